@@ -0,0 +1,164 @@
+package alog
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// An AccessRecord describes an HTTP request handled by AccessLog.
+type AccessRecord struct {
+	RemoteAddr string // the client's address, without the port
+	User       string // from basic auth, if any
+	Method     string
+	URI        string
+	Proto      string
+	Status     int
+	Size       int64 // bytes of body written
+	Referer    string
+	UserAgent  string
+	Duration   time.Duration
+}
+
+// AccessLog wraps next so that each request it handles is logged when done,
+// as a line like "GET /index.html 200 1.2KB 3.1ms" colored by status. The
+// line also carries an AccessRecord, for additional outputs to encode, e.g.
+// in Common Log Format with CLFEncoder:
+//
+//	accessLog, _ := os.OpenFile("access.log", os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+//	l.AddOutput(accessLog, log.OutputOptions{Encoder: log.CLFEncoder{Combined: true}})
+//	http.ListenAndServe(":8080", l.AccessLog(mux))
+func (l *Logger) AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := l.now()
+		rw := &accessResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rw, r)
+		if l.isDiscarded() {
+			return
+		}
+		user, _, _ := r.BasicAuth()
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		e := l.newEntry(1)
+		record := &AccessRecord{
+			RemoteAddr: host,
+			User:       user,
+			Method:     r.Method,
+			URI:        r.RequestURI,
+			Proto:      r.Proto,
+			Status:     rw.status,
+			Size:       rw.size,
+			Referer:    r.Referer(),
+			UserAgent:  r.UserAgent(),
+			Duration:   l.since(start, e.now),
+		}
+		e.access = record
+		l.emit(e, []byte(formatAccess(record)), false)
+	})
+}
+
+func AccessLog(next http.Handler) http.Handler { return DefaultLogger.AccessLog(next) }
+
+func formatAccess(r *AccessRecord) string {
+	color := ColorGreen
+	switch {
+	case r.Status >= 500:
+		color = ColorRed
+	case r.Status >= 400:
+		color = ColorYellow
+	case r.Status >= 300:
+		color = ColorCyan
+	}
+	uri := r.URI
+	if uri == "" {
+		uri = "-"
+	}
+	return fmt.Sprintf("%s %s %s %s %s\n", r.Method, uri, colorize(strconv.Itoa(r.Status), color),
+		formatBytes(r.Size), colorize(strings.TrimSpace(FormatDuration(r.Duration)), ColorDim))
+}
+
+// accessResponseWriter notes the status and size of a response.
+type accessResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	size        int64
+	wroteHeader bool
+}
+
+func (w *accessResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *accessResponseWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *accessResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *accessResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("hijacking not supported")
+}
+
+// CLFEncoder encodes lines logged by AccessLog in Common Log Format, or the
+// Combined Log Format if Combined is set, for tools that expect a web server's
+// access log. Other lines are left out.
+type CLFEncoder struct {
+	Combined bool
+}
+
+func (enc CLFEncoder) Encode(buf []byte, e *Entry) []byte {
+	r := e.Access
+	if r == nil {
+		return buf
+	}
+	buf = append(buf, clfField(r.RemoteAddr)...)
+	buf = append(buf, " - "...)
+	buf = append(buf, clfField(r.User)...)
+	buf = append(buf, " ["...)
+	buf = e.Time.AppendFormat(buf, "02/Jan/2006:15:04:05 -0700")
+	buf = append(buf, "] "...)
+	buf = strconv.AppendQuote(buf, r.Method+" "+r.URI+" "+r.Proto)
+	buf = append(buf, ' ')
+	buf = strconv.AppendInt(buf, int64(r.Status), 10)
+	buf = append(buf, ' ')
+	if r.Size == 0 {
+		buf = append(buf, '-')
+	} else {
+		buf = strconv.AppendInt(buf, r.Size, 10)
+	}
+	if enc.Combined {
+		buf = append(buf, ' ')
+		buf = strconv.AppendQuote(buf, r.Referer)
+		buf = append(buf, ' ')
+		buf = strconv.AppendQuote(buf, r.UserAgent)
+	}
+	return append(buf, byteNewline)
+}
+
+func clfField(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
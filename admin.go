@@ -0,0 +1,123 @@
+package alog
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// AdminSettings are the settings of a Logger that an AdminHandler shows and
+// changes.
+type AdminSettings struct {
+	Level        string `json:"level"`
+	Color        bool   `json:"color"`
+	PartialLines bool   `json:"partialLines"`
+}
+
+// adminUpdate is the body of a PUT to an AdminHandler; settings left out stay
+// as they are.
+type adminUpdate struct {
+	Level        *string `json:"level"`
+	Color        *bool   `json:"color"`
+	PartialLines *bool   `json:"partialLines"`
+}
+
+// NewAdminHandler returns an http.Handler for looking at and changing the
+// settings of loggers while the program runs, by name, e.g. on a service's
+// existing debug port:
+//
+//	http.Handle("/debug/log/", http.StripPrefix("/debug/log/", log.NewAdminHandler(map[string]*log.Logger{
+//		"default": log.DefaultLogger,
+//		"db":      dbLogger,
+//	})))
+//
+// GET of a name returns the logger's AdminSettings as JSON, and GET of the
+// root returns those of every logger, keyed by name. PUT of a name takes
+// AdminSettings as JSON, and changes whichever of them are given:
+//
+//	curl -X PUT -d '{"level": "debug"}' localhost:6060/debug/log/db
+func NewAdminHandler(loggers map[string]*Logger) http.Handler {
+	h := adminHandler{}
+	for name, l := range loggers {
+		h[name] = l
+	}
+	return h
+}
+
+type adminHandler map[string]*Logger
+
+func (h adminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(r.URL.Path, "/")
+	if name == "" {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		all := map[string]AdminSettings{}
+		for name, l := range h {
+			all[name] = l.adminSettings()
+		}
+		writeJSON(w, all)
+		return
+	}
+	l, ok := h[name]
+	if !ok {
+		http.Error(w, "no logger named "+name+"; try one of: "+strings.Join(h.names(), ", "), http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var update adminUpdate
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			http.Error(w, "bad settings: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if update.Level != nil {
+			level, err := ParseLevel(*update.Level)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			l.SetLevel(level)
+		}
+		if update.Color != nil {
+			l.SetColorEnabled(*update.Color)
+		}
+		if update.PartialLines != nil {
+			l.SetPartialLinesEnabled(*update.PartialLines)
+		}
+	default:
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPut)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, l.adminSettings())
+}
+
+func (h adminHandler) names() []string {
+	var names []string
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (l *Logger) adminSettings() AdminSettings {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	return AdminSettings{
+		Level:        l.level.String(),
+		Color:        l.isColorEnabled(),
+		PartialLines: l.isPartialLinesEnabled(),
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
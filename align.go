@@ -0,0 +1,42 @@
+package alog
+
+// SetPrefixWidth pads the Logger's prefix with spaces to width columns, so that
+// the messages of Loggers with different prefixes line up when they share a
+// writer. Zero turns padding off. See also AlignPrefixes.
+func (l *Logger) SetPrefixWidth(width int) {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	l.prefixWidth = width
+}
+
+// AlignPrefixes pads the prefixes of loggers to the width of the widest of
+// them, e.g.:
+//
+//	web := alog.New(os.Stderr, "web: ", 0)
+//	worker := alog.New(os.Stderr, "worker: ", 0)
+//	alog.AlignPrefixes(web, worker)
+func AlignPrefixes(loggers ...*Logger) {
+	width := 0
+	for _, l := range loggers {
+		if n := l.prefixVisibleWidth(); n > width {
+			width = n
+		}
+	}
+	for _, l := range loggers {
+		l.SetPrefixWidth(width)
+	}
+}
+
+// prefixVisibleWidth returns how many columns the Logger's prefix takes up,
+// with any fields in it filled in.
+func (l *Logger) prefixVisibleWidth() int {
+	e := entry{now: l.now()}
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	buf := getLineBuffer()
+	defer putLineBuffer(buf)
+	l.formatPrefix(buf, &e)
+	return VisibleStringLen(*buf)
+}
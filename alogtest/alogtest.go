@@ -0,0 +1,133 @@
+// Package alogtest helps test programs whose output is rendered with ansi-log,
+// by capturing it deterministically and comparing it against golden files.
+package alogtest
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	alog "github.com/tillberg/ansi-log"
+)
+
+// FixedTime is the time reported to Loggers created by NewLogger.
+var FixedTime = time.Date(2001, 2, 3, 4, 5, 6, 7000, time.UTC)
+
+// TerminalWidth is the width of the terminal Loggers created by NewLogger
+// pretend to write to.
+const TerminalWidth = 80
+
+// NewLogger creates a Logger writing to the returned buffer, with its clock
+// stopped at FixedTime and a terminal TerminalWidth columns wide, so that its
+// output is the same on every run.
+func NewLogger(prefix string, flag int) (*alog.Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	l := alog.New(&buf, prefix, flag)
+	l.SetNowFunc(func() time.Time { return FixedTime })
+	l.SetTerminalWidth(TerminalWidth)
+	return l, &buf
+}
+
+// Strip removes all ANSI escape sequences from s.
+func Strip(s string) string {
+	var buf bytes.Buffer
+	alog.NewStripWriter(&buf).Write([]byte(s))
+	return buf.String()
+}
+
+// Symbolize makes the escape sequences and carriage returns in s visible, so
+// that they show up in diffs: SGR sequences become e.g. {1;31}, other control
+// sequences {CSI 1A}, and carriage returns {CR}.
+func Symbolize(s string) string {
+	var out strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\r':
+			out.WriteString("{CR}")
+		case c == '\033' && i+1 < len(s) && s[i+1] == '[':
+			end := i + 2
+			for end < len(s) && (s[end] < 0x40 || s[end] > 0x7e) {
+				end++
+			}
+			if end == len(s) {
+				out.WriteString("{ESC}")
+				continue
+			}
+			if s[end] == 'm' {
+				fmt.Fprintf(&out, "{%s}", s[i+2:end])
+			} else {
+				fmt.Fprintf(&out, "{CSI %s}", s[i+2:end+1])
+			}
+			i = end
+		case c == '\033':
+			out.WriteString("{ESC}")
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return out.String()
+}
+
+// UpdateEnv is the environment variable that, when set, makes AssertGolden
+// write the actual output to the golden files rather than comparing.
+const UpdateEnv = "UPDATE_GOLDEN"
+
+// AssertGolden compares actual, symbolized, to testdata/<name>.golden and
+// fails t with a line-by-line diff if they differ.
+func AssertGolden(t testing.TB, name string, actual string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	got := Symbolize(actual)
+	if os.Getenv(UpdateEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading golden file (set %s=1 to create it): %v", UpdateEnv, err)
+	}
+	if diff := Diff(string(want), got); diff != "" {
+		t.Errorf("output differs from %s (set %s=1 to update it):\n%s", path, UpdateEnv, diff)
+	}
+}
+
+// Diff returns a line-by-line comparison of want and got, marking the lines
+// that differ, or "" if they're the same.
+func Diff(want, got string) string {
+	if want == got {
+		return ""
+	}
+	wantLines := strings.Split(want, "\n")
+	gotLines := strings.Split(got, "\n")
+	var out strings.Builder
+	for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w == g {
+			fmt.Fprintf(&out, "  %4d  %s\n", i+1, w)
+			continue
+		}
+		if i < len(wantLines) {
+			fmt.Fprintf(&out, "- %4d  %s\n", i+1, w)
+		}
+		if i < len(gotLines) {
+			fmt.Fprintf(&out, "+ %4d  %s\n", i+1, g)
+		}
+	}
+	return out.String()
+}
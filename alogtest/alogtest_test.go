@@ -0,0 +1,28 @@
+package alogtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSymbolize(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("{1;31}red{0}{CR}{CSI 1A}up{ESC}", Symbolize("\033[1;31mred\033[0m\r\033[1Aup\033"))
+	assert.Equal("redup", Strip("\033[1;31mred\033[0m\033[1Aup"))
+}
+
+func TestGolden(t *testing.T) {
+	l, buf := NewLogger("@(dim:{isodate}) ", 0)
+	defer l.Close()
+	l.EnableColorTemplate()
+	l.Printf("building")
+	l.Printf("... @(green:done)\n")
+	AssertGolden(t, "golden", buf.String())
+}
+
+func TestDiff(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("", Diff("a\nb", "a\nb"))
+	assert.Equal("     1  a\n-    2  b\n+    2  c\n", Diff("a\nb", "a\nc"))
+}
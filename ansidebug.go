@@ -0,0 +1,58 @@
+package alog
+
+import "fmt"
+
+// SetAnsiDebugEnabled turns on a mode for debugging colors that misbehave:
+// instead of being applied, escape sequences are printed symbolically, as in
+// "⟨ESC[31m⟩", and each line ends with the styling still active after it, as
+// tracked by the Logger, e.g. "⟨active: forecolor=31⟩". Color is printed this
+// way even where it's disabled.
+func (l *Logger) SetAnsiDebugEnabled(flag bool) {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	l.ansiDebug = boolPointer(flag)
+}
+func (l *Logger) EnableAnsiDebug()  { l.SetAnsiDebugEnabled(true) }
+func (l *Logger) DisableAnsiDebug() { l.SetAnsiDebugEnabled(false) }
+
+func EnableAnsiDebug()  { DefaultLogger.EnableAnsiDebug() }
+func DisableAnsiDebug() { DefaultLogger.DisableAnsiDebug() }
+
+func (l *Logger) isAnsiDebugEnabled() bool {
+	return isTrueDefaulted(l.ansiDebug, l.defaults().ansiDebug)
+}
+
+// appendAnsiDebug appends line with its escapes symbolized, followed by the
+// styling active at its end.
+func appendAnsiDebug(buf []byte, line []byte) []byte {
+	for i := 0; i < len(line); {
+		if line[i] != '\033' {
+			buf = append(buf, line[i])
+			i++
+			continue
+		}
+		n := escapeLength(line[i:])
+		if n == 0 {
+			// Cut off by the end of the line
+			n = len(line) - i
+		}
+		buf = append(buf, "⟨ESC"...)
+		buf = append(buf, line[i+1:i+n]...)
+		buf = append(buf, "⟩"...)
+		i += n
+	}
+	return append(buf, fmt.Sprintf(" ⟨active: %s⟩", getActiveAnsiCodes(line))...)
+}
+
+func (codes *ActiveAnsiCodes) String() string {
+	switch {
+	case codes.intensity != 0 && codes.forecolor != 0:
+		return fmt.Sprintf("intensity=%d forecolor=%d", codes.intensity, codes.forecolor)
+	case codes.intensity != 0:
+		return fmt.Sprintf("intensity=%d", codes.intensity)
+	case codes.forecolor != 0:
+		return fmt.Sprintf("forecolor=%d", codes.forecolor)
+	}
+	return "none"
+}
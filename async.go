@@ -0,0 +1,171 @@
+package alog
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// OverflowPolicy determines what an asynchronous Logger does when its queue
+// is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock makes callers wait for room in the queue.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest discards the oldest queued message to make room. The
+	// number of discarded messages is reported by AsyncDropped.
+	OverflowDropOldest
+)
+
+type asyncMessage struct {
+	e       entry
+	s       []byte
+	replace bool
+	done    chan struct{} // set on flush markers, closed once reached
+}
+
+type asyncQueue struct {
+	mutex    sync.RWMutex // held for reading while enqueueing, for writing to close
+	closed   bool
+	messages chan asyncMessage
+	policy   OverflowPolicy
+	dropped  uint64
+	stopped  chan struct{}
+}
+
+var errAsyncClosed = errors.New("Attempted to write to closed Logger.")
+
+func (q *asyncQueue) enqueue(m asyncMessage) error {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+	if q.closed {
+		return errAsyncClosed
+	}
+	if q.policy == OverflowBlock || m.done != nil {
+		q.messages <- m
+		return nil
+	}
+	for {
+		select {
+		case q.messages <- m:
+			return nil
+		default:
+		}
+		select {
+		case old := <-q.messages:
+			if old.done != nil {
+				// Never lose a flush marker; whoever is waiting on it is
+				// released early instead.
+				close(old.done)
+			} else {
+				atomic.AddUint64(&q.dropped, 1)
+			}
+		default:
+		}
+	}
+}
+
+// flush waits until everything queued so far has been written.
+func (q *asyncQueue) flush() {
+	done := make(chan struct{})
+	if q.enqueue(asyncMessage{done: done}) != nil {
+		return
+	}
+	<-done
+}
+
+// stop writes everything still queued and shuts down the background goroutine.
+func (q *asyncQueue) stop() {
+	q.mutex.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.messages)
+	}
+	q.mutex.Unlock()
+	<-q.stopped
+}
+
+func (l *Logger) runAsync(q *asyncQueue) {
+	defer close(q.stopped)
+	for m := range q.messages {
+		if m.done != nil {
+			close(m.done)
+			continue
+		}
+		// Render under the lock, but write after it's released, so that
+		// callers don't wait on a slow writer even to refresh their snapshot
+		ws := getWriterState(l.out)
+		ws.lock()
+		if m.replace {
+			l.truncateBuf()
+		}
+		ws.held++
+		l.intOutput(&m.e, m.s, true)
+		ws.held--
+		ws.writePendingAndUnlock(l.out)
+	}
+}
+
+// SetAsync makes the Logger queue up to size messages and render and write
+// them from a background goroutine, so that callers don't wait on slow
+// writers. Timestamps and callers are still captured at the time of each call.
+// The policy determines what happens when the queue is full. Flush waits for
+// the queue to drain, and Close drains and stops it. A size of 0 switches the
+// Logger back to writing synchronously. See SetBackgroundWritesEnabled for
+// how the two work together.
+func (l *Logger) SetAsync(size int, policy OverflowPolicy) {
+	l.stopAsync()
+	if size <= 0 {
+		return
+	}
+	q := &asyncQueue{
+		messages: make(chan asyncMessage, size),
+		policy:   policy,
+		stopped:  make(chan struct{}),
+	}
+	go l.runAsync(q)
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	l.async = q
+	configChanged()
+}
+
+// AsyncDropped returns the number of messages discarded because the async
+// queue was full.
+func (l *Logger) AsyncDropped() uint64 {
+	ws := getWriterState(l.out)
+	ws.lock()
+	q := l.async
+	ws.unlock()
+	if q == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&q.dropped)
+}
+
+func (l *Logger) getAsync() *asyncQueue {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	return l.async
+}
+
+func (l *Logger) drainAsync() {
+	if q := l.getAsync(); q != nil {
+		q.flush()
+	}
+}
+
+func (l *Logger) stopAsync() {
+	ws := getWriterState(l.out)
+	ws.lock()
+	q := l.async
+	l.async = nil
+	configChanged()
+	ws.unlock()
+	if q != nil {
+		q.stop()
+	}
+}
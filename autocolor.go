@@ -0,0 +1,44 @@
+package alog
+
+import "hash/fnv"
+
+// autoColorPalette holds the colors given out by SetAutoColorEnabled. Red is
+// left out, so as not to make ordinary lines look like errors.
+var autoColorPalette = []ColorCode{
+	ColorGreen,
+	ColorYellow,
+	ColorBlue,
+	ColorMagenta,
+	ColorCyan,
+	ColorBright | ColorGreen,
+	ColorBright | ColorYellow,
+	ColorBright | ColorBlue,
+	ColorBright | ColorMagenta,
+	ColorBright | ColorCyan,
+}
+
+// SetAutoColorEnabled determines whether a prefix without any colors of its
+// own is given one automatically, picked from a palette by a hash of the
+// prefix. That way, the lines of many Loggers (one per worker, say) sharing a
+// terminal can be told apart at a glance, and each prefix keeps its color from
+// one run to the next. Enabling it on the DefaultLogger enables it for Loggers
+// whose prefix is set afterwards.
+func (l *Logger) SetAutoColorEnabled(flag bool) {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	l.autoColor = boolPointer(flag)
+	l.reprocessPrefix()
+}
+func (l *Logger) EnableAutoColor()  { l.SetAutoColorEnabled(true) }
+func (l *Logger) DisableAutoColor() { l.SetAutoColorEnabled(false) }
+
+func EnableAutoColor()  { DefaultLogger.EnableAutoColor() }
+func DisableAutoColor() { DefaultLogger.DisableAutoColor() }
+
+// autoColor returns the color that SetAutoColorEnabled gives prefix.
+func autoColor(prefix []byte) ColorCode {
+	h := fnv.New32a()
+	h.Write(prefix)
+	return autoColorPalette[h.Sum32()%uint32(len(autoColorPalette))]
+}
@@ -0,0 +1,92 @@
+package alog
+
+import "strings"
+
+// A BadgeTheme says how the Llevel flag renders the level of each line.
+type BadgeTheme struct {
+	Left, Right string           // around every label, e.g. "[" and "]"
+	Labels      map[Level]string // padded to the width of the widest
+	Colors      map[Level][]int  // SGR codes for each label, e.g. background colors
+}
+
+// BracketBadges renders levels as "[INFO ]", "[ERROR]" and so on, on colored
+// backgrounds. It's the default.
+var BracketBadges = &BadgeTheme{
+	Left:  "[",
+	Right: "]",
+	Labels: map[Level]string{
+		LevelDebug: "DEBUG",
+		LevelInfo:  "INFO",
+		LevelWarn:  "WARN",
+		LevelError: "ERROR",
+	},
+	Colors: defaultBadgeColors,
+}
+
+// GlyphBadges renders levels as single colored glyphs.
+var GlyphBadges = &BadgeTheme{
+	Labels: map[Level]string{
+		LevelDebug: "·",
+		LevelInfo:  "•",
+		LevelWarn:  "▲",
+		LevelError: "✖",
+	},
+	Colors: defaultBadgeColors,
+}
+
+var defaultBadgeColors = map[Level][]int{
+	LevelDebug: {37, 40},
+	LevelInfo:  {37, 44},
+	LevelWarn:  {30, 43},
+	LevelError: {37, 41},
+}
+
+// appendBadge appends the badge for level.
+func (theme *BadgeTheme) appendBadge(buf []byte, level Level) []byte {
+	width := 0
+	for _, label := range theme.Labels {
+		if n := VisibleStringLen([]byte(label)); n > width {
+			width = n
+		}
+	}
+	label, ok := theme.Labels[level]
+	if !ok {
+		label = level.String()
+	}
+	colors := theme.Colors[level]
+	for _, code := range colors {
+		buf = append(buf, ansiEscapeBytes(code)...)
+	}
+	buf = append(buf, theme.Left...)
+	buf = append(buf, label...)
+	if n := VisibleStringLen([]byte(label)); n < width {
+		buf = append(buf, strings.Repeat(" ", width-n)...)
+	}
+	buf = append(buf, theme.Right...)
+	if len(colors) != 0 {
+		// Background colors aren't tracked like the others, so reset all
+		buf = append(buf, ansiEscapeBytes(ansiCodeResetAll)...)
+	}
+	return buf
+}
+
+// SetBadgeTheme sets how the Llevel flag renders levels. nil restores the
+// default, BracketBadges.
+func (l *Logger) SetBadgeTheme(theme *BadgeTheme) {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	l.badgeTheme = theme
+}
+
+func SetBadgeTheme(theme *BadgeTheme) { DefaultLogger.SetBadgeTheme(theme) }
+
+func (l *Logger) getBadgeTheme() *BadgeTheme {
+	if l.badgeTheme != nil {
+		return l.badgeTheme
+	}
+	if theme := l.defaults().badgeTheme; theme != nil {
+		return theme
+	}
+	return BracketBadges
+}
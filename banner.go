@@ -0,0 +1,68 @@
+package alog
+
+import "strings"
+
+// minLineWidth is the narrowest that Rule, Banner and friends will draw, no
+// matter how little room the terminal leaves them.
+const minLineWidth = 10
+
+// Rule prints a horizontal line across the width of the terminal, to separate
+// one phase of a long run from the next.
+func (l *Logger) Rule() {
+	if l.isDiscarded() {
+		return
+	}
+	e := l.newEntry(2)
+	line := colorize(strings.Repeat(l.glyphs().horizontal, l.lineWidth(&e)), ColorBlue)
+	l.emit(e, []byte(line+"\n"), false)
+}
+
+// Banner prints title centered between two horizontal lines across the width
+// of the terminal, e.g. l.Banner("Deploying v1.2.3"). Color templates in title
+// are expanded.
+func (l *Logger) Banner(title string) {
+	if l.isDiscarded() {
+		return
+	}
+	e := l.newEntry(2)
+	width := l.lineWidth(&e)
+	rule := colorize(strings.Repeat(l.glyphs().double, width), ColorBlue)
+	text := trimStringEllipsis([]byte(l.Colorify(title)), width)
+	padding := strings.Repeat(" ", (width-VisibleStringLen(text))/2)
+	var out strings.Builder
+	out.WriteString(rule + "\n")
+	out.WriteString(padding + colorize(string(text), ColorBright) + "\n")
+	out.WriteString(rule + "\n")
+	l.emit(e, []byte(out.String()), false)
+}
+
+func Rule()               { DefaultLogger.Rule() }
+func Banner(title string) { DefaultLogger.Banner(title) }
+
+// lineWidth returns how many columns of the terminal are left for a message
+// logged as e, after the Logger's header.
+func (l *Logger) lineWidth(e *entry) int {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	header := getLineBuffer()
+	defer putLineBuffer(header)
+	l.formatHeader(header, e)
+	width := getTermWidth(l.out) - 1 - VisibleStringLen(*header)
+	if width < minLineWidth {
+		return minLineWidth
+	}
+	return width
+}
+
+// colorize returns s in each of colors, leaving any colors of its own in place.
+func colorize(s string, colors ...ColorCode) string {
+	var buf []byte
+	for _, color := range colors {
+		for _, code := range color.GetAnsiCodes() {
+			buf = append(buf, ansiEscapeBytes(code)...)
+		}
+	}
+	buf = append(buf, s...)
+	return string(append(buf, getActiveAnsiCodes(buf).getResetBytes()...))
+}
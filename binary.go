@@ -0,0 +1,60 @@
+package alog
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PrintBinary prints a line previewing data, e.g. a request body or a frame
+// read off the wire, without writing any of it to the terminal raw. Mostly
+// textual data is shown quoted, with anything unprintable escaped; anything
+// else is shown in hex. Only the first max bytes are shown (all of them if max
+// is negative), and the line is labeled with label and the length of data.
+func (l *Logger) PrintBinary(label string, data []byte, max int) {
+	l.emitLazy(2, func() string { return formatBinary(label, data, max) })
+}
+
+func PrintBinary(label string, data []byte, max int) {
+	DefaultLogger.emitLazy(2, func() string { return formatBinary(label, data, max) })
+}
+
+func formatBinary(label string, data []byte, max int) string {
+	preview := data
+	if max >= 0 && len(preview) > max {
+		preview = preview[:max]
+	}
+	var out strings.Builder
+	fmt.Fprintf(&out, "%s (%s): ", label, plural(len(data), "byte"))
+	if isMostlyText(preview) {
+		out.WriteString(strconv.Quote(string(preview)))
+	} else {
+		for i, b := range preview {
+			if i > 0 {
+				out.WriteByte(' ')
+			}
+			out.WriteString(hex.EncodeToString([]byte{b}))
+		}
+	}
+	if rest := len(data) - len(preview); rest > 0 {
+		out.WriteString(colorize(fmt.Sprintf(" ... %d more", rest), ColorDim))
+	}
+	out.WriteString("\n")
+	return out.String()
+}
+
+// isMostlyText reports whether nearly all of data is printable UTF-8.
+func isMostlyText(data []byte) bool {
+	printable := 0
+	for i := 0; i < len(data); {
+		r, n := utf8.DecodeRune(data[i:])
+		if r != utf8.RuneError && (unicode.IsPrint(r) || r == '\t' || r == '\n' || r == '\r') {
+			printable += n
+		}
+		i += n
+	}
+	return printable >= len(data)*9/10
+}
@@ -0,0 +1,49 @@
+package alog
+
+import "time"
+
+// A Bookmark is a named point in a program's output, recorded by SetBookmark.
+type Bookmark struct {
+	Name string
+	Time time.Time
+	Line int // the number of the next line written after it; see SetBookmark
+}
+
+// SetBookmark records the current point in the output under name, e.g. at the
+// start of each phase of a long migration, for listing later with Bookmarks.
+// Bookmarks are kept per writer, alongside its partial lines. With Llinenum,
+// a bookmark's line number is the one shown next to the Logger's next line;
+// otherwise, lines are counted from 1 across all the Loggers writing there.
+func (l *Logger) SetBookmark(name string) {
+	now := l.now()
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	line := ws.linesWritten + 1
+	if l.flag&Llinenum != 0 {
+		line = l.peekLineNum(ws)
+	}
+	ws.bookmarks = append(ws.bookmarks, Bookmark{Name: name, Time: now, Line: line})
+}
+
+// Bookmarks returns the bookmarks recorded so far on the Logger's writer,
+// oldest first.
+func (l *Logger) Bookmarks() []Bookmark {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	return append([]Bookmark(nil), ws.bookmarks...)
+}
+
+// ResetBookmarks forgets the bookmarks recorded so far on the Logger's writer.
+func (l *Logger) ResetBookmarks() {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	ws.bookmarks = nil
+}
+
+// SetBookmark records a bookmark on the standard logger's writer.
+func SetBookmark(name string) { DefaultLogger.SetBookmark(name) }
+func Bookmarks() []Bookmark   { return DefaultLogger.Bookmarks() }
+func ResetBookmarks()         { DefaultLogger.ResetBookmarks() }
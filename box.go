@@ -0,0 +1,153 @@
+package alog
+
+import (
+	"os"
+	"strings"
+)
+
+// glyphs are the characters used to draw rules and boxes, and to show the
+// state of each target in a Targets grid.
+type glyphs struct {
+	horizontal, double, vertical string
+	topLeft, topRight            string
+	bottomLeft, bottomRight      string
+	ellipsis                     string
+	pending, ok, failed          string
+	spinner                      []string
+}
+
+var unicodeGlyphs = glyphs{
+	horizontal: "─", double: "═", vertical: "│",
+	topLeft: "╭", topRight: "╮",
+	bottomLeft: "╰", bottomRight: "╯",
+	pending: "·", ok: "✓", failed: "✗",
+	ellipsis: "…",
+	spinner:  []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"},
+}
+
+var asciiGlyphs = glyphs{
+	horizontal: "-", double: "=", vertical: "|",
+	topLeft: "+", topRight: "+",
+	bottomLeft: "+", bottomRight: "+",
+	pending: ".", ok: "+", failed: "x",
+	ellipsis: "...",
+	spinner:  []string{"|", "/", "-", "\\"},
+}
+
+// localeIsUTF8 guesses from the environment whether the terminal can show
+// box-drawing characters. Without any locale set at all, it assumes so.
+func localeIsUTF8() bool {
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if value := os.Getenv(name); value != "" {
+			value = strings.ToLower(value)
+			return strings.Contains(value, "utf-8") || strings.Contains(value, "utf8")
+		}
+	}
+	return true
+}
+
+// SetUnicodeEnabled determines whether Rule, Banner and Box draw with unicode
+// box-drawing characters, or fall back to plain ASCII. By default, unicode is
+// used unless the locale says otherwise.
+func (l *Logger) SetUnicodeEnabled(flag bool) {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	l.unicodeEnabled = boolPointer(flag)
+}
+func (l *Logger) EnableUnicode()  { l.SetUnicodeEnabled(true) }
+func (l *Logger) DisableUnicode() { l.SetUnicodeEnabled(false) }
+
+func EnableUnicode()  { DefaultLogger.EnableUnicode() }
+func DisableUnicode() { DefaultLogger.DisableUnicode() }
+
+func (l *Logger) glyphs() *glyphs {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	if isTrueDefaulted(l.unicodeEnabled, l.defaults().unicodeEnabled) {
+		return &unicodeGlyphs
+	}
+	return &asciiGlyphs
+}
+
+// Box prints lines inside a box drawn in color style, for messages that
+// shouldn't be missed, e.g.:
+//
+//	log.Box(alog.ColorYellow, "A new version is available.", "Run `tool update` to get it.")
+//
+// Color templates in lines are expanded, and lines too long for the terminal
+// are wrapped.
+func (l *Logger) Box(style ColorCode, lines ...string) {
+	if l.isDiscarded() {
+		return
+	}
+	e := l.newEntry(2)
+	g := l.glyphs()
+	maxWidth := l.lineWidth(&e) - 4
+	var wrapped []string
+	width := 0
+	for _, line := range lines {
+		for _, part := range wrapLine(l.Colorify(line), maxWidth) {
+			if n := VisibleStringLen([]byte(part)); n > width {
+				width = n
+			}
+			wrapped = append(wrapped, part)
+		}
+	}
+	horizontal := strings.Repeat(g.horizontal, width+2)
+	vertical := colorize(g.vertical, style)
+	var out strings.Builder
+	out.WriteString(colorize(g.topLeft+horizontal+g.topRight, style) + "\n")
+	for _, line := range wrapped {
+		padding := strings.Repeat(" ", width-VisibleStringLen([]byte(line)))
+		out.WriteString(vertical + " " + line + padding + " " + vertical + "\n")
+	}
+	out.WriteString(colorize(g.bottomLeft+horizontal+g.bottomRight, style) + "\n")
+	l.emit(e, []byte(out.String()), false)
+}
+
+func Box(style ColorCode, lines ...string) { DefaultLogger.Box(style, lines...) }
+
+// wrapLine splits line at spaces into lines no wider than width, breaking words
+// only where they don't fit on a line by themselves. Colors active at a break
+// are reset at the end of the line and set again at the start of the next.
+func wrapLine(line string, width int) []string {
+	var lines []string
+	var cur []byte
+	curLen := 0
+	flush := func() {
+		codes := getActiveAnsiCodes(cur)
+		lines = append(lines, string(append(cur, codes.getResetBytes()...)))
+		cur = codes.appendSetBytes(nil)
+		curLen = 0
+	}
+	for i, word := range strings.Split(line, " ") {
+		wordLen := VisibleStringLen([]byte(word))
+		if i > 0 && curLen > 0 {
+			if curLen+1+wordLen > width {
+				flush()
+			} else {
+				cur = append(cur, ' ')
+				curLen++
+			}
+		}
+		for wordLen > width-curLen {
+			if curLen > 0 {
+				flush()
+				continue
+			}
+			head := trimString([]byte(word), width)
+			cur = append(cur, head...)
+			curLen = width
+			word = word[len(head):]
+			wordLen -= width
+		}
+		cur = append(cur, word...)
+		curLen += wordLen
+	}
+	if curLen > 0 || len(lines) == 0 {
+		lines = append(lines, string(cur))
+	}
+	return lines
+}
@@ -0,0 +1,100 @@
+package alog
+
+import (
+	"bufio"
+	"io"
+	"sync"
+	"time"
+)
+
+// BufferedWriter batches writes to a non-interactive destination (a file, a
+// pipe, a socket), writing them out once size bytes have accumulated or once
+// interval has passed since the first unwritten byte, whichever comes first.
+type BufferedWriter struct {
+	mutex    sync.Mutex
+	buf      *bufio.Writer
+	dest     io.Writer
+	interval time.Duration
+	timer    *time.Timer
+}
+
+// NewBufferedWriter wraps w in a BufferedWriter. An interval of 0 disables the
+// time-based flush, leaving it to size and explicit calls to Flush.
+func NewBufferedWriter(w io.Writer, size int, interval time.Duration) *BufferedWriter {
+	return &BufferedWriter{
+		buf:      bufio.NewWriterSize(w, size),
+		dest:     w,
+		interval: interval,
+	}
+}
+
+func (b *BufferedWriter) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	n, err := b.buf.Write(p)
+	if b.buf.Buffered() > 0 && b.interval > 0 && b.timer == nil {
+		b.timer = time.AfterFunc(b.interval, func() { b.Flush() })
+	}
+	return n, err
+}
+
+// Flush writes out anything buffered.
+func (b *BufferedWriter) Flush() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	return b.buf.Flush()
+}
+
+// Writer returns the underlying destination.
+func (b *BufferedWriter) Writer() io.Writer {
+	return b.dest
+}
+
+// SetBuffered routes the output of every Logger writing to this Logger's
+// destination through a shared BufferedWriter, so that lines from different
+// Loggers still come out in the order they were logged. Terminals aren't
+// buffered, as buffering would get in the way of interactivity. Flush and Close
+// on any of those Loggers write out anything still buffered. Calling it again
+// replaces the previous size and interval.
+func (l *Logger) SetBuffered(size int, interval time.Duration) {
+	if isTerminal(l.out) {
+		return
+	}
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	ws.waitBackground(l.out)
+	if ws.buffered != nil {
+		ws.buffered.Flush()
+	}
+	ws.buffered = NewBufferedWriter(l.out, size, interval)
+}
+
+// dest returns where output meant for out goes: the writer's shared
+// BufferedWriter if there is one, otherwise out itself.
+func (w *WriterState) dest(out io.Writer) io.Writer {
+	if w.buffered != nil {
+		return w.buffered
+	}
+	return out
+}
+
+// flushBuffered writes out anything buffered for out, whether by SetBuffered or
+// by a BufferedWriter passed in as the output. Must be called with the writer
+// locked.
+func (w *WriterState) flushBuffered(out io.Writer) {
+	if w.buffered != nil {
+		w.buffered.Flush()
+	}
+	flushBufferedWriter(out)
+}
+
+func flushBufferedWriter(w io.Writer) {
+	if b, ok := w.(*BufferedWriter); ok {
+		b.Flush()
+	}
+}
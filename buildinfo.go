@@ -0,0 +1,71 @@
+//go:build go1.18
+// +build go1.18
+
+package alog
+
+import (
+	"runtime/debug"
+	"strings"
+)
+
+// PrintBuildInfo prints a line describing the running program, from the build
+// information embedded in it: its module path and version, the commit it was
+// built from, and the version of Go used, e.g.:
+//
+//	example.com/tool v1.4.2 (commit 3f2a9c1e0b7d, modified) go1.21.5
+//
+// Whatever isn't known is left out.
+func (l *Logger) PrintBuildInfo() { l.emitLazy(2, buildInfoLine) }
+
+func PrintBuildInfo() { DefaultLogger.emitLazy(2, buildInfoLine) }
+
+// buildInfoLine returns the line PrintBuildInfo prints, or "" if there's no
+// build information.
+func buildInfoLine() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	return formatBuildInfo(info) + "\n"
+}
+
+// commitLength is how much of a commit hash PrintBuildInfo shows.
+const commitLength = 12
+
+func formatBuildInfo(info *debug.BuildInfo) string {
+	var parts []string
+	path := info.Main.Path
+	if path == "" {
+		path = info.Path
+	}
+	if path != "" {
+		parts = append(parts, colorize(path, ColorBright))
+	}
+	if version := info.Main.Version; version != "" && version != "(devel)" {
+		parts = append(parts, highlight(version))
+	}
+	var commit string
+	modified := false
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			commit = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if commit != "" {
+		if len(commit) > commitLength {
+			commit = commit[:commitLength]
+		}
+		s := "(commit " + commit
+		if modified {
+			s += ", modified"
+		}
+		parts = append(parts, colorize(s+")", ColorDim))
+	}
+	if info.GoVersion != "" {
+		parts = append(parts, info.GoVersion)
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,26 @@
+//go:build go1.18
+// +build go1.18
+
+package alog
+
+import (
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatBuildInfo(t *testing.T) {
+	assert := assert.New(t)
+	info := &debug.BuildInfo{
+		GoVersion: "go1.21.5",
+		Main:      debug.Module{Path: "example.com/tool", Version: "v1.4.2"},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "3f2a9c1e0b7d55aa"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	}
+	assert.Equal(string(Uncolorize([]byte(formatBuildInfo(info)))), "example.com/tool v1.4.2 (commit 3f2a9c1e0b7d, modified) go1.21.5")
+	info = &debug.BuildInfo{GoVersion: "go1.21.5", Main: debug.Module{Path: "example.com/tool", Version: "(devel)"}}
+	assert.Equal(string(Uncolorize([]byte(formatBuildInfo(info)))), "example.com/tool go1.21.5")
+}
@@ -0,0 +1,55 @@
+package alog
+
+import (
+	"os"
+	"sync"
+)
+
+// CaptureStdout swaps os.Stdout and os.Stderr for pipes that feed l, so that
+// stray fmt.Println calls and the like (from dependencies, say) are printed as
+// lines labelled "stdout" or "stderr" rather than colliding with partial lines.
+// Only writes made through the os.Stdout and os.Stderr variables are caught,
+// not ones made straight to the file descriptors (by child processes, say).
+// The returned func puts them back, after everything written so far has been
+// printed. l must not write to the captured os.Stdout or os.Stderr itself.
+func (l *Logger) CaptureStdout() (restore func()) {
+	stdout, stderr := os.Stdout, os.Stderr
+	var wg sync.WaitGroup
+	capture := func(label string, style string) *os.File {
+		r, w, err := os.Pipe()
+		if err != nil {
+			l.Warnf("couldn't capture %s: %v", label, err)
+			return nil
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.PrefixPipe(r, label, style)
+			r.Close()
+		}()
+		return w
+	}
+	outW := capture("stdout", "dim")
+	errW := capture("stderr", "red")
+	if outW != nil {
+		os.Stdout = outW
+	}
+	if errW != nil {
+		os.Stderr = errW
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			if outW != nil {
+				outW.Close()
+			}
+			if errW != nil {
+				errW.Close()
+			}
+			wg.Wait()
+			os.Stdout, os.Stderr = stdout, stderr
+		})
+	}
+}
+
+func CaptureStdout() (restore func()) { return DefaultLogger.CaptureStdout() }
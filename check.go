@@ -0,0 +1,45 @@
+package alog
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+)
+
+// CheckErr does nothing if err is nil. Otherwise, it prints err in red along
+// with where CheckErr was called from, and exits like Fatal.
+func (l *Logger) CheckErr(err error) {
+	if err != nil {
+		l.exitErr(2, err)
+	}
+}
+
+func CheckErr(err error) {
+	if err != nil {
+		DefaultLogger.exitErr(2, err)
+	}
+}
+
+// exitErr prints err along with the caller calldepth frames up, and exits.
+func (l *Logger) exitErr(calldepth int, err error) {
+	e := l.newEntry(calldepth + 1)
+	l.exitFatal(e, l.errWithCaller(calldepth+1, err))
+}
+
+// errWithCaller formats err along with the function, file and line of the
+// caller calldepth frames up, e.g.:
+//
+//	error: open config.json: no such file or directory
+//	  at main.loadConfig (config.go:42)
+func (l *Logger) errWithCaller(calldepth int, err error) string {
+	s := colorize("error: "+err.Error(), ColorRed) + "\n"
+	pc, file, line, ok := runtime.Caller(calldepth + l.snapshot().callDepth)
+	if !ok {
+		return s
+	}
+	at := fmt.Sprintf("%s:%d", filepath.Base(file), line)
+	if fn := runtime.FuncForPC(pc); fn != nil {
+		at = fmt.Sprintf("%s (%s)", fn.Name(), at)
+	}
+	return s + colorize("  at "+at, ColorDim) + "\n"
+}
@@ -0,0 +1,168 @@
+package alog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A CIMode says how output is marked up for a CI system's log viewer.
+type CIMode int
+
+const (
+	// CINone leaves output as it is, for a person at a terminal.
+	CINone CIMode = iota
+	// CIGitHub writes warnings and errors as GitHub Actions workflow commands,
+	// so they show up as annotations, and groups as collapsible ::group::s.
+	CIGitHub
+	// CIGitLab writes groups as GitLab collapsible sections.
+	CIGitLab
+	// CIBuildkite writes groups as Buildkite "---" headers, and expands the
+	// current group when an error is logged in it.
+	CIBuildkite
+)
+
+// DetectCI returns the CIMode for the CI system the program is running under,
+// judging by the environment, or CINone if none. The standard logger starts
+// out in this mode.
+func DetectCI() CIMode {
+	switch {
+	case os.Getenv("GITHUB_ACTIONS") == "true":
+		return CIGitHub
+	case os.Getenv("GITLAB_CI") == "true":
+		return CIGitLab
+	case os.Getenv("BUILDKITE") == "true":
+		return CIBuildkite
+	}
+	return CINone
+}
+
+// SetCIMode sets how output is marked up for a CI system. Like
+// SetTerminalWidth, this applies to every Logger sharing the Logger's writer.
+func (l *Logger) SetCIMode(mode CIMode) {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	ws.ciMode = mode
+}
+
+func SetCIMode(mode CIMode) { DefaultLogger.SetCIMode(mode) }
+
+// Group starts a group of lines called name, which CI systems show as a
+// collapsible section, and returns a func that ends it, e.g.:
+//
+//	end := log.Group("Run tests")
+//	runTests()
+//	end()
+//
+// At a terminal, the name is printed as a heading.
+func (l *Logger) Group(name string) (end func()) {
+	if l.isDiscarded() {
+		return func() {}
+	}
+	ws := getWriterState(l.out)
+	ws.lock()
+	mode := ws.ciMode
+	ws.unlock()
+	if mode == CINone {
+		l.emit(l.newEntry(2), []byte(colorize(name, ColorBright)+"\n"), false)
+		return func() {}
+	}
+
+	id := ciSectionID(name)
+	l.writeCILine(func() string {
+		switch mode {
+		case CIGitHub:
+			return "::group::" + ciEscapeMessage(name)
+		case CIGitLab:
+			return fmt.Sprintf("\033[0Ksection_start:%d:%s\r\033[0K%s", l.now().Unix(), id, name)
+		}
+		return "--- " + name
+	}, +1)
+	ended := false
+	return func() {
+		if ended {
+			return
+		}
+		ended = true
+		l.writeCILine(func() string {
+			switch mode {
+			case CIGitHub:
+				return "::endgroup::"
+			case CIGitLab:
+				return fmt.Sprintf("\033[0Ksection_end:%d:%s\r\033[0K", l.now().Unix(), id)
+			}
+			return "" // Buildkite groups run until the next one
+		}, -1)
+	}
+}
+
+func Group(name string) (end func()) { return DefaultLogger.Group(name) }
+
+// writeCILine writes the markup returned by line, if any, on a line of its own,
+// without a header, and adjusts the count of open groups by delta.
+func (l *Logger) writeCILine(line func() string, delta int) {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	ws.ciGroups += delta
+	if s := line(); s != "" {
+		l.writeFormattedLine(ws, []byte(s), LevelInfo)
+		updateTempOutput(l.out)
+		ws.writePending(l.out)
+	}
+}
+
+// writeCIFormattedLine is writeFormattedLine for a warning or error while in a
+// CIMode; line is the bare message, without the header. Must be called with
+// the writer locked.
+func (l *Logger) writeCIFormattedLine(ws *WriterState, formatted, line []byte, e *entry) {
+	switch ws.ciMode {
+	case CIGitHub:
+		command := "warning"
+		if e.level >= LevelError {
+			command = "error"
+		}
+		annotation := "::" + command
+		if e.callerFile != "" {
+			annotation += fmt.Sprintf(" file=%s,line=%d", ciEscapeProperty(ciRelativePath(e.callerFile)), e.callerLine)
+		}
+		annotation += "::" + ciEscapeMessage(string(Uncolorize(line)))
+		formatted = []byte(annotation)
+	case CIBuildkite:
+		if ws.ciGroups > 0 && e.level >= LevelError {
+			l.writeFormattedLine(ws, []byte("^^^ +++"), e.level)
+		}
+	}
+	l.writeFormattedLine(ws, formatted, e.level)
+}
+
+// ciRelativePath makes path relative to the checkout, where GitHub expects it.
+func ciRelativePath(path string) string {
+	workspace := os.Getenv("GITHUB_WORKSPACE")
+	if workspace == "" || !filepath.IsAbs(path) {
+		return path
+	}
+	if rel, err := filepath.Rel(workspace, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+var ciMessageEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+var ciPropertyEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+
+func ciEscapeMessage(s string) string  { return ciMessageEscaper.Replace(s) }
+func ciEscapeProperty(s string) string { return ciPropertyEscaper.Replace(s) }
+
+// ciSectionID turns name into an identifier GitLab accepts for a section.
+func ciSectionID(name string) string {
+	id := []byte(strings.ToLower(name))
+	for i, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9') {
+			id[i] = '_'
+		}
+	}
+	return string(id)
+}
@@ -0,0 +1,111 @@
+package alog
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+)
+
+// MinColumnWidth is the narrowest a column made by Columns can be. When the
+// terminal is too narrow to fit all the columns, their lines are printed one
+// after the other at full width instead.
+const MinColumnWidth = 20
+
+// Columns splits the terminal into n side-by-side columns, and returns a Logger
+// for each, e.g. one per job run in parallel. Each line written to one of them
+// is wrapped to the width of its column, and printed in that column with the
+// others left blank. The returned Loggers don't show partial lines.
+func (l *Logger) Columns(n int) []*Logger {
+	if n < 1 {
+		n = 1
+	}
+	group := &columnGroup{l: l, n: n}
+	loggers := make([]*Logger, n)
+	for i := range loggers {
+		w := &columnWriter{group: group, index: i}
+		loggers[i] = New(w, "", 0)
+		loggers[i].HidePartialLines()
+		if width := group.columnWidth(group.width()); width != 0 {
+			loggers[i].SetTerminalWidth(width + 1)
+		}
+	}
+	return loggers
+}
+
+func Columns(n int) []*Logger { return DefaultLogger.Columns(n) }
+
+type columnGroup struct {
+	l     *Logger
+	n     int
+	mutex sync.Mutex
+}
+
+func (g *columnGroup) width() int {
+	e := g.l.newEntry(0)
+	return g.l.lineWidth(&e)
+}
+
+// columnWidth returns how wide each column is when the Logger has width
+// columns of the terminal to work with, or zero if they don't fit.
+func (g *columnGroup) columnWidth(width int) int {
+	width = (width - columnSeparatorWidth*(g.n-1)) / g.n
+	if width < MinColumnWidth {
+		return 0
+	}
+	return width
+}
+
+// columnSeparatorWidth is the width of the line between columns, with a space
+// either side.
+const columnSeparatorWidth = 3
+
+// render prints line in the column index.
+func (g *columnGroup) render(index int, line []byte) {
+	e := g.l.newEntry(3)
+	width := g.columnWidth(g.l.lineWidth(&e))
+	if width == 0 {
+		g.l.emit(e, append(line, byteNewline), false)
+		return
+	}
+	separator := colorize(" "+g.l.glyphs().vertical+" ", ColorBlue)
+	blank := strings.Repeat(" ", width)
+	var out strings.Builder
+	for _, part := range wrapLine(string(line), width) {
+		for i := 0; i < g.n; i++ {
+			if i > 0 {
+				out.WriteString(separator)
+			}
+			if i != index {
+				out.WriteString(blank)
+				continue
+			}
+			out.WriteString(part)
+			out.WriteString(blank[:width-VisibleStringLen([]byte(part))])
+		}
+		out.WriteString("\n")
+	}
+	g.l.emit(e, []byte(out.String()), false)
+}
+
+// columnWriter collects what a column's Logger writes into lines, and has them
+// rendered into the column.
+type columnWriter struct {
+	group *columnGroup
+	index int
+	buf   []byte
+}
+
+func (w *columnWriter) Write(p []byte) (int, error) {
+	w.group.mutex.Lock()
+	defer w.group.mutex.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, byteNewline)
+		if i == -1 {
+			break
+		}
+		w.group.render(w.index, w.buf[:i:i])
+		w.buf = w.buf[:copy(w.buf, w.buf[i+1:])]
+	}
+	return len(p), nil
+}
@@ -0,0 +1,330 @@
+package alog
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Config is the contents of a configuration file read by WatchConfig.
+// Settings left out are left as they are.
+type Config struct {
+	Level   string            `json:"level"`   // debug, info, warn or error
+	Color   *bool             `json:"color"`   // whether to print colors
+	Theme   string            `json:"theme"`   // level badges: brackets or glyphs
+	Output  string            `json:"output"`  // also append each line to this file
+	Format  string            `json:"format"`  // the format for output, as for -log-format
+	Loggers map[string]string `json:"loggers"` // levels for other Loggers, by name
+}
+
+var badgeThemes = map[string]*BadgeTheme{
+	"brackets": BracketBadges,
+	"glyphs":   GlyphBadges,
+}
+
+// configWatchInterval is how often WatchConfig checks for changes.
+const configWatchInterval = time.Second
+
+// WatchConfig configures the Logger from the file at path, and then again
+// whenever the file changes, so that a daemon's logging can be changed without
+// restarting it. The file may be JSON, YAML or TOML, by its extension, e.g.:
+//
+//	{"level": "info", "output": "/var/log/app.log", "loggers": {"db": "debug"}}
+//
+// or:
+//
+//	level: info
+//	output: /var/log/app.log
+//	loggers:
+//	  db: debug
+//
+// or:
+//
+//	level = "info"
+//	output = "/var/log/app.log"
+//	[loggers]
+//	db = "debug"
+//
+// Only the simple keys and values that Config needs are understood in YAML and
+// TOML; anchors, lists, inline tables and the like are mistakes. Levels under
+// "loggers" apply to the Loggers of the same name in loggers, which may be
+// nil. If a changed file can't be read or has a mistake in it, the Logger says
+// so and keeps the settings it has. WatchConfig returns an error if the file
+// can't be used to begin with; otherwise, call the returned function to stop
+// watching it, which also closes the output file, if any.
+func (l *Logger) WatchConfig(path string, loggers map[string]*Logger) (stop func(), err error) {
+	w := &configWatcher{l: l, path: path, loggers: loggers, file: &logFileFlag{l: l, format: "text"}}
+	if err := w.load(); err != nil {
+		return nil, err
+	}
+	cancel := l.getScheduler().Every(configWatchInterval, func() {
+		if !w.changed() {
+			return
+		}
+		if err := w.load(); err != nil {
+			l.Warnf("not reloading %s: %v", path, err)
+		} else {
+			l.Infof("reloaded %s", path)
+		}
+	})
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			cancel()
+			w.file.close()
+		})
+	}, nil
+}
+
+func WatchConfig(path string, loggers map[string]*Logger) (stop func(), err error) {
+	return DefaultLogger.WatchConfig(path, loggers)
+}
+
+type configWatcher struct {
+	l       *Logger
+	path    string
+	loggers map[string]*Logger
+	file    *logFileFlag
+	output  string // the path file was opened with
+	modTime time.Time
+	size    int64
+}
+
+// changed reports whether the file has changed since it was last loaded.
+func (w *configWatcher) changed() bool {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return false
+	}
+	return !info.ModTime().Equal(w.modTime) || info.Size() != w.size
+}
+
+func (w *configWatcher) load() error {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return err
+	}
+	w.modTime, w.size = info.ModTime(), info.Size()
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		return err
+	}
+	config, err := parseConfig(filepath.Ext(w.path), data)
+	if err != nil {
+		return fmt.Errorf("%s: %v", w.path, err)
+	}
+	return w.apply(config)
+}
+
+// apply checks all of config, and opens its output file, if that's changed,
+// and only then applies it.
+func (w *configWatcher) apply(config *Config) error {
+	var level Level
+	var err error
+	if config.Level != "" {
+		if level, err = ParseLevel(config.Level); err != nil {
+			return err
+		}
+	}
+	theme, ok := badgeThemes[config.Theme]
+	if config.Theme != "" && !ok {
+		return fmt.Errorf("unknown theme %q", config.Theme)
+	}
+	if _, ok := logFormats[config.Format]; config.Format != "" && !ok {
+		return fmt.Errorf("unknown log format %q", config.Format)
+	}
+	levels := map[*Logger]Level{}
+	for name, s := range config.Loggers {
+		l, ok := w.loggers[name]
+		if !ok {
+			return fmt.Errorf("no logger named %q", name)
+		}
+		if levels[l], err = ParseLevel(s); err != nil {
+			return err
+		}
+	}
+	var output *os.File
+	if config.Output != "" && config.Output != w.output {
+		if output, err = openLogFile(config.Output); err != nil {
+			return err
+		}
+	}
+
+	if config.Level != "" {
+		w.l.SetLevel(level)
+	}
+	if config.Color != nil {
+		w.l.SetColorEnabled(*config.Color)
+	}
+	if theme != nil {
+		w.l.SetBadgeTheme(theme)
+	}
+	if config.Format != "" {
+		w.file.setFormat(config.Format)
+	}
+	if output != nil {
+		w.file.setFile(output)
+		w.output = config.Output
+	}
+	for l, level := range levels {
+		l.SetLevel(level)
+	}
+	return nil
+}
+
+// parseConfig parses a config file with the extension ext.
+func parseConfig(ext string, data []byte) (*Config, error) {
+	var values map[string]interface{}
+	var err error
+	switch strings.ToLower(ext) {
+	case ".json":
+		var config Config
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, err
+		}
+		return &config, nil
+	case ".yaml", ".yml":
+		values, err = parseYAMLConfig(data)
+	case ".toml":
+		values, err = parseTOMLConfig(data)
+	default:
+		return nil, fmt.Errorf("unsupported config format %q; use .json, .yaml or .toml", ext)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// By way of JSON, so that the same keys apply
+	js, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	var config Config
+	if err := json.Unmarshal(js, &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// parseYAMLConfig parses the simple YAML a Config needs: "key: value" lines,
+// and "key:" followed by indented "key: value" lines for a map.
+func parseYAMLConfig(data []byte) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	var section map[string]interface{}
+	for i, line := range strings.Split(string(data), "\n") {
+		indented := strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
+		line = strings.TrimSpace(stripConfigComment(line))
+		if line == "" || line == "---" {
+			continue
+		}
+		colon := strings.Index(line, ":")
+		if colon <= 0 {
+			return nil, fmt.Errorf("line %d: expected key: value", i+1)
+		}
+		key := strings.TrimSpace(line[:colon])
+		text := strings.TrimSpace(line[colon+1:])
+		if indented {
+			if section == nil {
+				return nil, fmt.Errorf("line %d: unexpected indentation", i+1)
+			}
+			value, err := parseConfigValue(text, true)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", i+1, err)
+			}
+			section[key] = value
+			continue
+		}
+		if text == "" {
+			section = map[string]interface{}{}
+			values[key] = section
+			continue
+		}
+		section = nil
+		value, err := parseConfigValue(text, true)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// parseTOMLConfig parses the simple TOML a Config needs: "key = value" lines,
+// and "[key]" tables of them for a map.
+func parseTOMLConfig(data []byte) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	table := values
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(stripConfigComment(line))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			key := strings.TrimSpace(line[1 : len(line)-1])
+			if key == "" || strings.ContainsAny(key, "[].") {
+				return nil, fmt.Errorf("line %d: unsupported table %s", i+1, line)
+			}
+			table = map[string]interface{}{}
+			values[key] = table
+			continue
+		}
+		eq := strings.Index(line, "=")
+		if eq <= 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", i+1)
+		}
+		value, err := parseConfigValue(strings.TrimSpace(line[eq+1:]), false)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		table[strings.Trim(strings.TrimSpace(line[:eq]), `"`)] = value
+	}
+	return values, nil
+}
+
+// parseConfigValue parses a string or boolean value in a YAML or TOML config.
+// YAML's strings needn't be quoted; TOML's must be.
+func parseConfigValue(s string, bare bool) (interface{}, error) {
+	switch {
+	case s == "":
+		return nil, fmt.Errorf("empty value")
+	case s == "true":
+		return true, nil
+	case s == "false":
+		return false, nil
+	case strings.HasPrefix(s, `"`):
+		return strconv.Unquote(s)
+	case len(s) >= 2 && strings.HasPrefix(s, "'") && strings.HasSuffix(s, "'"):
+		return s[1 : len(s)-1], nil
+	case bare && !strings.ContainsAny(s[:1], "[{&*!|>%@`"):
+		return s, nil
+	}
+	return nil, fmt.Errorf("unsupported value %s", s)
+}
+
+// stripConfigComment removes a "#" comment from the end of a line of YAML or
+// TOML, unless it's in a quoted string.
+func stripConfigComment(line string) string {
+	var quote rune
+	escaped := false
+	for i, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else if r == '\\' && quote == '"' {
+				escaped = true
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
@@ -0,0 +1,40 @@
+package alog
+
+import (
+	"context"
+	"flag"
+)
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx that carries l, for handing a Logger down
+// through code that passes a context along anyway, e.g. a cobra command's
+// (cmd.SetContext) or a urfave/cli action's (c.Context).
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the Logger carried by ctx, or the DefaultLogger if it
+// doesn't carry one.
+func FromContext(ctx context.Context) *Logger {
+	if l, ok := ctx.Value(contextKey{}).(*Logger); ok {
+		return l
+	}
+	return DefaultLogger
+}
+
+// FlagSet returns a new FlagSet named name with the flags of RegisterFlags
+// defined on it, for CLI frameworks that take their flags from elsewhere. With
+// cobra:
+//
+//	rootCmd.PersistentFlags().AddGoFlagSet(log.FlagSet("log"))
+//
+// With urfave/cli, parse the FlagSet from a Before hook, or wrap each of its
+// flags (see FlagSet.VisitAll) in a cli.GenericFlag.
+func (l *Logger) FlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	l.RegisterFlags(fs)
+	return fs
+}
+
+func FlagSet(name string) *flag.FlagSet { return DefaultLogger.FlagSet(name) }
@@ -0,0 +1,121 @@
+package alog
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Countdown counts down from d on a partial line, e.g. "starting in 9s…", and
+// returns when it reaches zero, clearing the line. Use CountdownContext for a
+// countdown that can be canceled, e.g. to give the user a chance to abort a
+// destructive operation with Ctrl-C.
+func (l *Logger) Countdown(label string, d time.Duration) {
+	l.countdown(context.Background(), label, d)
+}
+
+// CountdownContext is Countdown for a countdown that should end with ctx: if
+// ctx is done first, the line is finished as canceled, in yellow, and
+// CountdownContext returns ctx.Err(). It returns nil once the countdown
+// reaches zero.
+func (l *Logger) CountdownContext(ctx context.Context, label string, d time.Duration) error {
+	return l.countdown(ctx, label, d)
+}
+
+func (l *Logger) countdown(ctx context.Context, label string, d time.Duration) error {
+	cl := l.derive("")
+	defer cl.Destroy()
+	label = cl.Colorify(label)
+	ellipsis := cl.glyphs().ellipsis
+	last := d
+	err := cl.wait(ctx, d, func(remaining time.Duration) {
+		last = remaining
+		if !cl.isDiscarded() {
+			line := fmt.Sprintf("%s %s%s", label, formatCountdown(remaining), ellipsis)
+			cl.emit(cl.newEntry(3), []byte(line), true)
+		}
+	})
+	if err != nil {
+		if !cl.isDiscarded() {
+			e := cl.newEntry(2)
+			e.level = LevelWarn
+			line := fmt.Sprintf("%s %s%s canceled", label, formatCountdown(last), ellipsis)
+			line = colorize(string(Uncolorize([]byte(line))), ColorYellow)
+			cl.emit(e, []byte(line+"\n"), true)
+		}
+		return err
+	}
+	ws := getWriterState(cl.out)
+	ws.lock()
+	cl.clearPartialLine(ws)
+	ws.unlock()
+	return nil
+}
+
+func Countdown(label string, d time.Duration) {
+	DefaultLogger.countdown(context.Background(), label, d)
+}
+func CountdownContext(ctx context.Context, label string, d time.Duration) error {
+	return DefaultLogger.countdown(ctx, label, d)
+}
+
+// wait waits for d on the Logger's Scheduler, calling show with the time
+// remaining, formatted by formatCountdown, whenever that changes. It returns
+// ctx.Err() if ctx is done first.
+func (l *Logger) wait(ctx context.Context, d time.Duration, show func(remaining time.Duration)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if d <= 0 {
+		return nil
+	}
+	show(d)
+	tick := countdownTick
+	if d < tick {
+		tick = d
+	}
+	remaining := d
+	shown := formatCountdown(d)
+	var mutex sync.Mutex
+	stopped := false
+	done := make(chan struct{})
+	cancel := l.getScheduler().Every(tick, func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if stopped || remaining <= 0 {
+			return // already done, but not yet canceled
+		}
+		remaining -= tick
+		if remaining <= 0 {
+			close(done)
+		} else if s := formatCountdown(remaining); s != shown {
+			shown = s
+			show(remaining)
+		}
+	})
+	defer func() {
+		cancel()
+		mutex.Lock()
+		stopped = true // so that show isn't called after wait returns
+		mutex.Unlock()
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// countdownTick is how often wait checks whether it's done.
+const countdownTick = 100 * time.Millisecond
+
+// formatCountdown formats d as whole seconds, rounded up, or as tenths of a
+// second if it's less than one.
+func formatCountdown(d time.Duration) string {
+	if d < time.Second {
+		return fmt.Sprintf("%.1fs", d.Seconds())
+	}
+	return fmt.Sprintf("%ds", (d+time.Second-1)/time.Second)
+}
@@ -0,0 +1,154 @@
+package alog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// SetCrashReportDir makes Fatal and Panic write a crash report into dir, with
+// the final message, the lines in the Logger's RingBuffer (if it has one), the
+// stacks of all goroutines, and build information, and then print the path to
+// it. An empty dir turns this off.
+func (l *Logger) SetCrashReportDir(dir string) {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	l.crashReportDir = dir
+}
+
+func SetCrashReportDir(dir string) { DefaultLogger.SetCrashReportDir(dir) }
+
+// exitFatal prints s, along with a crash report if the Logger makes them, and
+// exits.
+func (l *Logger) exitFatal(e entry, s string) {
+	e.level = LevelError
+	l.emit(e, []byte(s), false)
+	l.writeCrashReport(e, s)
+	l.drainAsync()
+	osExit()
+}
+
+// FatalWithHint prints err in red, followed by an indented hint on what to do
+// about it, and exits, e.g.:
+//
+//	log.FatalWithHint(err, "Check that the server is running, or pass --addr.")
+//
+// The hint is dimmed and wrapped to the width of the terminal.
+func (l *Logger) FatalWithHint(err error, hint string) {
+	e := l.newEntry(2)
+	l.exitFatal(e, l.formatHint(&e, err, hint))
+}
+
+func FatalWithHint(err error, hint string) {
+	e := DefaultLogger.newEntry(2)
+	DefaultLogger.exitFatal(e, DefaultLogger.formatHint(&e, err, hint))
+}
+
+const hintLabel = "  hint: "
+
+func (l *Logger) formatHint(e *entry, err error, hint string) string {
+	var out strings.Builder
+	out.WriteString(colorize("error: "+err.Error(), ColorRed) + "\n")
+	indent := strings.Repeat(" ", len(hintLabel))
+	for i, line := range wrapLine(hint, l.lineWidth(e)-len(hintLabel)) {
+		if i == 0 {
+			line = hintLabel + line
+		} else {
+			line = indent + line
+		}
+		out.WriteString(colorize(line, ColorDim) + "\n")
+	}
+	return out.String()
+}
+
+// panicWith prints s, along with a crash report if the Logger makes them, and
+// then panics with it.
+func (l *Logger) panicWith(e entry, s string) {
+	e.level = LevelError
+	l.emit(e, []byte(s), false)
+	l.writeCrashReport(e, s)
+	l.Flush()
+	panic(s)
+}
+
+func (l *Logger) writeCrashReport(e entry, s string) {
+	ws := getWriterState(l.out)
+	ws.lock()
+	dir := l.crashReportDir
+	ring := l.ring
+	ws.unlock()
+	if dir == "" {
+		return
+	}
+	path, err := writeCrashReport(dir, e.now, s, ring)
+	var msg string
+	if err != nil {
+		msg = fmt.Sprintf("failed to write crash report: %v\n", err)
+	} else {
+		msg = fmt.Sprintf("crash report written to %s\n", path)
+	}
+	if !strings.HasSuffix(s, "\n") {
+		// Finish the final message's line first
+		msg = "\n" + msg
+	}
+	l.emit(e, []byte(msg), false)
+}
+
+func writeCrashReport(dir string, now time.Time, s string, ring *RingBuffer) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("%s-crash-%s.txt", filepath.Base(os.Args[0]), now.Format("20060102-150405.000000"))
+	path := filepath.Join(dir, name)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	var report strings.Builder
+	fmt.Fprintf(&report, "%s crashed at %s\n\n", os.Args[0], now.Format(time.RFC3339Nano))
+	report.Write(Uncolorize([]byte(strings.TrimSuffix(s, "\n"))))
+	report.WriteString("\n")
+	if ring != nil {
+		report.WriteString("\nRecent lines:\n")
+		var buf []byte
+		for _, e := range ring.Entries() {
+			buf = append(buf[:0], "  "...)
+			buf = appendEntryRecord(buf, &e)
+			buf = append(buf, byteNewline)
+			report.Write(buf)
+		}
+	}
+	report.WriteString("\nBuild:\n")
+	fmt.Fprintf(&report, "  go: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	if info, ok := debug.ReadBuildInfo(); ok {
+		fmt.Fprintf(&report, "  main: %s %s\n", info.Main.Path, info.Main.Version)
+		for _, dep := range info.Deps {
+			fmt.Fprintf(&report, "  dep: %s %s\n", dep.Path, dep.Version)
+		}
+	}
+	fmt.Fprintf(&report, "  args: %q\n", os.Args)
+	report.WriteString("\nGoroutines:\n")
+	report.Write(allStacks())
+
+	_, err = file.WriteString(report.String())
+	return path, err
+}
+
+// allStacks returns the stack traces of all goroutines.
+func allStacks() []byte {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
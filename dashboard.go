@@ -0,0 +1,138 @@
+package alog
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// A Dashboard shows running counts for a worker pool or queue, e.g. "pending 12
+// running 4 completed 31 failed 1", on a partial line of its own that stays
+// below the Logger's other output and is redrawn whenever a count changes.
+type Dashboard struct {
+	l      *Logger // shows the counts
+	mutex  sync.Mutex
+	names  []string // in the order they're shown
+	counts map[string]int
+	closed bool
+}
+
+// dashboardColors are the colors of the counts every Dashboard starts with.
+var dashboardColors = map[string]ColorCode{
+	"pending":   ColorNone,
+	"running":   ColorCyan,
+	"completed": ColorGreen,
+	"failed":    ColorRed,
+}
+
+// NewDashboard starts a Dashboard showing pending, running, completed and
+// failed counts, all zero. Other counts are added to the end as they're first
+// used. Close the Dashboard to finish its line, e.g.:
+//
+//	d := log.NewDashboard()
+//	defer d.Close()
+//	d.Add("pending", len(jobs))
+//	for _, job := range jobs {
+//		go func(job Job) {
+//			d.Move("pending", "running")
+//			if err := job.Run(); err != nil {
+//				d.Move("running", "failed")
+//			} else {
+//				d.Move("running", "completed")
+//			}
+//		}(job)
+//	}
+func (l *Logger) NewDashboard() *Dashboard {
+	d := &Dashboard{
+		l:      l.derive(""),
+		names:  []string{"pending", "running", "completed", "failed"},
+		counts: map[string]int{},
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.show(2, "")
+	return d
+}
+
+func NewDashboard() *Dashboard { return DefaultLogger.NewDashboard() }
+
+// Inc adds one to the count name.
+func (d *Dashboard) Inc(name string) { d.update(name, 1, "") }
+
+// Dec subtracts one from the count name.
+func (d *Dashboard) Dec(name string) { d.update(name, -1, "") }
+
+// Add adds delta to the count name.
+func (d *Dashboard) Add(name string, delta int) { d.update(name, delta, "") }
+
+// Move subtracts one from the count from and adds one to the count to, as when
+// a job goes from pending to running, in a single update.
+func (d *Dashboard) Move(from, to string) { d.update(from, -1, to) }
+
+// Count returns the count name.
+func (d *Dashboard) Count(name string) int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.counts[name]
+}
+
+// Close prints the final counts as a line of its own. The Dashboard shouldn't
+// be updated afterward.
+func (d *Dashboard) Close() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.closed {
+		return
+	}
+	d.closed = true
+	d.show(2, "\n")
+	d.l.Destroy()
+}
+
+// update adds delta to name and, if moveTo is set, subtracts it from moveTo,
+// then redraws the counts.
+func (d *Dashboard) update(name string, delta int, moveTo string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.closed {
+		return
+	}
+	d.add(name, delta)
+	if moveTo != "" {
+		d.add(moveTo, -delta)
+	}
+	d.show(3, "")
+}
+
+// add adds delta to name. Must be called with d.mutex held.
+func (d *Dashboard) add(name string, delta int) {
+	if _, ok := d.counts[name]; !ok {
+		if _, ok := dashboardColors[name]; !ok {
+			d.names = append(d.names, name)
+		}
+	}
+	d.counts[name] += delta
+}
+
+// show draws the counts, followed by end, for the caller calldepth frames up. Must be called with d.mutex held.
+func (d *Dashboard) show(calldepth int, end string) {
+	if d.l.isDiscarded() {
+		return
+	}
+	var line strings.Builder
+	for i, name := range d.names {
+		if i > 0 {
+			line.WriteString("  ")
+		}
+		count := d.counts[name]
+		s := fmt.Sprintf("%s %d", name, count)
+		if count == 0 {
+			s = colorize(s, ColorDim)
+		} else if color := dashboardColors[name]; color != ColorNone {
+			s = colorize(s, color)
+		}
+		line.WriteString(s)
+	}
+	line.WriteString(end)
+	d.l.emit(d.l.newEntry(calldepth+1), []byte(line.String()), true)
+}
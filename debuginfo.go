@@ -0,0 +1,74 @@
+package alog
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriterInfo is a snapshot of what the package knows about a writer's screen,
+// for diagnosing partial lines that come out garbled. Its String method formats
+// it for pasting into a bug report.
+type WriterInfo struct {
+	TempLines     []string // each partial line as last drawn, escapes and all
+	TempWidths    []int    // how many columns each takes up
+	AnsiState     string   // the styling still active after the last of them
+	TempLoggers   int      // how many Loggers have partial lines
+	TermWidth     int
+	Multiline     bool
+	OnTerminal    bool
+	CursorLine    int // which partial line the cursor is on
+	CursorInline  bool
+	CursorAtBegin bool
+	Updates       int // how many times the partial lines were composed
+	Redraws       int // how many times a partial line was drawn in full,
+	Appends       int // or extended in place
+	Lines         int // how many finished lines were written
+}
+
+// WriterDebugInfo returns a snapshot of the state kept for w, or nil if no
+// Logger has written there.
+func WriterDebugInfo(w io.Writer) *WriterInfo {
+	mutexGlobal.RLock()
+	ws, ok := writers[w]
+	mutexGlobal.RUnlock()
+	if !ok {
+		return nil
+	}
+	ws.lock()
+	defer ws.unlock()
+	info := &WriterInfo{
+		AnsiState:     "none",
+		TempLoggers:   len(ws.tempLoggers),
+		TermWidth:     getTermWidth(w),
+		Multiline:     ws.multiline,
+		OnTerminal:    ws.onTerminal,
+		CursorLine:    ws.cursorLineIndex,
+		CursorInline:  ws.cursorIsInline,
+		CursorAtBegin: ws.cursorIsAtBegin,
+		Updates:       ws.tempUpdates,
+		Redraws:       ws.tempRedraws,
+		Appends:       ws.tempAppends,
+		Lines:         ws.linesWritten,
+	}
+	for _, line := range ws.lastTemp {
+		info.TempLines = append(info.TempLines, string(line))
+		info.TempWidths = append(info.TempWidths, VisibleStringLen(line))
+	}
+	if n := len(ws.lastTemp); n != 0 {
+		info.AnsiState = getActiveAnsiCodes(ws.lastTemp[n-1]).String()
+	}
+	return info
+}
+
+func (info *WriterInfo) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "terminal: %t, width %d, multiline %t\n", info.OnTerminal, info.TermWidth, info.Multiline)
+	fmt.Fprintf(&b, "cursor: line %d, inline %t, at beginning %t\n", info.CursorLine, info.CursorInline, info.CursorAtBegin)
+	fmt.Fprintf(&b, "partial lines: %d from %d loggers, active styling %s\n", len(info.TempLines), info.TempLoggers, info.AnsiState)
+	for i, line := range info.TempLines {
+		fmt.Fprintf(&b, "  %d (%d columns): %s\n", i, info.TempWidths[i], appendAnsiDebug(nil, []byte(line)))
+	}
+	fmt.Fprintf(&b, "counts: %d updates, %d redraws, %d appends, %d lines\n", info.Updates, info.Redraws, info.Appends, info.Lines)
+	return b.String()
+}
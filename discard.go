@@ -0,0 +1,51 @@
+package alog
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// Discard is a Logger that prints nothing, and skips formatting its arguments
+// too. Libraries can default to it to stay silent unless given a Logger.
+var Discard = newDiscard()
+
+func newDiscard() *Logger {
+	l := New(io.Discard, "", 0)
+	l.discard = true
+	return l
+}
+
+// Set while DisableAll is in effect.
+var allDisabled int32
+
+// DisableAll mutes every Logger, until EnableAll is called. Fatal still exits
+// and Panic still panics, but nothing is printed.
+func DisableAll() { atomic.StoreInt32(&allDisabled, 1) }
+
+// EnableAll undoes DisableAll.
+func EnableAll() { atomic.StoreInt32(&allDisabled, 0) }
+
+func (l *Logger) isDiscarded() bool {
+	return l.discard || atomic.LoadInt32(&allDisabled) != 0
+}
+
+// isPrinting reports whether a line at level is worth formatting, i.e. whether
+// it would be printed, or at least recorded. Print and the like check this
+// first, whether called on a Logger or at the package level, so that both
+// filter lines alike.
+func (l *Logger) isPrinting(level Level) bool {
+	return !l.isDiscarded() && !l.snapshot().isHidden(level)
+}
+
+// emitLazy emits the message returned by format, unless the Logger is
+// discarded, in which case format isn't called at all. Nothing is emitted if
+// format returns "".
+func (l *Logger) emitLazy(calldepth int, format func() string) {
+	if l.isDiscarded() {
+		return
+	}
+	e := l.newEntry(calldepth + 1)
+	if s := format(); s != "" {
+		l.emit(e, []byte(s), false)
+	}
+}
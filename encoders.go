@@ -0,0 +1,127 @@
+package alog
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GELFEncoder encodes each entry as a GELF 1.1 message, for Graylog, e.g.:
+//
+//	conn, err := net.Dial("udp", "graylog:12201")
+//	...
+//	l.AddOutput(conn, log.OutputOptions{Encoder: log.GELFEncoder{}})
+//
+// Levels are mapped to their syslog severities, and the file and line, when
+// known, are sent as the additional fields _file and _line.
+type GELFEncoder struct {
+	Host string // the source of the messages; the machine's hostname by default
+	// NullDelimited ends each message with a NUL byte instead of a newline, as
+	// GELF over TCP requires.
+	NullDelimited bool
+}
+
+type gelfEntry struct {
+	Version      string  `json:"version"`
+	Host         string  `json:"host"`
+	ShortMessage string  `json:"short_message"`
+	Timestamp    float64 `json:"timestamp"`
+	Level        int     `json:"level"`
+	File         string  `json:"_file,omitempty"`
+	Line         int     `json:"_line,omitempty"`
+}
+
+func (enc GELFEncoder) Encode(buf []byte, e *Entry) []byte {
+	message := e.Message
+	if message == "" {
+		message = "-" // GELF requires a non-empty short_message
+	}
+	data, err := json.Marshal(gelfEntry{
+		Version:      "1.1",
+		Host:         encoderHost(enc.Host),
+		ShortMessage: message,
+		Timestamp:    float64(e.Time.UnixNano()/int64(time.Millisecond)) / 1000,
+		Level:        syslogSeverity(e.Level),
+		File:         e.File,
+		Line:         e.Line,
+	})
+	if err != nil {
+		return buf
+	}
+	buf = append(buf, data...)
+	if enc.NullDelimited {
+		return append(buf, 0)
+	}
+	return append(buf, byteNewline)
+}
+
+// LogstashEncoder encodes each entry as a JSON object with the field layout
+// Logstash and the rest of the ELK stack expect, one per line, e.g.:
+//
+//	{"@timestamp":"2024-05-01T12:00:00.000Z","@version":"1","message":"hi","level":"INFO","level_value":20000,"host":"web1"}
+type LogstashEncoder struct {
+	Host string // the machine's hostname by default
+}
+
+type logstashEntry struct {
+	Timestamp  string `json:"@timestamp"`
+	Version    string `json:"@version"`
+	Message    string `json:"message"`
+	Level      string `json:"level"`
+	LevelValue int    `json:"level_value"`
+	Host       string `json:"host"`
+	File       string `json:"file,omitempty"`
+	Line       int    `json:"line_number,omitempty"`
+}
+
+func (enc LogstashEncoder) Encode(buf []byte, e *Entry) []byte {
+	data, err := json.Marshal(logstashEntry{
+		Timestamp:  e.Time.Format("2006-01-02T15:04:05.000Z07:00"),
+		Version:    "1",
+		Message:    e.Message,
+		Level:      strings.ToUpper(e.Level.String()),
+		LevelValue: (int(e.Level) + 2) * 10000,
+		Host:       encoderHost(enc.Host),
+		File:       e.File,
+		Line:       e.Line,
+	})
+	if err != nil {
+		return buf
+	}
+	buf = append(buf, data...)
+	return append(buf, byteNewline)
+}
+
+// syslogSeverity returns the syslog severity for level.
+func syslogSeverity(level Level) int {
+	switch {
+	case level <= LevelDebug:
+		return 7
+	case level == LevelInfo:
+		return 6
+	case level == LevelWarn:
+		return 4
+	}
+	return 3
+}
+
+var (
+	hostnameOnce sync.Once
+	hostname     string
+)
+
+// encoderHost returns host, or the machine's hostname if host is empty.
+func encoderHost(host string) string {
+	if host != "" {
+		return host
+	}
+	hostnameOnce.Do(func() {
+		hostname, _ = os.Hostname()
+		if hostname == "" {
+			hostname = "localhost"
+		}
+	})
+	return hostname
+}
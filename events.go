@@ -0,0 +1,98 @@
+package alog
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// SetEventOutput makes the Logger also write a JSON object to w, one per line,
+// for each event a program driving it might want to show in its own UI: each
+// finished line ("line"), each change to the Logger's partial line
+// ("partial"), and each Step starting and ending ("step_start" and
+// "step_end"), e.g.:
+//
+//	{"event":"step_end","time":"2024-05-01T12:00:01.25Z","name":"build","status":"ok","duration_ms":1250}
+//
+// Unlike AddOutput, this applies to the Loggers derived from the Logger, as
+// for Steps. A nil w stops the events.
+func (l *Logger) SetEventOutput(w io.Writer) {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	if w == nil {
+		l.events = nil
+		return
+	}
+	l.events = &eventWriter{w: w}
+}
+
+func SetEventOutput(w io.Writer) { DefaultLogger.SetEventOutput(w) }
+
+type eventWriter struct {
+	mutex sync.Mutex
+	w     io.Writer
+}
+
+type jsonEvent struct {
+	Event      string   `json:"event"`
+	Time       string   `json:"time"`
+	Level      string   `json:"level,omitempty"`
+	Msg        *string  `json:"msg,omitempty"`
+	Name       string   `json:"name,omitempty"`
+	Status     string   `json:"status,omitempty"`
+	DurationMs *float64 `json:"duration_ms,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+func (ew *eventWriter) write(ev *jsonEvent, now time.Time) {
+	ev.Time = now.Format(time.RFC3339Nano)
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	ew.mutex.Lock()
+	defer ew.mutex.Unlock()
+	ew.w.Write(append(data, byteNewline))
+}
+
+// writeLineEvent writes the event for a line, finished or not. Must be called
+// with the writer locked.
+func (l *Logger) writeLineEvent(event string, line []byte, e *entry) {
+	msg := string(Uncolorize(line))
+	ev := jsonEvent{Event: event, Msg: &msg}
+	if event == "line" {
+		ev.Level = e.level.String()
+	}
+	l.events.write(&ev, e.now)
+}
+
+// writeStepEvent writes the event for a Step starting, or for result if set.
+func (l *Logger) writeStepEvent(name string, result *StepResult, now time.Time) {
+	ws := getWriterState(l.out)
+	ws.lock()
+	events := l.events
+	ws.unlock()
+	if events == nil {
+		return
+	}
+	if result == nil {
+		events.write(&jsonEvent{Event: "step_start", Name: name}, now)
+		return
+	}
+	ev := jsonEvent{Event: "step_end", Name: name, Status: "ok"}
+	ms := result.Duration.Seconds() * 1000
+	ev.DurationMs = &ms
+	if result.Canceled {
+		ev.Status = "canceled"
+		ev.Error = result.Err.Error()
+	} else if result.Err != nil {
+		ev.Status = "failed"
+		ev.Error = result.Err.Error()
+	} else if result.Skipped != "" {
+		ev.Status = "skipped"
+		ev.Error = result.Skipped
+	}
+	events.write(&ev, now)
+}
@@ -0,0 +1,48 @@
+package alog
+
+import (
+	"sync"
+	"time"
+)
+
+// LogEvery calls status every interval and prints what it returns, until the
+// returned func is called, e.g.:
+//
+//	stop := log.LogEvery(30*time.Second, func() string {
+//		return fmt.Sprintf("%d of %d done", done, total)
+//	})
+//	defer stop()
+//
+// A status without a trailing newline is shown as a partial line, replaced by
+// each one after it; a status with one is printed as a line of its own. The
+// status line is separate from the Logger's own partial line, and is finished
+// off by stop. An empty status prints nothing.
+func (l *Logger) LogEvery(interval time.Duration, status func() string) (stop func()) {
+	sl := l.derive("")
+	var mutex sync.Mutex
+	stopped := false
+	cancel := l.getScheduler().Every(interval, func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if stopped {
+			return
+		}
+		if s := status(); s != "" && !sl.isDiscarded() {
+			sl.emit(sl.newEntry(1), []byte(s), true)
+		}
+	})
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			cancel()
+			mutex.Lock()
+			stopped = true
+			mutex.Unlock()
+			sl.Destroy()
+		})
+	}
+}
+
+func LogEvery(interval time.Duration, status func() string) (stop func()) {
+	return DefaultLogger.LogEvery(interval, status)
+}
@@ -0,0 +1,78 @@
+package alog
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+)
+
+// commandCounts counts the commands run with Run, and how many of them failed.
+var commandCounts struct {
+	run    int64
+	failed int64
+}
+
+// CommandCounts returns how many commands have been run with Run since the
+// program started or ResetCounts was last called, and how many of them failed.
+func CommandCounts() (run, failed int) {
+	return int(atomic.LoadInt64(&commandCounts.run)), int(atomic.LoadInt64(&commandCounts.failed))
+}
+
+// stderrTag marks lines a command run with Run wrote to stderr.
+var stderrTag = colorize("!", ColorRed) + " "
+
+// Run runs cmd, logging whatever it writes to stdout and stderr with label
+// added to the prefix, and then a line with its exit status and how long it
+// took: green for "exit 0", and red otherwise. Lines from stderr are tagged
+// with a red "!". The two are logged in the order they're read, and each has
+// a partial line of its own, so that they don't break into each other's lines.
+// Run returns the error from cmd.Run. Failed commands are counted by
+// CommandCounts, and included in Summary.
+//
+// If cmd calls ReportProgressToParent, its partial lines and Steps are shown
+// on a partial line of its own while it runs.
+func (l *Logger) Run(cmd *exec.Cmd, label string) error {
+	cl := l.derive(label)
+	defer cl.Destroy()
+	el := cl.derive(stderrTag)
+	defer el.Destroy()
+	cmd.Stdout = &LineWriter{l: cl}
+	cmd.Stderr = &LineWriter{l: el}
+	start := cl.now()
+	progress, err := cl.attachProgressChannel(cmd)
+	if err == nil {
+		err = cmd.Start()
+		progress.start()
+		if err == nil {
+			err = cmd.Wait()
+		}
+		progress.wait()
+	}
+	duration := strings.TrimSpace(FormatDuration(cl.since(start, cl.now())))
+
+	atomic.AddInt64(&commandCounts.run, 1)
+	if err != nil {
+		atomic.AddInt64(&commandCounts.failed, 1)
+	}
+	if cl.isDiscarded() {
+		return err
+	}
+	var s string
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		s = colorize(fmt.Sprintf("exit 0 (%s)", duration), ColorGreen)
+	case errors.As(err, &exitErr):
+		s = colorize(fmt.Sprintf("exit %d (%s)", exitErr.ExitCode(), duration), ColorRed)
+	default:
+		s = colorize(err.Error(), ColorRed)
+	}
+	cl.Flush()
+	el.Flush()
+	cl.emit(cl.newEntry(2), []byte(s+"\n"), false)
+	return err
+}
+
+func Run(cmd *exec.Cmd, label string) error { return DefaultLogger.Run(cmd, label) }
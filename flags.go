@@ -0,0 +1,174 @@
+package alog
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParseLevel returns the Level named s, as returned by Level.String.
+func ParseLevel(s string) (Level, error) {
+	for level := LevelDebug; level <= LevelError; level++ {
+		if strings.EqualFold(s, level.String()) {
+			return level, nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q", s)
+}
+
+// logFormats are the choices for the -log-format flag, by name. nil means the
+// Logger's usual text, without colors.
+var logFormats = map[string]Encoder{
+	"text":     nil,
+	"json":     JSONEncoder{},
+	"clf":      CLFEncoder{},
+	"combined": CLFEncoder{Combined: true},
+	"gelf":     GELFEncoder{},
+	"logstash": LogstashEncoder{},
+	"msgpack":  MsgpackEncoder{},
+}
+
+// RegisterFlags defines flags on fs that configure the Logger, so that every
+// command built on this package gets the same ones:
+//
+//	-v                 verbose: print debug lines, with their file and line
+//	-q                 quiet: print only warnings and errors
+//	-log-level level   the lowest level to print (debug, info, warn or error)
+//	-no-color          don't print colors
+//	-log-file path     also append each line to the file at path
+//	-log-format name   the format for -log-file (text, json, clf, combined,
+//	                   gelf, logstash or msgpack)
+//	-json-events path  also write events as JSON to the file at path; see
+//	                   SetEventOutput
+//
+// Each takes effect as soon as fs parses it. The files opened for -log-file
+// and -json-events are closed when the Logger is.
+func (l *Logger) RegisterFlags(fs *flag.FlagSet) {
+	file := &logFileFlag{l: l, format: "text"}
+	l.closeWith(file.close)
+	fs.Var(funcFlag{isBool: true, set: func(s string) error {
+		on, err := strconv.ParseBool(s)
+		if err == nil {
+			l.SetVerbose(on)
+		}
+		return err
+	}}, "v", "verbose: print debug lines, with their file and line")
+	fs.Var(funcFlag{isBool: true, set: func(s string) error {
+		on, err := strconv.ParseBool(s)
+		if err == nil {
+			l.SetQuiet(on)
+		}
+		return err
+	}}, "q", "quiet: print only warnings and errors")
+	fs.Var(funcFlag{set: func(s string) error {
+		level, err := ParseLevel(s)
+		if err == nil {
+			l.SetLevel(level)
+		}
+		return err
+	}}, "log-level", "the lowest `level` to print: debug, info, warn or error")
+	fs.Var(funcFlag{isBool: true, set: func(s string) error {
+		off, err := strconv.ParseBool(s)
+		if err == nil {
+			l.SetColorEnabled(!off)
+		}
+		return err
+	}}, "no-color", "don't print colors")
+	fs.Var(funcFlag{set: file.setPath}, "log-file", "also append each line to the file at `path`")
+	fs.Var(funcFlag{set: file.setFormat}, "log-format", "the `format` for -log-file: "+strings.Join(logFormatNames(), " or "))
+	fs.Var(funcFlag{set: func(path string) error {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err == nil {
+			l.SetEventOutput(file)
+			l.closeWith(func() { file.Close() })
+		}
+		return err
+	}}, "json-events", "also write events as JSON to the file at `path`, e.g. /dev/fd/3")
+}
+
+func RegisterFlags(fs *flag.FlagSet) { DefaultLogger.RegisterFlags(fs) }
+
+// closeWith arranges for f to be called once the Logger is closed, e.g. to
+// close a file opened for it.
+func (l *Logger) closeWith(f func()) {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	l.closers = append(l.closers, f)
+}
+
+func logFormatNames() []string {
+	var names []string
+	for name := range logFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// funcFlag is a flag.Value that calls set with each value given.
+type funcFlag struct {
+	isBool bool
+	set    func(string) error
+}
+
+func (f funcFlag) String() string     { return "" }
+func (f funcFlag) Set(s string) error { return f.set(s) }
+func (f funcFlag) IsBoolFlag() bool   { return f.isBool }
+
+// logFileFlag keeps track of -log-file and -log-format, which may be given in
+// either order.
+type logFileFlag struct {
+	l      *Logger
+	file   *os.File
+	format string
+}
+
+func (f *logFileFlag) setPath(path string) error {
+	file, err := openLogFile(path)
+	if err != nil {
+		return err
+	}
+	f.setFile(file)
+	return nil
+}
+
+// openLogFile opens the file at path for appending lines to.
+func openLogFile(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+}
+
+// setFile starts writing to file, closing any file written to before.
+func (f *logFileFlag) setFile(file *os.File) {
+	f.close()
+	f.file = file
+	f.addOutput()
+}
+
+// close stops writing to the file, if any, and closes it.
+func (f *logFileFlag) close() {
+	if f.file != nil {
+		f.l.RemoveOutput(f.file)
+		f.file.Close()
+		f.file = nil
+	}
+}
+
+func (f *logFileFlag) setFormat(format string) error {
+	if _, ok := logFormats[format]; !ok {
+		return fmt.Errorf("unknown log format %q", format)
+	}
+	f.format = format
+	if f.file != nil {
+		f.l.RemoveOutput(f.file)
+		f.addOutput()
+	}
+	return nil
+}
+
+func (f *logFileFlag) addOutput() {
+	f.l.AddOutput(f.file, OutputOptions{StripColor: true, MinLevel: LevelDebug, Encoder: logFormats[f.format]})
+}
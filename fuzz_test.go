@@ -0,0 +1,71 @@
+//go:build go1.18
+// +build go1.18
+
+package alog
+
+import (
+	"bytes"
+	"testing"
+	"unicode/utf8"
+)
+
+func FuzzSGR(f *testing.F) {
+	f.Add([]byte("\033[1;31mred\033[0m"), 3)
+	f.Add([]byte("\033[31"), 1)
+	f.Add([]byte("\033\033[m\xff\xfe"), 2)
+	f.Add([]byte("ᚠᛇ\033[;;mᚻᛒ"), 3)
+	f.Fuzz(func(t *testing.T, buf []byte, length int) {
+		visible := VisibleStringLen(buf)
+		if n := utf8.RuneCount(Uncolorize(buf)); n != visible {
+			t.Fatalf("VisibleStringLen = %d, but %d runes are left after Uncolorize", visible, n)
+		}
+		if length < 0 || length > len(buf) {
+			return
+		}
+		trimmed := trimString(buf, length)
+		if !bytes.HasPrefix(buf, trimmed) {
+			t.Fatalf("trimString(%q, %d) = %q isn't a prefix", buf, length, trimmed)
+		}
+		if n := VisibleStringLen(trimmed); n > length {
+			t.Fatalf("trimString(%q, %d) = %q is %d long", buf, length, trimmed, n)
+		}
+		getActiveAnsiCodes(buf)
+	})
+}
+
+func FuzzPrint(f *testing.F) {
+	f.Add([]byte("hello\rHELLO\nworld"), []byte("\033[31mred\r\033[1mbright\033[0m\n"), 20)
+	f.Add([]byte("ab\xffcd\rx"), []byte("\033[3\033[31\r\033"), 8)
+	f.Add([]byte("\t𐌸𐌸𐌸\r\033[32mᚠ"), []byte("\r\r\n\n"), 10)
+	f.Fuzz(func(t *testing.T, a []byte, b []byte, width int) {
+		if width < 4 || width > 200 {
+			return
+		}
+		var buf bytes.Buffer
+		writer1 := New(&buf, "@(green:1) ", 0)
+		defer writer1.Close()
+		writer1.EnableColorTemplate()
+		writer2 := New(&buf, "2 ", 0)
+		defer writer2.Close()
+		writer1.SetTerminalWidth(width)
+		check := func(l *Logger) {
+			if l.cursorByteIndex < 0 || l.cursorByteIndex > len(l.buf) {
+				t.Fatalf("cursorByteIndex=%d with len(buf)=%d", l.cursorByteIndex, len(l.buf))
+			}
+			ws := getWriterState(&buf)
+			for _, line := range ws.lastTemp {
+				if n := VisibleStringLen(line); n > width-1 {
+					t.Fatalf("status line %q is %d wide on a %d-wide terminal", line, n, width)
+				}
+			}
+		}
+		for i := 0; i < 3; i++ {
+			writer1.PrintBytes(a)
+			check(writer1)
+			writer2.PrintBytes(b)
+			check(writer2)
+			writer1.Replace(string(b))
+			check(writer1)
+		}
+	})
+}
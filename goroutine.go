@@ -0,0 +1,38 @@
+package alog
+
+import (
+	"bytes"
+	"runtime"
+)
+
+// ForGoroutine returns a Logger like l, but with its lines tagged with label in
+// a color of its own, for the output of one goroutine in a pool of workers,
+// e.g.:
+//
+//	for i := 0; i < n; i++ {
+//		go worker(jobs, log.ForGoroutine(fmt.Sprintf("worker %d", i)))
+//	}
+//
+// prints lines like "[worker 3] fetched 12 pages". Each returned Logger has a
+// partial line of its own, so workers don't cut into each other's. If label is
+// empty, the calling goroutine's ID is used, as in "[g42]". Destroy the Logger
+// when the goroutine is done with it.
+func (l *Logger) ForGoroutine(label string) *Logger {
+	if label == "" {
+		label = "g" + goroutineID()
+	}
+	return l.derive(colorize("["+label+"]", autoColor([]byte(label))) + " ")
+}
+
+func ForGoroutine(label string) *Logger { return DefaultLogger.ForGoroutine(label) }
+
+// goroutineID returns the ID of the calling goroutine, as shown in stack traces.
+func goroutineID() string {
+	var buf [64]byte
+	stack := buf[:runtime.Stack(buf[:], false)]
+	stack = bytes.TrimPrefix(stack, []byte("goroutine "))
+	if i := bytes.IndexByte(stack, ' '); i != -1 {
+		return string(stack[:i])
+	}
+	return "?"
+}
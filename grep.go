@@ -0,0 +1,57 @@
+package alog
+
+import (
+	"errors"
+	"regexp"
+)
+
+// Grep prints again each line recorded in the Logger's RingBuffer that matches
+// the regular expression pattern, with the matches highlighted, e.g. to offer
+// a "show me all the errors so far" command. Lines are shown as by DumpRecent,
+// so pattern can match the time and level as well as the text. Grep returns
+// how many lines matched.
+func (l *Logger) Grep(pattern string) (int, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return 0, err
+	}
+	l.drainAsync()
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	if l.ring == nil {
+		return 0, errors.New("no RingBuffer to search; see SetRingBuffer")
+	}
+	matches := 0
+	buf := getLineBuffer()
+	defer putLineBuffer(buf)
+	for _, e := range l.ring.Entries() {
+		*buf = appendEntryRecord((*buf)[:0], &e)
+		indexes := re.FindAllIndex(*buf, -1)
+		if len(indexes) == 0 {
+			continue
+		}
+		matches++
+		if l.isDiscarded() {
+			continue
+		}
+		l.writeFormattedLine(ws, highlightMatches(*buf, indexes), LevelInfo)
+	}
+	updateTempOutput(l.out)
+	ws.writePending(l.out)
+	return matches, nil
+}
+
+func Grep(pattern string) (int, error) { return DefaultLogger.Grep(pattern) }
+
+// highlightMatches returns line with the given ranges of it highlighted.
+func highlightMatches(line []byte, indexes [][]int) []byte {
+	var out []byte
+	last := 0
+	for _, index := range indexes {
+		out = append(out, line[last:index[0]]...)
+		out = append(out, colorize(string(line[index[0]:index[1]]), ColorYellow|ColorBright)...)
+		last = index[1]
+	}
+	return append(out, line[last:]...)
+}
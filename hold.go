@@ -0,0 +1,44 @@
+package alog
+
+// Hold stops everything from being written to the Logger's writer, by any
+// Logger, until Release is called, for code that needs the terminal to itself
+// for a while (to show a menu, say). Partial lines are cleared from the screen
+// first. Output in the meantime is kept and written out by Release, which
+// assumes the cursor has been left at the start of an empty line. Calls nest.
+func (l *Logger) Hold() {
+	l.drainAsync()
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	if ws.held == 0 {
+		for i := range ws.lastTemp {
+			setTempLineOutput(l.out, i, bytesEmpty)
+		}
+		if !moveCursorToLine(l.out, 0) && !ws.cursorIsAtBegin {
+			ws.pending = append(ws.pending, bytesCarriageReturn...)
+			ws.cursorIsAtBegin = true
+			ws.cursorIsInline = false
+		}
+		ws.writePending(l.out)
+	}
+	ws.held++
+}
+
+// Release undoes Hold, writing out everything logged in the meantime and
+// redrawing partial lines.
+func (l *Logger) Release() {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	if ws.held == 0 {
+		return
+	}
+	ws.held--
+	if ws.held == 0 {
+		updateTempOutput(l.out)
+		ws.writePending(l.out)
+	}
+}
+
+func Hold()    { DefaultLogger.Hold() }
+func Release() { DefaultLogger.Release() }
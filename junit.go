@@ -0,0 +1,121 @@
+package alog
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// SetJUnitReport makes the Logger collect the outcome of each Step, and write
+// them as a JUnit XML report to the file at path when WriteJUnitReport is
+// called or the Logger is closed, for CI dashboards that read them. Each step
+// becomes a test case, with its failure and its output, if any. Close returns
+// any error writing the report. An empty path stops collecting.
+func (l *Logger) SetJUnitReport(path string) {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	if path == "" {
+		l.junit = nil
+		return
+	}
+	l.junit = &junitReport{owner: l, path: path, name: filepath.Base(os.Args[0])}
+}
+
+func SetJUnitReport(path string) { DefaultLogger.SetJUnitReport(path) }
+
+// WriteJUnitReport writes the report set up by SetJUnitReport with the steps
+// finished so far, replacing any written before. Programs using the standard
+// logger, which shouldn't be closed, call this once their steps are done.
+func (l *Logger) WriteJUnitReport() error {
+	ws := getWriterState(l.out)
+	ws.lock()
+	r := l.junit
+	ws.unlock()
+	if r == nil {
+		return nil
+	}
+	return r.finish()
+}
+
+func WriteJUnitReport() error { return DefaultLogger.WriteJUnitReport() }
+
+type junitReport struct {
+	owner   *Logger // the Logger that writes the report when closed
+	path    string
+	name    string
+	mutex   sync.Mutex
+	results []StepResult
+}
+
+func (r *junitReport) report(result *StepResult) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.results = append(r.results, *result)
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     float64         `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// finish writes the report, replacing the file atomically.
+func (r *junitReport) finish() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	suite := junitTestSuite{Name: r.name, Tests: len(r.results)}
+	for _, result := range r.results {
+		c := junitTestCase{
+			Name:      result.Name,
+			ClassName: r.name,
+			Time:      result.Duration.Seconds(),
+			SystemOut: result.Output,
+		}
+		switch {
+		case result.Err != nil:
+			suite.Failures++
+			c.Failure = &junitMessage{Message: result.Err.Error(), Text: result.Output}
+			c.SystemOut = ""
+		case result.Skipped != "":
+			suite.Skipped++
+			c.Skipped = &junitMessage{Message: result.Skipped}
+		}
+		suite.Time += c.Time
+		suite.Cases = append(suite.Cases, c)
+	}
+	data, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append([]byte(xml.Header), data...)
+	data = append(data, '\n')
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.path)
+}
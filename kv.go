@@ -0,0 +1,110 @@
+package alog
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Printkv prints msg followed by fields made from kv, which alternates keys
+// and values, e.g.:
+//
+//	log.Printkv("connected", "addr", addr, "retries", n)
+//
+// prints "connected addr=10.0.0.1:5432 retries=3". Values are styled as by
+// PrintTemplate, and quoted if they contain spaces or are empty. A value left
+// without a key is shown with a "?" for one, in red. Color templates in msg
+// are expanded, but not ones in kv.
+func (l *Logger) Printkv(msg string, kv ...interface{}) {
+	l.emitLazy(2, func() string { return formatFields(l.expandFormat(msg, nil), kv) })
+}
+
+func Printkv(msg string, kv ...interface{}) {
+	l := DefaultLogger
+	l.emitLazy(2, func() string { return formatFields(l.expandFormat(msg, nil), kv) })
+}
+
+func formatFields(msg string, kv []interface{}) string {
+	var out bytes.Buffer
+	out.WriteString(strings.TrimSuffix(msg, "\n"))
+	appendFields(&out, kv)
+	out.WriteString("\n")
+	return out.String()
+}
+
+// fprintf formats v by format into msg, as fmt.Fprintf does, except that the
+// arguments left over once format's verbs have been given theirs are appended
+// as fields, as by Printkv, e.g.:
+//
+//	log.Printf("connected\n", "addr", addr, "retries", n)
+//
+// prints "connected addr=10.0.0.1:5432 retries=3". The fields go before the
+// format's newline, if it ends with one. If format picks its arguments by
+// index, with "%[1]d" and the like, there are no fields.
+func (l *Logger) fprintf(msg *bytes.Buffer, format string, v []interface{}) {
+	n := countFormatArgs(format)
+	if n < 0 || n >= len(v) {
+		fmt.Fprintf(msg, l.expandFormat(format, v), v...)
+		return
+	}
+	fmt.Fprintf(msg, l.expandFormat(format, v[:n]), v[:n]...)
+	newline := bytes.HasSuffix(msg.Bytes(), []byte("\n"))
+	if newline {
+		msg.Truncate(msg.Len() - 1)
+	}
+	appendFields(msg, v[n:])
+	if newline {
+		msg.WriteByte('\n')
+	}
+}
+
+// countFormatArgs returns how many arguments the verbs in format use, or -1 if
+// they pick them by index.
+func countFormatArgs(format string) int {
+	n := 0
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		for i++; i < len(format); i++ {
+			c := format[i]
+			if c == '[' {
+				return -1
+			}
+			if c == '*' {
+				n++ // for the width or precision
+			} else if !strings.ContainsRune("+-# 0123456789.", rune(c)) {
+				break
+			}
+		}
+		if i < len(format) && format[i] != '%' {
+			n++
+		}
+	}
+	return n
+}
+
+// appendFields appends the fields made from kv, each after a space.
+func appendFields(out *bytes.Buffer, kv []interface{}) {
+	for i := 0; i < len(kv); i += 2 {
+		out.WriteByte(' ')
+		if i+1 == len(kv) {
+			out.WriteString(colorize("?", ColorRed))
+			out.WriteByte('=')
+			out.WriteString(formatFieldValue(kv[i]))
+			break
+		}
+		out.WriteString(fmt.Sprint(kv[i]))
+		out.WriteByte('=')
+		out.WriteString(formatFieldValue(kv[i+1]))
+	}
+}
+
+func formatFieldValue(value interface{}) string {
+	s := fmt.Sprint(value)
+	if s == "" || strings.ContainsAny(s, " \t\r\n\"=") {
+		s = strconv.Quote(s)
+	}
+	return colorize(s, valueColor(value))
+}
@@ -0,0 +1,50 @@
+package alog
+
+// lineNumWidth is the narrowest line numbers are padded to, so that the text
+// after them stays lined up for a while.
+const lineNumWidth = 4
+
+// nextLineNum counts a finished line for Llinenum, returning its number. Must
+// be called with the writer locked.
+func (l *Logger) nextLineNum(ws *WriterState) int {
+	if l.flag&Llinenumlocal != 0 {
+		l.lineCount++
+		return l.lineCount
+	}
+	ws.lineCount++
+	return ws.lineCount
+}
+
+// peekLineNum returns the number nextLineNum will give the next line, without
+// counting it. Must be called with the writer locked.
+func (l *Logger) peekLineNum(ws *WriterState) int {
+	if l.flag&Llinenumlocal != 0 {
+		return l.lineCount + 1
+	}
+	return ws.lineCount + 1
+}
+
+// appendLineNum appends num, dimmed and right-aligned, or the same width of
+// blank space for a partial line, which doesn't have a number yet.
+func appendLineNum(buf *[]byte, num int) {
+	if num == 0 {
+		for i := 0; i <= lineNumWidth; i++ {
+			*buf = append(*buf, ' ')
+		}
+		return
+	}
+	start := len(*buf)
+	for _, code := range ColorCode(ColorDim).GetAnsiCodes() {
+		*buf = append(*buf, ansiEscapeBytes(code)...)
+	}
+	digits := 1
+	for n := num; n >= 10; n /= 10 {
+		digits++
+	}
+	for ; digits < lineNumWidth; digits++ {
+		*buf = append(*buf, ' ')
+	}
+	itoa(buf, num, -1)
+	*buf = append(*buf, getActiveAnsiCodes((*buf)[start:]).getResetBytes()...)
+	*buf = append(*buf, ' ')
+}
@@ -0,0 +1,42 @@
+package alog
+
+// LineWriterOptions control how a LineWriter logs what's written to it.
+type LineWriterOptions struct {
+	Prefix           string // added after the Logger's own prefix
+	Level            Level  // the level each line is logged at
+	HidePartialLines bool   // don't show unfinished lines as partial lines
+}
+
+// A LineWriter is an io.Writer that logs whatever is written to it through a
+// Logger, a line at a time, e.g. for a decompressed log file or the frames
+// read from a connection. Lines may be split across writes any which way; an
+// unfinished line is shown as a partial line of its own, so it doesn't run
+// into the Logger's. Close the LineWriter when done with it.
+type LineWriter struct {
+	l     *Logger
+	level Level
+}
+
+// NewLineWriter returns a LineWriter that logs through l.
+func NewLineWriter(l *Logger, opts LineWriterOptions) *LineWriter {
+	w := &LineWriter{l: l.derive(opts.Prefix), level: opts.Level}
+	if opts.HidePartialLines {
+		w.l.HidePartialLines()
+	}
+	return w
+}
+
+func (w *LineWriter) Write(p []byte) (int, error) {
+	if w.l.isDiscarded() {
+		return len(p), nil
+	}
+	e := w.l.newEntry(2)
+	e.level = w.level
+	return len(p), w.l.emit(e, p, false)
+}
+
+// Close logs the unfinished line, if there is one.
+func (w *LineWriter) Close() error {
+	w.l.Destroy()
+	return nil
+}
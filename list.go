@@ -0,0 +1,57 @@
+package alog
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// List prints items as an indented, bulleted list, e.g. for the next steps at
+// the end of a command. Color templates in items are expanded, and items too
+// long for the terminal are wrapped with a hanging indent. If styles are
+// given, styles[i] colors the whole of items[i], bullet included.
+func (l *Logger) List(items []string, styles ...ColorCode) {
+	if l.isDiscarded() {
+		return
+	}
+	bullet := "• "
+	if l.glyphs() == &asciiGlyphs {
+		bullet = "- "
+	}
+	l.printList(l.newEntry(2), items, styles, func(int) string { return bullet })
+}
+
+// NumberedList is List, but numbers the items instead.
+func (l *Logger) NumberedList(items []string, styles ...ColorCode) {
+	if l.isDiscarded() {
+		return
+	}
+	format := "%" + strconv.Itoa(len(strconv.Itoa(len(items)))) + "d. "
+	l.printList(l.newEntry(2), items, styles, func(i int) string { return fmt.Sprintf(format, i+1) })
+}
+
+func List(items []string, styles ...ColorCode)         { DefaultLogger.List(items, styles...) }
+func NumberedList(items []string, styles ...ColorCode) { DefaultLogger.NumberedList(items, styles...) }
+
+const listIndent = "  "
+
+func (l *Logger) printList(e entry, items []string, styles []ColorCode, marker func(int) string) {
+	width := l.lineWidth(&e)
+	var out strings.Builder
+	for i, item := range items {
+		mark := marker(i)
+		indent := strings.Repeat(" ", len(listIndent)+VisibleStringLen([]byte(mark)))
+		for j, line := range wrapLine(l.Colorify(item), width-len(indent)) {
+			if j == 0 {
+				line = listIndent + mark + line
+			} else {
+				line = indent + line
+			}
+			if i < len(styles) {
+				line = colorize(line, styles[i])
+			}
+			out.WriteString(line + "\n")
+		}
+	}
+	l.emit(e, []byte(out.String()), false)
+}
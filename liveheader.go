@@ -0,0 +1,73 @@
+package alog
+
+import "time"
+
+// liveHeaderInterval is how often partial lines are redrawn to keep live
+// headers up to date.
+const liveHeaderInterval = time.Second
+
+// SetLiveHeaderEnabled sets whether partial lines show the current time in
+// their headers, rather than the time they were last written to. While
+// enabled on a Logger, partial lines on its writer are redrawn every second
+// as well, so that a timestamp or {elapsed} field in the header keeps
+// counting even when nothing is being written.
+func (l *Logger) SetLiveHeaderEnabled(flag bool) {
+	scheduler := l.getScheduler()
+	ws := getWriterState(l.out)
+	ws.lock()
+	l.liveHeader = boolPointer(flag)
+	var stop func()
+	if flag && l.liveHeaderCancel == nil {
+		l.liveHeaderCancel = scheduler.Every(liveHeaderInterval, l.refreshLiveHeaders)
+	} else if !flag {
+		stop = l.stopLiveHeaders()
+	}
+	ws.unlock()
+	if stop != nil {
+		stop()
+	}
+}
+func (l *Logger) EnableLiveHeader()  { l.SetLiveHeaderEnabled(true) }
+func (l *Logger) DisableLiveHeader() { l.SetLiveHeaderEnabled(false) }
+
+func SetLiveHeaderEnabled(flag bool) { DefaultLogger.SetLiveHeaderEnabled(flag) }
+func EnableLiveHeader()              { DefaultLogger.SetLiveHeaderEnabled(true) }
+func DisableLiveHeader()             { DefaultLogger.SetLiveHeaderEnabled(false) }
+
+func (l *Logger) isLiveHeaderEnabled() bool {
+	return isTrueDefaulted(l.liveHeader, l.defaults().liveHeader)
+}
+
+// stopLiveHeaders stops the redraws started by SetLiveHeaderEnabled. Must be
+// called with the writer locked, and the func it returns, which waits for a
+// redraw under way, called once it's unlocked.
+func (l *Logger) stopLiveHeaders() (wait func()) {
+	cancel := l.liveHeaderCancel
+	l.liveHeaderCancel = nil
+	if cancel == nil {
+		return func() {}
+	}
+	return cancel
+}
+
+func (l *Logger) refreshLiveHeaders() {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	if len(ws.tempLoggers) != 0 {
+		updateTempOutput(l.out)
+		ws.writePending(l.out)
+	}
+}
+
+// tempEntry returns the entry to format the Logger's partial line with.
+func (l *Logger) tempEntry() entry {
+	e := l.lastEntry
+	if l.isLiveHeaderEnabled() {
+		e.now = l.now()
+		if l.flag&LUTC != 0 {
+			e.now = e.now.UTC()
+		}
+	}
+	return e
+}
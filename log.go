@@ -32,6 +32,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unicode/utf8"
 )
@@ -55,7 +56,10 @@ const (
 	LUTC                      // if Ldate or Ltime is set, use UTC rather than the local time zone
 	Lelapsed                  // elapsed time since this line was first started
 	Lisodate
-	LstdFlags = Ldate | Ltime // initial values for the standard logger
+	Llevel                        // the level of the line, as a badge: [WARN ]
+	Llinenum                      // number each finished line, counting every Logger's lines on the writer
+	Llinenumlocal                 // with Llinenum, count only the Logger's own lines instead
+	LstdFlags     = Ldate | Ltime // initial values for the standard logger
 )
 
 type ColorCode int
@@ -119,10 +123,16 @@ var ansiColorCodes = map[string]ColorCode{
 	"warn":    ColorYellow,
 }
 
+// Output is locked per writer, so anything shared between writers (like these
+// lookup tables) needs its own synchronization.
 var tputCache = make(map[string]string)
+var tputMutex sync.Mutex
+var ansiColorCodesMutex sync.RWMutex
 
 func tput(strs ...string) string {
 	key := strings.Join(strs, "-")
+	tputMutex.Lock()
+	defer tputMutex.Unlock()
 	val, ok := tputCache[key]
 	if !ok {
 		cmd := exec.Command("tput", strs...)
@@ -140,6 +150,7 @@ func tput(strs ...string) string {
 
 type WriterState struct {
 	mutex           sync.Mutex
+	writeMutex      sync.Mutex // held while writing; see writePendingAndUnlock
 	lastTemp        [][]byte
 	tempLoggers     []*Logger
 	termWidth       int
@@ -147,6 +158,34 @@ type WriterState struct {
 	cursorLineIndex int
 	cursorIsInline  bool
 	cursorIsAtBegin bool
+	numLoggers      int
+	pending         []byte // output queued by the current update
+	lastStatus      []byte // the status last composed by updateTempOutput,
+	lastStatusWidth int    // the width it was fitted to,
+	lastStatusOut   []byte // and the resulting line
+	terminal        terminalKey
+	onTerminal      bool // if so, other writers to the same terminal share this state
+	held            int  // while nonzero, pending output is kept rather than written
+	narrowMode      NarrowMode
+	mirrors         []io.Writer // also get everything written; see MirrorTo
+	lastActivity    time.Time   // when any Logger last wrote anything
+	background      *backgroundWriter
+	tempDeferred    bool // a partial line update was put off; see SetBackgroundWritesEnabled
+	lineCount       int  // finished lines numbered by Llinenum
+	linesWritten    int  // all finished lines, for bookmarks and WriterDebugInfo
+	bookmarks       []Bookmark
+	ciMode          CIMode
+	ciGroups        int // how many Groups are open
+	tempUpdates     int // for WriterDebugInfo
+	tempRedraws     int
+	tempAppends     int
+	buffered        *BufferedWriter // shared by every Logger here; see SetBuffered
+}
+
+// terminalKey identifies a terminal device.
+type terminalKey struct {
+	dev uint64
+	ino uint64
 }
 
 func (w *WriterState) removeTempLogger(l *Logger) {
@@ -163,6 +202,54 @@ func (w *WriterState) removeTempLogger(l *Logger) {
 	}
 }
 
+// writePending writes everything queued up by the current update in a single
+// call, so that each update reaches the terminal (or another process sharing
+// it) in one piece.
+func (w *WriterState) writePending(out io.Writer) error {
+	if len(w.pending) == 0 || w.held != 0 {
+		return nil
+	}
+	var err error
+	if w.background != nil {
+		w.background.queue(w, w.dest(out), w.pending)
+		w.writeMirrors(w.pending)
+	} else {
+		w.writeMutex.Lock()
+		_, err = w.dest(out).Write(w.pending)
+		w.writeMirrors(w.pending)
+		w.writeMutex.Unlock()
+	}
+	w.pending = w.pending[:0]
+	return err
+}
+
+// writePendingAndUnlock is writePending, but unlocks the writer before the
+// write itself, so that nobody waits on a slow writer but the caller. Writes
+// still happen in the order they were rendered, as writeMutex is taken before
+// the writer is unlocked. Must be called with the writer locked, which it
+// won't be on return.
+func (w *WriterState) writePendingAndUnlock(out io.Writer) error {
+	if len(w.pending) == 0 || w.held != 0 || w.background != nil {
+		err := w.writePending(out)
+		w.unlock()
+		return err
+	}
+	data := w.pending
+	w.pending = nil // it's ours now
+	w.writeMutex.Lock()
+	w.unlock()
+	defer w.writeMutex.Unlock()
+	_, err := w.dest(out).Write(data)
+	w.writeMirrors(data)
+	return err
+}
+
+func (w *WriterState) writeMirrors(p []byte) {
+	for _, mirror := range w.mirrors {
+		mirror.Write(p)
+	}
+}
+
 func (w *WriterState) lock()   { w.mutex.Lock() }
 func (w *WriterState) unlock() { w.mutex.Unlock() }
 
@@ -191,10 +278,7 @@ func getWriterState(writer io.Writer) *WriterState {
 		mutexGlobal.Lock()
 		ws, ok = writers[writer]
 		if !ok {
-			ws = &WriterState{}
-			ws.cursorIsAtBegin = true
-			ws.cursorIsInline = false
-			ws.lastTemp = [][]byte{[]byte{}}
+			ws = newWriterState(writer)
 			writers[writer] = ws
 		}
 		mutexGlobal.Unlock()
@@ -202,7 +286,92 @@ func getWriterState(writer io.Writer) *WriterState {
 	return ws
 }
 
-// ensures atomic writes; shared by all Logger instances
+// newWriterState returns the state for a writer not yet in the writers map.
+// Writers to the same terminal (typically os.Stdout and os.Stderr) share one
+// state, so that their partial lines are drawn together instead of over each
+// other. Must be called with mutexGlobal held.
+func newWriterState(writer io.Writer) *WriterState {
+	terminal, onTerminal := terminalID(writer)
+	if onTerminal {
+		for _, ws := range writers {
+			if ws.onTerminal && ws.terminal == terminal {
+				return ws
+			}
+		}
+	}
+	ws := &WriterState{terminal: terminal, onTerminal: onTerminal}
+	ws.cursorIsAtBegin = true
+	ws.cursorIsInline = false
+	ws.lastTemp = [][]byte{[]byte{}}
+	return ws
+}
+
+// deleteWriterState removes every entry for ws from the writers map. Must be
+// called with mutexGlobal held.
+func deleteWriterState(ws *WriterState) {
+	for writer, other := range writers {
+		if other == ws {
+			delete(writers, writer)
+		}
+	}
+}
+
+// attachWriterState counts l as a user of its writer's state, so that the state
+// can be dropped once the last Logger writing there is destroyed.
+func (l *Logger) attachWriterState() {
+	ws := getWriterState(l.out)
+	ws.lock()
+	ws.numLoggers++
+	ws.unlock()
+	l.attached = true
+}
+
+func (l *Logger) detachWriterState() {
+	if !l.attached {
+		return
+	}
+	l.attached = false
+	mutexGlobal.RLock()
+	ws, ok := writers[l.out]
+	mutexGlobal.RUnlock()
+	if !ok {
+		return
+	}
+	// The writer's lock comes first, as on every other path that takes both
+	ws.lock()
+	defer ws.unlock()
+	ws.numLoggers--
+	if ws.numLoggers <= 0 && len(ws.tempLoggers) == 0 {
+		mutexGlobal.Lock()
+		deleteWriterState(ws)
+		mutexGlobal.Unlock()
+	}
+}
+
+// ReleaseWriter flushes any partial lines pending on w and discards the state
+// kept for it (cursor position, terminal width, multiline mode). Loggers that
+// write to w again afterward start over with fresh state. This is only needed
+// for writers that are abandoned without Destroying the Loggers using them.
+func ReleaseWriter(w io.Writer) {
+	mutexGlobal.RLock()
+	ws, ok := writers[w]
+	mutexGlobal.RUnlock()
+	if !ok {
+		return
+	}
+	ws.lock()
+	ws.flushAll()
+	ws.waitBackground(w)
+	ws.flushBuffered(w)
+	ws.unlock()
+	mutexGlobal.Lock()
+	delete(writers, w)
+	mutexGlobal.Unlock()
+}
+
+// Guards the writers map only. Each WriterState has its own mutex, so Loggers
+// only contend with other Loggers that share their writer. When both are
+// needed, the WriterState's mutex must be taken first.
 var mutexGlobal sync.RWMutex
 
 var writers map[io.Writer]*WriterState = make(map[io.Writer]*WriterState)
@@ -215,11 +384,10 @@ var bytesEmpty = []byte("")
 var bytesCarriageReturn = []byte("\r")
 var byteNewline = byte('\n')
 var bytesNewline = []byte{byteNewline}
-var bytesSpace = []byte(" ")
+var bytesTab = []byte("\t")
+var bytesTabSpaces = []byte("        ")
 
 var bytesComma = []byte(",")
-var ansiColorRegexp = regexp.MustCompile("\033\\[(\\d+(?:;\\d+)*)m")
-var ansiColorOrCharRegexp = regexp.MustCompile("(\033\\[\\d+m)|.")
 var ansiBytesEscapeStart = []byte("\033[")
 var ansiBytesColorEscapeEnd = []byte("m")
 var ansiBytesResetAll = []byte("\033[0m")
@@ -275,12 +443,32 @@ func (codes *ActiveAnsiCodes) getResetBytes() []byte {
 	return bytesEmpty
 }
 
+// appendSetBytes appends the escapes needed to get from no styling to codes.
+func (codes *ActiveAnsiCodes) appendSetBytes(buf []byte) []byte {
+	if codes.intensity != 0 {
+		buf = append(buf, ansiEscapeBytes(codes.intensity)...)
+	}
+	if codes.forecolor != 0 {
+		buf = append(buf, ansiEscapeBytes(codes.forecolor)...)
+	}
+	return buf
+}
+
+// addFrom applies every escape in buf, in order.
+func (codes *ActiveAnsiCodes) addFrom(buf []byte) {
+	if !hasEscapes(buf) {
+		return
+	}
+	index, length := nextSGR(buf, 0)
+	for index != -1 {
+		codes.addSGRParams(buf[index : index+length])
+		index, length = nextSGR(buf, index+length)
+	}
+}
+
 func getActiveAnsiCodes(buf []byte) *ActiveAnsiCodes {
 	var ansiActive ActiveAnsiCodes
-	for _, groups := range ansiColorRegexp.FindAllSubmatch(buf, -1) {
-		code, _ := strconv.ParseInt(string(groups[1]), 10, 32)
-		ansiActive.add(int(code))
-	}
+	ansiActive.addFrom(buf)
 	return &ansiActive
 }
 
@@ -289,33 +477,98 @@ func getActiveAnsiCodes(buf []byte) *ActiveAnsiCodes {
 // the Writer's Write method.  A Logger can be used simultaneously from
 // multiple goroutines; it guarantees to serialize access to the Writer.
 type Logger struct {
-	prefix               []byte    // prefix to write at beginning of each line
-	flag                 int       // properties
-	out                  io.Writer // destination for output
-	buf                  []byte    // for accumulating text to write
-	tmp                  []byte    // for formatting the current line
-	prefixFormatted      []byte
-	cursorByteIndex      int
-	tempLineActive       bool
-	isClosed             bool
-	partialLinesEnabled  *bool
-	colorEnabled         *bool
-	colorTemplateEnabled *bool
-	autoAppendNewline    *bool
-	colorRegexp          *regexp.Regexp
-	termWidth            int
-	callerFile           string
-	callerLine           int
-	now                  time.Time
-	lineStartTime        time.Time
+	prefix                  []byte    // prefix to write at beginning of each line
+	flag                    int       // properties
+	callDepthOffset         int       // extra frames to skip when finding the caller
+	out                     io.Writer // destination for output
+	buf                     []byte    // for accumulating text to write
+	prefixFormatted         []byte
+	prefixParts             []prefixPart
+	prefixWidth             int
+	cursorByteIndex         int
+	tempLineActive          bool
+	centerStatus            []byte // shown centered on the partial line; see SetCenterStatus
+	rightStatus             []byte // and right-aligned
+	isClosed                bool
+	async                   *asyncQueue
+	maxPartialLineLength    int
+	maxLineLength           int
+	linePolicy              LinePolicy
+	carryColors             *bool
+	carriedCodes            ActiveAnsiCodes // styling left active by the lines written so far
+	attached                bool
+	level                   Level    // lines below this are dropped
+	outputs                 []output // additional destinations for finished lines
+	errOut                  io.Writer
+	errLevel                Level
+	errOutShared            bool // errOut is the same terminal as out
+	ring                    *RingBuffer
+	dumpOnError             bool
+	crashReportDir          string
+	discard                 bool
+	nowFunc                 func() time.Time
+	translator              Translator
+	badgeTheme              *BadgeTheme
+	timerMediumTime         time.Duration
+	timerLongTime           time.Duration
+	slowWarnAfter           time.Duration
+	slowErrorAfter          time.Duration
+	partialLinesEnabled     *bool
+	quiet                   bool
+	partialLinesBeforeQuiet *bool
+	levelBeforeQuiet        Level
+	verbose                 bool
+	verboseOverQuiet        bool // verbose mode was turned on while quiet
+	levelBeforeVerbose      Level
+	verboseAddedFlags       int
+	colorEnabled            *bool
+	colorTemplateEnabled    *bool
+	unicodeEnabled          *bool
+	autoColor               *bool
+	monotonicTiming         *bool
+	ansiDebug               *bool
+	strictTemplates         *bool
+	autoAppendNewline       *bool
+	colorRegexp             *regexp.Regexp
+	termWidth               int
+	lastEntry               entry // the most recent call, used to render the partial line
+	lineStartTime           time.Time
+	tempLine                tempLineCache // see formatTempLine
+	liveHeader              *bool
+	liveHeaderCancel        func() // stops redrawing live headers
+	lineCount               int    // finished lines numbered by Llinenum with Llinenumlocal
+	scheduler               Scheduler
+	tap                     *tapWriter   // see SetTAPOutput
+	junit                   *junitReport // see SetJUnitReport
+	events                  *eventWriter // see SetEventOutput
+	closers                 []func()     // called when the Logger is closed; see closeWith
+	standalone              bool         // see NewStandalone
+	seenKeys                *sync.Map    // for Oncef and the like; nil for onceKeys
+	config                  atomic.Value // the current *configSnapshot
+}
+
+// entry holds the state belonging to a single call to Output, so that
+// concurrent calls on one Logger never see each other's timestamps or callers.
+type entry struct {
+	now        time.Time
+	callerFile string
+	callerLine int
+	level      Level
+	lineNum    int           // for Llinenum; zero for partial lines
+	access     *AccessRecord // for lines logged by AccessLog
 }
 
 type LoggerInt interface {
 	Printf(string, ...interface{})
 	Print(...interface{})
+	PrintBytes([]byte)
 	Replacef(string, ...interface{})
 	Replace(...interface{})
 	Println(...interface{})
+	Debugf(string, ...interface{})
+	Infof(string, ...interface{})
+	Warnf(string, ...interface{})
+	Error(string, ...interface{})
 	Fatal(...interface{})
 	Fatalf(string, ...interface{})
 	Fatalln(...interface{})
@@ -326,6 +579,18 @@ type LoggerInt interface {
 	BailIf(error)
 	Flags() int
 	SetFlags(int)
+	SetCallDepthOffset(int)
+	SetNowFunc(func() time.Time)
+	SetLevel(Level)
+	AddOutput(io.Writer, OutputOptions)
+	RemoveOutput(io.Writer)
+	SetErrorOutput(io.Writer, Level)
+	SetRingBuffer(*RingBuffer, bool)
+	DumpRecent(io.Writer) error
+	SetCrashReportDir(string)
+	Timer(string) func()
+	SetTimerThresholds(time.Duration, time.Duration)
+	SetSlowThresholds(time.Duration, time.Duration)
 	Prefix() string
 	SetPrefix(string)
 	Write([]byte) (int, error)
@@ -334,6 +599,7 @@ type LoggerInt interface {
 	closeInt()
 	Flush()
 	Close() error
+	Destroy()
 	SetPartialLinesEnabled(bool)
 	ShowPartialLines()
 	HidePartialLines()
@@ -346,6 +612,9 @@ type LoggerInt interface {
 	SetAutoNewlines(bool)
 	EnableAutoNewlines()
 	DisableAutoNewlines()
+	SetCarryColorsEnabled(bool)
+	EnableCarryColors()
+	DisableCarryColors()
 	SetColorTemplateRegexp(*regexp.Regexp)
 	SetTerminalWidth(int)
 	SetMultilineEnabled(bool)
@@ -360,24 +629,162 @@ type LoggerInt interface {
 func New(out io.Writer, prefix string, flag int) *Logger {
 	var l = &Logger{out: out, prefix: []byte(prefix), flag: flag}
 	l.reprocessPrefix()
+	l.attachWriterState()
 	return l
 }
 
+// NewStandalone is like New, but the Logger doesn't fall back to the settings
+// of the standard logger (DefaultLogger) for those not set on it, so that a
+// library can log without being affected by how the program configures the
+// standard logger, or affecting it. It starts out with the settings the
+// standard logger starts out with instead. Loggers derived from it, e.g. for
+// Steps, are standalone too, and share with it, rather than with the rest of
+// the program, what Oncef, Deprecated and the warnings about unknown color
+// template codes have already warned about.
+//
+// What a standalone Logger still shares with others:
+//
+//   - The state of its writer (partial lines, cursor, terminal width,
+//     multiline and CI modes, line numbers and bookmarks), with every Logger
+//     writing to the same writer, so that their lines don't garble each
+//     other's. Given a writer of its own, it shares nothing. Note that
+//     DefaultLogger writes to os.Stderr, and detects the CI mode for it when
+//     the package is loaded.
+//   - DisableAll, which mutes every Logger.
+func NewStandalone(out io.Writer, prefix string, flag int) *Logger {
+	var l = &Logger{out: out, prefix: []byte(prefix), flag: flag, standalone: true, seenKeys: &sync.Map{}}
+	l.reprocessPrefix()
+	l.attachWriterState()
+	return l
+}
+
+// derive returns a new Logger writing to the same writer as l, with all of l's
+// settings, extraPrefix added to the end of its prefix, and a partial line of
+// its own.
+func (l *Logger) derive(extraPrefix string) *Logger {
+	ws := getWriterState(l.out)
+	ws.lock()
+	d := &Logger{}
+	*d = *l
+	d.resetInstanceState()
+	d.prefix = append(append([]byte{}, l.prefix...), extraPrefix...)
+	d.outputs = append([]output(nil), l.outputs...)
+	d.reprocessPrefix()
+	ws.unlock()
+	d.attachWriterState()
+	return d
+}
+
+// resetInstanceState clears everything a Logger made by copying another one
+// mustn't share with it: its partial line and the state of its own output, as
+// opposed to its settings. Quiet and verbose mode and Discard aren't carried
+// over either; Clone copies those itself.
+func (l *Logger) resetInstanceState() {
+	l.buf = nil
+	l.cursorByteIndex = 0
+	l.tempLineActive = false
+	l.centerStatus = nil
+	l.rightStatus = nil
+	l.isClosed = false
+	l.async = nil
+	l.carriedCodes = ActiveAnsiCodes{}
+	l.attached = false
+	l.quiet = false
+	l.partialLinesBeforeQuiet = nil
+	l.levelBeforeQuiet = 0
+	l.verbose = false
+	l.verboseOverQuiet = false
+	l.levelBeforeVerbose = 0
+	l.verboseAddedFlags = 0
+	l.discard = false
+	l.lastEntry = entry{}
+	l.lineStartTime = time.Time{}
+	l.liveHeaderCancel = nil
+	l.lineCount = 0
+	l.tempLine = tempLineCache{}
+	l.closers = nil
+	l.config = atomic.Value{}
+}
+
+// Clone returns a new Logger with all of l's settings, including its prefix,
+// flags, level, theme and additional outputs, but with a partial line and
+// other state of its own, e.g. to make one Logger per job from one set up as
+// a template. If l keeps a RingBuffer or writes asynchronously, the clone gets
+// a RingBuffer or queue of its own, of the same size.
+func (l *Logger) Clone() *Logger {
+	c := l.derive("")
+	ws := getWriterState(l.out)
+	ws.lock()
+	c.quiet = l.quiet
+	c.partialLinesBeforeQuiet = l.partialLinesBeforeQuiet
+	c.levelBeforeQuiet = l.levelBeforeQuiet
+	c.verbose = l.verbose
+	c.verboseOverQuiet = l.verboseOverQuiet
+	c.levelBeforeVerbose = l.levelBeforeVerbose
+	c.verboseAddedFlags = l.verboseAddedFlags
+	c.discard = l.discard
+	if l.ring != nil {
+		c.ring = NewRingBuffer(len(l.ring.entries))
+	}
+	q := l.async
+	ws.unlock()
+	if q != nil {
+		c.SetAsync(cap(q.messages), q.policy)
+	}
+	return c
+}
+
+func Clone() *Logger { return DefaultLogger.Clone() }
+
 // newStd duplicates some of the work done by New because we can't call
 // reprocessPrefix here (as it creates a circular reference back to DefaultLogger)
 func newStd() *Logger {
 	var l = &Logger{out: os.Stderr, prefix: []byte("@(dim:{isodate}) "), flag: 0}
+	l.setBuiltinDefaults()
+	// This is like calling reprocessPrefix:
+	l.prefixFormatted = processColorTemplates(l.colorRegexp, l.prefix)
+	l.prefixParts = parsePrefix(l.prefixFormatted)
+	l.attachWriterState()
+	getWriterState(l.out).ciMode = DetectCI()
+	return l
+}
+
+var DefaultLogger = newStd()
+
+// setBuiltinDefaults gives l the settings the standard logger starts out with.
+func (l *Logger) setBuiltinDefaults() {
 	l.partialLinesEnabled = &yes
 	l.colorRegexp = regexp.MustCompile("@\\(([\\w,]+?)(:([^)]*?))?\\)")
 	l.colorEnabled = &yes
 	l.colorTemplateEnabled = &yes
+	l.unicodeEnabled = boolPointer(localeIsUTF8())
 	l.autoAppendNewline = &no
-	// This is like calling reprocessPrefix:
-	l.prefixFormatted = processColorTemplates(l.colorRegexp, l.prefix)
+	l.carryColors = &no
+	l.autoColor = &no
+	l.monotonicTiming = &yes
+	l.liveHeader = &no
+	l.scheduler = tickerScheduler{}
+	l.ansiDebug = &no
+	l.strictTemplates = &no
+}
+
+// builtinDefaults holds the settings that Loggers made with NewStandalone fall
+// back to, which are those the standard logger starts out with, and which never
+// change.
+var builtinDefaults = func() *Logger {
+	l := &Logger{}
+	l.setBuiltinDefaults()
 	return l
-}
+}()
 
-var DefaultLogger = newStd()
+// defaults returns the Logger whose settings l falls back to for those not set
+// on it.
+func (l *Logger) defaults() *Logger {
+	if l.standalone {
+		return builtinDefaults
+	}
+	return DefaultLogger
+}
 
 func isTrueDefaulted(flag *bool, fallback *bool) bool {
 	if flag != nil {
@@ -387,36 +794,73 @@ func isTrueDefaulted(flag *bool, fallback *bool) bool {
 }
 
 func (l *Logger) isColorEnabled() bool {
-	return isTrueDefaulted(l.colorEnabled, DefaultLogger.colorEnabled)
+	return isTrueDefaulted(l.colorEnabled, l.defaults().colorEnabled)
 }
 
 func (l *Logger) isPartialLinesEnabled() bool {
-	return isTrueDefaulted(l.partialLinesEnabled, DefaultLogger.partialLinesEnabled)
+	return isTrueDefaulted(l.partialLinesEnabled, l.defaults().partialLinesEnabled)
 }
 
 func (l *Logger) isAutoNewlineEnabled() bool {
-	return isTrueDefaulted(l.autoAppendNewline, DefaultLogger.autoAppendNewline)
+	return isTrueDefaulted(l.autoAppendNewline, l.defaults().autoAppendNewline)
+}
+
+func (l *Logger) now() time.Time {
+	if l.nowFunc != nil {
+		return l.nowFunc()
+	}
+	if now := l.defaults().nowFunc; now != nil {
+		return now()
+	}
+	return time.Now()
+}
+
+func (l *Logger) isCarryColorsEnabled() bool {
+	return isTrueDefaulted(l.carryColors, l.defaults().carryColors)
 }
 
 func (l *Logger) getColorTemplateRegexp() *regexp.Regexp {
-	if !isTrueDefaulted(l.colorTemplateEnabled, DefaultLogger.colorTemplateEnabled) {
+	if !isTrueDefaulted(l.colorTemplateEnabled, l.defaults().colorTemplateEnabled) {
 		return nil
 	}
 	if l.colorRegexp != nil {
 		return l.colorRegexp
 	}
-	return DefaultLogger.colorRegexp
+	return l.defaults().colorRegexp
 }
 
 // SetOutput sets the output destination for the logger.
 func (l *Logger) SetOutput(w io.Writer) {
 	// This is all not really threadsafe. Calling SetOutput while simultaneously writing
 	// data will result in undefined behavior.
+	l.drainAsync()
+	// Take the partial line (if any) off the old writer's status line...
 	ws := getWriterState(l.out)
 	ws.lock()
-	defer ws.unlock()
-	l.flushInt()
+	if l.tempLineActive {
+		ws.removeTempLogger(l)
+		l.tempLineActive = false
+		updateTempOutput(l.out)
+		ws.writePending(l.out)
+	}
+	ws.unlock()
+	wasAttached := l.attached
+	l.detachWriterState()
 	l.out = w
+	l.updateErrOutShared()
+	if wasAttached {
+		l.attachWriterState()
+	}
+	// ... and carry on with it on the new one.
+	ws = getWriterState(w)
+	ws.lock()
+	defer ws.unlock()
+	if l.isPartialLinesEnabled() && VisibleStringLen(l.buf) > 0 {
+		ws.addTempLogger(l)
+		l.tempLineActive = true
+		updateTempOutput(l.out)
+		ws.writePending(l.out)
+	}
 }
 
 // Cheap integer to fixed-width decimal ASCII.  Give a negative width to avoid zero-padding.
@@ -436,12 +880,12 @@ func itoa(buf *[]byte, i int, wid int) {
 	*buf = append(*buf, b[bp:]...)
 }
 
-func (l *Logger) appendDate(buf *[]byte, useIsoDate bool) {
+func (e *entry) appendDate(buf *[]byte, useIsoDate bool) {
 	dateSep := "/"
 	if useIsoDate {
 		dateSep = "-"
 	}
-	year, month, day := l.now.Date()
+	year, month, day := e.now.Date()
 	itoa(buf, year, 4)
 	*buf = append(*buf, dateSep...)
 	itoa(buf, int(month), 2)
@@ -449,8 +893,8 @@ func (l *Logger) appendDate(buf *[]byte, useIsoDate bool) {
 	itoa(buf, day, 2)
 }
 
-func (l *Logger) appendTime(buf *[]byte, includeMicros bool) {
-	hour, min, sec := l.now.Clock()
+func (e *entry) appendTime(buf *[]byte, includeMicros bool) {
+	hour, min, sec := e.now.Clock()
 	itoa(buf, hour, 2)
 	*buf = append(*buf, ':')
 	itoa(buf, min, 2)
@@ -458,19 +902,19 @@ func (l *Logger) appendTime(buf *[]byte, includeMicros bool) {
 	itoa(buf, sec, 2)
 	if includeMicros {
 		*buf = append(*buf, '.')
-		itoa(buf, l.now.Nanosecond()/1e3, 6)
+		itoa(buf, e.now.Nanosecond()/1e3, 6)
 	}
 }
 
-func (l *Logger) appendIsoDate(buf *[]byte, includeMicros bool) {
-	l.appendDate(buf, true)
+func (e *entry) appendIsoDate(buf *[]byte, includeMicros bool) {
+	e.appendDate(buf, true)
 	*buf = append(*buf, 'T')
-	l.appendTime(buf, includeMicros)
+	e.appendTime(buf, includeMicros)
 }
 
-func (l *Logger) appendElapsed(buf *[]byte) {
-	if !l.lineStartTime.IsZero() && l.now != l.lineStartTime {
-		*buf = append(*buf, FormatDuration(l.now.Sub(l.lineStartTime))...)
+func (l *Logger) appendElapsed(buf *[]byte, e *entry) {
+	if !l.lineStartTime.IsZero() && e.now != l.lineStartTime {
+		*buf = append(*buf, FormatDuration(l.between(l.lineStartTime, e.now))...)
 	} else {
 		*buf = append(*buf, '-')
 	}
@@ -478,68 +922,104 @@ func (l *Logger) appendElapsed(buf *[]byte) {
 
 var prefixTemplateRegexp = regexp.MustCompile("{(date|time|isodate|elapsed)( micros)?}|.+?")
 
-func (l *Logger) formatHeader(buf *[]byte) {
-	for _, groups := range prefixTemplateRegexp.FindAllSubmatch(l.prefixFormatted, -1) {
+// A prefixPart is either literal text or one of the {date}-style fields.
+type prefixPart struct {
+	text          []byte
+	field         string
+	includeMicros bool
+}
+
+// parsePrefix splits up a (color-processed) prefix once, rather than on every
+// line.
+func parsePrefix(prefix []byte) []prefixPart {
+	var parts []prefixPart
+	for _, groups := range prefixTemplateRegexp.FindAllSubmatch(prefix, -1) {
 		if len(groups[1]) != 0 {
-			s := string(groups[1])
-			includeMicros := len(groups[2]) > 0
-			if s == "date" {
-				l.appendDate(buf, false)
-			} else if s == "time" {
-				l.appendTime(buf, includeMicros)
-			} else if s == "isodate" {
-				l.appendIsoDate(buf, includeMicros)
-			} else if s == "elapsed" {
-				l.appendElapsed(buf)
-			}
+			parts = append(parts, prefixPart{field: string(groups[1]), includeMicros: len(groups[2]) > 0})
+		} else if n := len(parts); n > 0 && parts[n-1].field == "" {
+			parts[n-1].text = append(parts[n-1].text, groups[0]...)
 		} else {
-			*buf = append(*buf, groups[0]...)
+			parts = append(parts, prefixPart{text: append([]byte{}, groups[0]...)})
+		}
+	}
+	return parts
+}
+
+func (l *Logger) formatHeader(buf *[]byte, e *entry) {
+	if l.flag&Llinenum != 0 {
+		appendLineNum(buf, e.lineNum)
+	}
+	start := len(*buf)
+	l.formatPrefix(buf, e)
+	if l.prefixWidth != 0 {
+		for n := VisibleStringLen((*buf)[start:]); n < l.prefixWidth; n++ {
+			*buf = append(*buf, ' ')
 		}
 	}
 
 	if l.flag&Lisodate != 0 {
-		l.appendIsoDate(buf, l.flag&Lmicroseconds != 0)
+		e.appendIsoDate(buf, l.flag&Lmicroseconds != 0)
 		*buf = append(*buf, ' ')
 	} else {
 		if l.flag&Ldate != 0 {
-			l.appendDate(buf, false)
+			e.appendDate(buf, false)
 			*buf = append(*buf, ' ')
 		}
 		if l.flag&(Ltime|Lmicroseconds) != 0 {
-			l.appendTime(buf, l.flag&Lmicroseconds != 0)
+			e.appendTime(buf, l.flag&Lmicroseconds != 0)
 			*buf = append(*buf, ' ')
 		}
 	}
+	if l.flag&Llevel != 0 {
+		*buf = l.getBadgeTheme().appendBadge(*buf, e.level)
+		*buf = append(*buf, ' ')
+	}
 	if l.flag&(Lshortfile|Llongfile) != 0 {
-		*buf = append(*buf, l.callerFile...)
+		*buf = append(*buf, e.callerFile...)
 		*buf = append(*buf, ':')
-		itoa(buf, l.callerLine, -1)
+		itoa(buf, e.callerLine, -1)
 		*buf = append(*buf, ": "...)
 	}
-	if l.flag&Lelapsed != 0 && !l.lineStartTime.IsZero() && l.now != l.lineStartTime {
+	if l.flag&Lelapsed != 0 && !l.lineStartTime.IsZero() && e.now != l.lineStartTime {
 		*buf = append(*buf, "("...)
-		l.appendElapsed(buf)
+		l.appendElapsed(buf, e)
 		*buf = append(*buf, ") "...)
 	}
 }
 
+// formatPrefix appends the Logger's prefix, with any fields in it filled in.
+func (l *Logger) formatPrefix(buf *[]byte, e *entry) {
+	for _, part := range l.prefixParts {
+		switch part.field {
+		case "":
+			*buf = append(*buf, part.text...)
+		case "date":
+			e.appendDate(buf, false)
+		case "time":
+			e.appendTime(buf, part.includeMicros)
+		case "isodate":
+			e.appendIsoDate(buf, part.includeMicros)
+		case "elapsed":
+			l.appendElapsed(buf, e)
+		}
+	}
+}
+
 func moveCursorToLine(out io.Writer, line int) bool {
 	ws := getWriterState(out)
 	if line == ws.cursorLineIndex {
 		return false
 	}
-	tmp := []byte{}
 	for line != ws.cursorLineIndex {
 		if line < ws.cursorLineIndex {
-			tmp = append(tmp, tput("cuu", "1")...)
+			ws.pending = append(ws.pending, tput("cuu", "1")...)
 			ws.cursorLineIndex--
 		} else {
-			tmp = append(tmp, tput("cud", "1")...)
+			ws.pending = append(ws.pending, tput("cud", "1")...)
 			ws.cursorLineIndex++
 		}
 	}
-	tmp = append(tmp, bytesCarriageReturn...)
-	out.Write(tmp)
+	ws.pending = append(ws.pending, bytesCarriageReturn...)
 	ws.cursorLineIndex = line
 	ws.cursorIsAtBegin = true
 	ws.cursorIsInline = false
@@ -557,17 +1037,19 @@ func setTempLineOutput(out io.Writer, line int, buf []byte) {
 		// Don't need to do anything
 		return
 	} else if cursorIsOnlineAndInline && (currLen >= lastLen && bytes.Equal(lastBuf, buf[:lastLen])) {
-		out.Write(buf[lastLen:])
+		ws.pending = append(ws.pending, buf[lastLen:]...)
+		ws.tempAppends++
 	} else {
-		out.Write(getActiveAnsiCodes(lastBuf).getResetBytes())
+		ws.tempRedraws++
+		ws.pending = append(ws.pending, getActiveAnsiCodes(lastBuf).getResetBytes()...)
 		if !moveCursorToLine(out, line) && !ws.cursorIsAtBegin {
-			out.Write(bytesCarriageReturn)
+			ws.pending = append(ws.pending, bytesCarriageReturn...)
 		}
-		out.Write(buf)
+		ws.pending = append(ws.pending, buf...)
 		currStringLen := VisibleStringLen(buf)
 		lastStringLen := VisibleStringLen(lastBuf)
 		for i := currStringLen; i < lastStringLen; i++ {
-			out.Write(bytesSpace)
+			ws.pending = append(ws.pending, ' ')
 		}
 		ws.cursorIsInline = currStringLen >= lastStringLen
 	}
@@ -577,39 +1059,114 @@ func setTempLineOutput(out io.Writer, line int, buf []byte) {
 }
 
 func writeLine(out io.Writer, buf []byte) {
-	setTempLineOutput(out, 0, buf)
-	out.Write(getActiveAnsiCodes(buf).getResetBytes())
 	ws := getWriterState(out)
+	setTempLineOutput(out, 0, buf)
+	ws.pending = append(ws.pending, getActiveAnsiCodes(buf).getResetBytes()...)
 	if ws.multiline {
 		ws.lastTemp = ws.lastTemp[1:]
 		// Always keep an empty line at the bottom
 		if len(ws.lastTemp) == 0 {
 			ws.lastTemp = append(ws.lastTemp, []byte{})
 			moveCursorToLine(out, 0)
-			out.Write(bytesNewline)
+			ws.pending = append(ws.pending, bytesNewline...)
 		} else {
 			ws.cursorLineIndex = -1
 			moveCursorToLine(out, 0)
 		}
 	} else {
-		out.Write(bytesNewline)
+		ws.pending = append(ws.pending, bytesNewline...)
 		ws.lastTemp[0] = bytesEmpty
 		ws.cursorIsAtBegin = true
 		ws.cursorIsInline = false
 	}
 }
 
+// tempLineCache holds a Logger's partial line as last formatted for the
+// status line, along with everything that went into it.
+type tempLineCache struct {
+	key         tempLineKey
+	buf         []byte // the Logger's buf
+	prefix      []byte // and prefixFormatted
+	formatted   []byte
+	headerWidth int
+}
+
+type tempLineKey struct {
+	valid                               bool
+	e                                   entry
+	flag, prefixWidth                   int
+	lineStartTime                       time.Time
+	badgeTheme                          *BadgeTheme
+	carriedCodes                        ActiveAnsiCodes
+	carryColors, ansiDebug, color, mono bool
+}
+
+// formatTempLine returns the Logger's partial line, header and all, and the
+// width of the header, only formatting it again if something it depends on
+// has changed since the last update. The result is only good until the next
+// call. Must be called with the writer locked.
+func (l *Logger) formatTempLine(e *entry) ([]byte, int) {
+	key := tempLineKey{
+		valid:         true,
+		e:             *e,
+		flag:          l.flag,
+		prefixWidth:   l.prefixWidth,
+		lineStartTime: l.lineStartTime,
+		badgeTheme:    l.getBadgeTheme(),
+		carriedCodes:  l.carriedCodes,
+		carryColors:   l.isCarryColorsEnabled(),
+		ansiDebug:     l.isAnsiDebugEnabled(),
+		color:         l.isColorEnabled(),
+		mono:          isTrueDefaulted(l.monotonicTiming, l.defaults().monotonicTiming),
+	}
+	c := &l.tempLine
+	if key != c.key || !bytes.Equal(l.buf, c.buf) || !bytes.Equal(l.prefixFormatted, c.prefix) {
+		c.key = key
+		c.buf = append(c.buf[:0], l.buf...)
+		c.prefix = append(c.prefix[:0], l.prefixFormatted...)
+		c.formatted = l.appendFormattedLine(c.formatted[:0], l.buf, e)
+		c.headerWidth = l.headerWidth(e)
+	}
+	return c.formatted, c.headerWidth
+}
+
 func updateTempOutput(out io.Writer) {
 	ws := getWriterState(out)
+	if len(ws.pending) == 0 && ws.deferTempOutput() {
+		// Only put off updates that are all there is to write; if finished
+		// lines or cursor movement are already queued, the partial lines have
+		// to be drawn after them in the same update.
+		return
+	}
+	ws.tempDeferred = false
+	ws.tempUpdates++
 	maxWidth := getTermWidth(out) - 1
-	var bufs [][]byte
+	// Every line goes in the one pooled buffer, one after the other
+	lines := getLineBuffer()
+	defer putLineBuffer(lines)
+	ends := make([]int, 0, len(ws.tempLoggers))
 	for _, logger := range ws.tempLoggers {
-		bufs = append(bufs, logger.getFormattedLine(logger.buf))
+		start := len(*lines)
+		e := logger.tempEntry()
+		formatted, headerWidth := logger.formatTempLine(&e)
+		line := append((*lines)[start:], formatted...)
+		line, maxWidth = ws.fitNarrow(line, headerWidth, maxWidth)
+		line = logger.appendStatusSegments(line, maxWidth, ws.multiline || len(ws.tempLoggers) == 1)
+		*lines = append((*lines)[:start], line...)
+		ends = append(ends, len(*lines))
+	}
+	bufs := make([][]byte, len(ends))
+	for i, end := range ends {
+		start := 0
+		if i > 0 {
+			start = ends[i-1]
+		}
+		bufs[i] = (*lines)[start:end:end]
 	}
 	if ws.multiline {
 		for i := len(ws.lastTemp); i < len(bufs); i++ {
 			moveCursorToLine(out, i-1)
-			out.Write(bytesNewline)
+			ws.pending = append(ws.pending, bytesNewline...)
 			ws.cursorLineIndex = i
 			ws.cursorIsAtBegin = true
 			ws.cursorIsInline = false
@@ -619,6 +1176,19 @@ func updateTempOutput(out io.Writer) {
 			setTempLineOutput(out, i, trimStringEllipsis(buf, maxWidth))
 		}
 	} else {
+		status := getLineBuffer()
+		defer putLineBuffer(status)
+		for i, buf := range bufs {
+			if i > 0 {
+				*status = append(*status, tempLineSep...)
+			}
+			*status = append(*status, buf...)
+		}
+		if maxWidth == ws.lastStatusWidth && bytes.Equal(*status, ws.lastStatus) {
+			// Same as last time; this only redraws if something wrote over it
+			setTempLineOutput(out, 0, ws.lastStatusOut)
+			return
+		}
 		numBufs := len(bufs)
 		lengths := make([]int, 0)
 		lengthSum := 0
@@ -667,40 +1237,73 @@ func updateTempOutput(out io.Writer) {
 		outputBuf = bytes.Join(bufs, tempLineSep)
 		outputBuf = trimStringEllipsis(outputBuf, maxWidth)
 		setTempLineOutput(out, 0, outputBuf)
+		ws.lastStatus = append(ws.lastStatus[:0], *status...)
+		ws.lastStatusWidth = maxWidth
+		ws.lastStatusOut = append(ws.lastStatusOut[:0], outputBuf...)
 	}
 }
 
+// Precomputed escapes for every single-parameter SGR code we emit (intensity,
+// foreground, background, and their bright variants).
+var ansiEscapeTable = func() [108][]byte {
+	var table [108][]byte
+	for code := range table {
+		buf := append([]byte{}, ansiBytesEscapeStart...)
+		buf = strconv.AppendInt(buf, int64(code), 10)
+		buf = append(buf, ansiBytesColorEscapeEnd...)
+		table[code] = buf[:len(buf):len(buf)]
+	}
+	return table
+}()
+
+// ansiEscapeBytes returns the escape sequence for colorCode. The result may be
+// shared and must not be modified.
 func ansiEscapeBytes(colorCode int) []byte {
+	if colorCode >= 0 && colorCode < len(ansiEscapeTable) {
+		return ansiEscapeTable[colorCode]
+	}
 	buf := []byte{}
 	buf = append(buf, ansiBytesEscapeStart...)
-	buf = append(buf, fmt.Sprintf("%d", colorCode)...)
+	buf = strconv.AppendInt(buf, int64(colorCode), 10)
 	buf = append(buf, ansiBytesColorEscapeEnd...)
 	return buf
 }
 
+// hasEscapes reports whether buf might contain ANSI escapes; most text doesn't,
+// and can skip the regexps entirely.
+func hasEscapes(buf []byte) bool {
+	return bytes.IndexByte(buf, '\033') != -1
+}
+
 func Uncolorize(buf []byte) []byte {
-	return ansiColorRegexp.ReplaceAll(buf, bytesEmpty)
+	if !hasEscapes(buf) {
+		return buf
+	}
+	return uncolorizeSGR(buf)
 }
 
 func trimString(buf []byte, length int) []byte {
-	if length == 0 {
-		return bytesEmpty
-	}
 	tmp := []byte{}
-	for _, groups := range ansiColorOrCharRegexp.FindAllSubmatch(buf, -1) {
-		tmp = append(tmp, groups[0]...)
-		if len(groups[1]) == 0 {
-			// This match was not an ANSI escape, so count it towards the length
-			length -= 1
-			if length <= 0 {
-				return tmp
-			}
+	for i := 0; i < len(buf) && length > 0; {
+		if n := scanSGR(buf[i:]); n > 0 {
+			// ANSI escapes don't count towards the length
+			tmp = append(tmp, buf[i:i+n]...)
+			i += n
+			continue
 		}
+		_, n := utf8.DecodeRune(buf[i:])
+		tmp = append(tmp, buf[i:i+n]...)
+		i += n
+		length--
 	}
 	return tmp
 }
 
 func trimStringEllipsis(buf []byte, length int) []byte {
+	if length <= tempLineEllipsisLength {
+		// No room for the ellipsis as well as any text
+		return trimString(buf, length)
+	}
 	if VisibleStringLen(buf) > length {
 		return append(trimString(buf, length-tempLineEllipsisLength), tempLineEllipsis...)
 	}
@@ -708,19 +1311,65 @@ func trimStringEllipsis(buf []byte, length int) []byte {
 }
 
 func VisibleStringLen(buf []byte) int {
-	return utf8.RuneCount(Uncolorize(buf))
+	if !hasEscapes(buf) {
+		return utf8.RuneCount(buf)
+	}
+	return visibleLenSGR(buf)
+}
+
+// Line buffers are recycled between calls, as formatting one happens for every
+// line written and every redraw of the partial lines.
+var lineBufferPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 256)
+		return &buf
+	},
+}
+
+func getLineBuffer() *[]byte { return lineBufferPool.Get().(*[]byte) }
+
+// Messages are formatted into recycled buffers as well; emit copies what it
+// needs to keep.
+var messageBufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+func getMessageBuffer() *bytes.Buffer { return messageBufferPool.Get().(*bytes.Buffer) }
+
+func putMessageBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > 64*1024 {
+		return
+	}
+	buf.Reset()
+	messageBufferPool.Put(buf)
 }
 
-func (l *Logger) getFormattedLine(line []byte) []byte {
-	l.tmp = l.tmp[:0]
-	l.formatHeader(&l.tmp)
-	codes := getActiveAnsiCodes(l.tmp)
-	l.tmp = append(l.tmp, codes.getResetBytes()...)
-	l.tmp = append(l.tmp, line...)
-	if !l.isColorEnabled() {
-		l.tmp = Uncolorize(l.tmp)
+func putLineBuffer(buf *[]byte) {
+	if cap(*buf) > 64*1024 {
+		// Don't hang on to the occasional giant line
+		return
+	}
+	*buf = (*buf)[:0]
+	lineBufferPool.Put(buf)
+}
+
+// appendFormattedLine appends line, preceded by the Logger's header, to tmp.
+func (l *Logger) appendFormattedLine(tmp []byte, line []byte, e *entry) []byte {
+	start := len(tmp)
+	l.formatHeader(&tmp, e)
+	codes := getActiveAnsiCodes(tmp[start:])
+	tmp = append(tmp, codes.getResetBytes()...)
+	if l.isCarryColorsEnabled() {
+		tmp = l.carriedCodes.appendSetBytes(tmp)
+	}
+	tmp = append(tmp, line...)
+	if l.isAnsiDebugEnabled() {
+		formatted := append([]byte{}, tmp[start:]...)
+		tmp = appendAnsiDebug(tmp[:start], formatted)
+	} else if !l.isColorEnabled() && hasEscapes(tmp[start:]) {
+		tmp = append(tmp[:start], Uncolorize(tmp[start:])...)
 	}
-	return l.tmp
+	return tmp
 }
 
 func (l *Logger) reprocessPrefix() {
@@ -730,6 +1379,10 @@ func (l *Logger) reprocessPrefix() {
 	} else {
 		l.prefixFormatted = l.prefix
 	}
+	if len(l.prefix) != 0 && !hasEscapes(l.prefixFormatted) && isTrueDefaulted(l.autoColor, l.defaults().autoColor) {
+		l.prefixFormatted = []byte(colorize(string(l.prefixFormatted), autoColor(l.prefix)))
+	}
+	l.prefixParts = parsePrefix(l.prefixFormatted)
 }
 
 func processColorTemplates(colorTemplateRegexp *regexp.Regexp, buf []byte) []byte {
@@ -741,7 +1394,9 @@ func processColorTemplates(colorTemplateRegexp *regexp.Regexp, buf []byte) []byt
 		groups := colorTemplateRegexp.FindSubmatch(token)
 		var ansiActive ActiveAnsiCodes
 		for _, codeBytes := range bytes.Split(groups[1], bytesComma) {
+			ansiColorCodesMutex.RLock()
 			colorCode, ok := ansiColorCodes[string(codeBytes)]
+			ansiColorCodesMutex.RUnlock()
 			if !ok {
 				// Don't modify the text if we don't recognize any of the codes
 				return groups[0]
@@ -770,6 +1425,29 @@ func (l *Logger) applyColorTemplates(s string) string {
 }
 
 func (l *Logger) injectAtVirtualCursor(input []byte) {
+	// Line breaks move the cursor rather than overwriting anything, as on a
+	// terminal. Once the cursor is at the end, intOutput takes care of them.
+	for len(l.buf) != l.cursorByteIndex {
+		i := bytes.IndexAny(input, "\r\n")
+		if i == -1 {
+			break
+		}
+		l.overwriteAtVirtualCursor(input[:i])
+		if input[i] == '\r' && (i+1 == len(input) || input[i+1] != '\n') {
+			l.cursorByteIndex = 0
+			input = input[i+1:]
+		} else {
+			// A newline ends the line where it is, keeping the rest of it
+			l.cursorByteIndex = len(l.buf)
+			input = input[i:]
+		}
+	}
+	l.overwriteAtVirtualCursor(input)
+}
+
+// overwriteAtVirtualCursor writes input, which has no line breaks, at the
+// virtual cursor.
+func (l *Logger) overwriteAtVirtualCursor(input []byte) {
 	if len(l.buf) != l.cursorByteIndex {
 		// Append s to l.buf[:cursorByteIndex], consuming l.buf[cursorByteIndex:] with
 		// each rune, but also injecting ansi escapes at the new old/new transition
@@ -782,7 +1460,8 @@ func (l *Logger) injectAtVirtualCursor(input []byte) {
 				panic(fmt.Sprintf("injectAtVirtualCursor failed with cursorByteIndex=%d and len(buf)=%d. Original: %v", l.cursorByteIndex, len(l.buf), e))
 			}
 		}()
-		before := l.buf[:l.cursorByteIndex]
+		// Cap before, so that appending to it copies rather than overwriting after
+		before := l.buf[:l.cursorByteIndex:l.cursorByteIndex]
 		after := l.buf[l.cursorByteIndex:]
 		afterLength := VisibleStringLen(after)
 		inputLength := VisibleStringLen(input)
@@ -821,35 +1500,102 @@ func (l *Logger) injectAtVirtualCursor(input []byte) {
 }
 
 func (l *Logger) Output(calldepth int, _s string) error {
-	return l.intOutput(calldepth+1, []byte(_s), false)
+	if l.isDiscarded() {
+		return nil
+	}
+	return l.emit(l.newEntry(calldepth+1), []byte(_s), false)
+}
+
+// OutputBytes is like Output, but takes the text as a []byte, which saves
+// a copy for callers that already have one. b is not retained.
+func (l *Logger) OutputBytes(calldepth int, b []byte) error {
+	if l.isDiscarded() {
+		return nil
+	}
+	return l.emit(l.newEntry(calldepth+1), b, false)
+}
+
+// newEntry captures the time and (if the flags call for it) the caller of a
+// logging event. This is expensive for callers, so it is done before taking the
+// writer lock, which is then held for the rest of the call; lines from different
+// goroutines can't interleave into one another. The flags and the rest are read
+// from the Logger's configSnapshot, so it must be called without the lock
+// (see lockedEntry). Calldepth is passed
+// through to runtime.Caller, so 2 refers to the caller of the function calling
+// newEntry, plus the Logger's SetCallDepthOffset.
+func (l *Logger) newEntry(calldepth int) entry {
+	c := l.snapshot()
+	return makeEntry(calldepth+1, c.flag, c.callDepth, c.nowFunc)
+}
+
+// lockedEntry is newEntry for callers that already hold the writer's lock.
+func (l *Logger) lockedEntry(calldepth int) entry {
+	nowFunc := l.nowFunc
+	if nowFunc == nil {
+		nowFunc = l.defaults().nowFunc
+	}
+	return makeEntry(calldepth+1, l.flag, l.callDepthOffset, nowFunc)
+}
+
+func makeEntry(calldepth int, flag int, callDepthOffset int, nowFunc func() time.Time) entry {
+	var e entry
+	if nowFunc != nil {
+		e.now = nowFunc()
+	} else {
+		e.now = time.Now()
+	}
+	if flag&LUTC != 0 {
+		e.now = e.now.UTC()
+	}
+	if flag&(Lshortfile|Llongfile) != 0 {
+		var ok bool
+		_, e.callerFile, e.callerLine, ok = runtime.Caller(calldepth + callDepthOffset)
+		if !ok {
+			e.callerFile = "???"
+			e.callerLine = 0
+		}
+		if flag&Lshortfile != 0 {
+			for i := len(e.callerFile) - 1; i > 0; i-- {
+				if e.callerFile[i] == '/' {
+					e.callerFile = e.callerFile[i+1:]
+					break
+				}
+			}
+		}
+	}
+	return e
 }
 
 // Output writes the output for a logging event.  The string s contains
 // the text to print after the prefix specified by the flags of the
 // Logger.  A newline is appended if the last character of s is not
-// already a newline.  Calldepth is used to recover the PC and is
-// provided for generality, although at the moment on all pre-defined
-// paths it will be 2.
-func (l *Logger) intOutput(calldepth int, s []byte, haveLock bool) error {
+// already a newline.
+func (l *Logger) intOutput(e *entry, s []byte, haveLock bool) error {
 	ws := getWriterState(l.out)
 	if !haveLock {
 		ws.lock()
 		defer ws.unlock()
 	}
-	l.now = time.Now() // get this early.
-	if l.flag&LUTC != 0 {
-		l.now = l.now.UTC()
-	}
 	if l.isClosed {
 		return errors.New("Attempted to write to closed Logger.")
 	}
+	ws.lastActivity = e.now
+	if l.isPlainLine(ws, s) {
+		// Fast path: a single, whole line, with nothing on the screen to
+		// work around. This is the common case when not showing partial lines.
+		l.finishLine(ws, s[:len(s)-1], e)
+		l.lastEntry = entry{now: e.now}
+		return ws.writePending(l.out)
+	}
 	// This is kind of kludgy, but better than nothing:
-	s = []byte(strings.Replace(string(s), "\t", "        ", -1))
+	if bytes.IndexByte(s, '\t') != -1 {
+		s = bytes.Replace(s, bytesTab, bytesTabSpaces, -1)
+	}
 	if l.isAutoNewlineEnabled() && len(s) > 0 && s[len(s)-1] != byteNewline {
-		s = append(s, byteNewline)
+		// Force a copy so we never write into the caller's spare capacity
+		s = append(s[:len(s):len(s)], byteNewline)
 	}
 	l.injectAtVirtualCursor(s)
-	wroteFullLine := false
 	for true {
 		indexNewline := bytes.IndexByte(l.buf, '\n')
 		var currLine []byte
@@ -872,60 +1618,157 @@ func (l *Logger) intOutput(calldepth int, s []byte, haveLock bool) error {
 		if indexNewline == -1 {
 			break
 		}
-		l.buf = l.buf[indexNewline+1:]
-		l.cursorByteIndex = 0
-		if l.flag&(Lshortfile|Llongfile) != 0 && len(l.callerFile) == 0 {
-			// release lock while getting caller info - it's expensive.
-			if !haveLock {
-				ws.unlock()
-			}
-			var ok bool
-			_, l.callerFile, l.callerLine, ok = runtime.Caller(calldepth)
-			if !ok {
-				l.callerFile = "???"
-				l.callerLine = 0
-			}
-			if l.flag&Lshortfile != 0 {
-				for i := len(l.callerFile) - 1; i > 0; i-- {
-					if l.callerFile[i] == '/' {
-						l.callerFile = l.callerFile[i+1:]
-						break
-					}
-				}
-			}
-			if !haveLock {
-				ws.lock()
-			}
-		}
-		// ansiActive := getActiveAnsiCodes(currLine)
-		ws.removeTempLogger(l)
-		l.tempLineActive = false
-		writeLine(l.out, l.getFormattedLine(currLine))
-		wroteFullLine = true
-		// // XXX This is probably inefficient?:
-		// prepends := []byte{}
-		// if ansiActive.intensity != 0 {
-		//     prepends = append(prepends, ansiEscapeBytes(ansiActive.intensity)...)
-		// }
-		// if ansiActive.forecolor != 0 {
-		//     prepends = append(prepends, ansiEscapeBytes(ansiActive.forecolor)...)
-		// }
-		// if len(prepends) > 0 {
-		//     l.buf = append(prepends, l.buf...)
-		//     l.cursorByteIndex += len(prepends)
-		// }
-	}
-	if wroteFullLine {
-		l.callerFile = ""
-		l.callerLine = 0
+		l.finishLine(ws, currLine, e)
+		// Shift the rest down rather than reslicing, which would keep the
+		// already-written text alive for as long as the Logger writes no more
+		// newlines. Everything remaining was written after the newline, so the
+		// virtual cursor ends up after all of it.
+		l.buf = l.buf[:copy(l.buf, l.buf[indexNewline+1:])]
+		l.cursorByteIndex = len(l.buf)
 	}
+	l.limitPartialLine()
+	l.lastEntry = entry{now: e.now}
 	if !l.tempLineActive && l.isPartialLinesEnabled() && VisibleStringLen(l.buf) > 0 {
 		ws.addTempLogger(l)
 		l.tempLineActive = true
-		l.lineStartTime = l.now
+		l.lineStartTime = e.now
+	}
+	if l.events != nil && l.tempLineActive {
+		l.writeLineEvent("partial", l.buf, e)
 	}
 	updateTempOutput(l.out)
-	return nil
+	return ws.writePending(l.out)
+}
+
+// DefaultMaxPartialLineLength is the default limit for SetMaxPartialLineLength.
+const DefaultMaxPartialLineLength = 1 << 20
+
+// SetMaxPartialLineLength limits how many bytes of an unfinished line the
+// Logger holds on to. Past that, the line is cut short with an ellipsis, so
+// that a Logger that never writes a newline can't grow without bound.
+func (l *Logger) SetMaxPartialLineLength(length int) {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	l.maxPartialLineLength = length
+}
+
+func (l *Logger) limitPartialLine() {
+	maxLength := l.maxPartialLineLength
+	if maxLength <= 0 {
+		maxLength = DefaultMaxPartialLineLength
+	}
+	if len(l.buf) > maxLength {
+		keep := visibleCut(l.buf, maxLength-len(tempLineEllipsis))
+		l.buf = append(l.buf[:keep], tempLineEllipsis...)
+		l.cursorByteIndex = len(l.buf)
+	}
+	if cap(l.buf) > 64*1024 && len(l.buf) < cap(l.buf)/4 {
+		// Give back the memory from an earlier giant line
+		l.buf = append([]byte(nil), l.buf...)
+	}
+}
+
+// visibleCut returns where to cut buf at or before keep bytes in, without
+// splitting a character or an escape sequence.
+func visibleCut(buf []byte, keep int) int {
+	if keep <= 0 {
+		return 0
+	}
+	for keep > 0 && !utf8.RuneStart(buf[keep]) {
+		keep--
+	}
+	esc := bytes.LastIndexByte(buf[:keep], '\033')
+	if esc < 0 {
+		return keep
+	}
+	end := esc + 2
+	if buf[esc+1] == '[' {
+		// A CSI sequence runs up to its final byte, e.g. the m of an SGR
+		for end < len(buf) && (buf[end] < 0x40 || buf[end] > 0x7e) {
+			end++
+		}
+		end++
+	}
+	if end > keep {
+		return esc
+	}
+	return keep
+}
+
+// isPlainLine reports whether s can be written as-is as a single finished line,
+// without any of the partial-line bookkeeping.
+func (l *Logger) isPlainLine(ws *WriterState, s []byte) bool {
+	if len(l.buf) != 0 || len(ws.tempLoggers) != 0 || ws.multiline || !ws.cursorIsAtBegin || len(ws.lastTemp[0]) != 0 {
+		return false
+	}
+	if len(s) == 0 || s[len(s)-1] != byteNewline {
+		return false
+	}
+	for _, c := range s[:len(s)-1] {
+		if c == '\n' || c == '\r' || c == '\t' {
+			return false
+		}
+	}
+	return true
+}
+
+// finishLine writes out line, which has just been completed.
+func (l *Logger) finishLine(ws *WriterState, line []byte, e *entry) {
+	if l.maxLineLength > 0 {
+		for _, part := range l.limitLine(line) {
+			l.writeFinishedLine(ws, part, e)
+		}
+		return
+	}
+	l.writeFinishedLine(ws, line, e)
+}
+
+func (l *Logger) writeFinishedLine(ws *WriterState, line []byte, e *entry) {
+	ws.removeTempLogger(l)
+	l.tempLineActive = false
+	if l.ring != nil {
+		if l.dumpOnError && e.level >= LevelError {
+			l.dumpRing(ws, e.level)
+		}
+		l.ring.add(newEntryRecord(line, e))
+	}
+	ws.linesWritten++
+	if l.flag&Llinenum != 0 {
+		e.lineNum = l.nextLineNum(ws)
+	}
+	lineBuf := getLineBuffer()
+	*lineBuf = l.appendFormattedLine(*lineBuf, line, e)
+	if ws.ciMode != CINone && e.level >= LevelWarn {
+		l.writeCIFormattedLine(ws, *lineBuf, line, e)
+	} else {
+		l.writeFormattedLine(ws, *lineBuf, e.level)
+	}
+	if len(l.outputs) != 0 {
+		l.writeOutputs(*lineBuf, line, e)
+	}
+	if l.events != nil {
+		l.writeLineEvent("line", line, e)
+	}
+	putLineBuffer(lineBuf)
+	if l.isCarryColorsEnabled() {
+		l.carriedCodes.addFrom(line)
+	}
+}
+
+// writeFormattedLine writes out a finished line, header and all, to wherever
+// lines at its level go.
+func (l *Logger) writeFormattedLine(ws *WriterState, formatted []byte, level Level) {
+	if l.isErrOutLine(level) {
+		l.writeErrOut(formatted)
+	} else if len(ws.tempLoggers) != 0 || ws.multiline || !ws.cursorIsAtBegin || len(ws.lastTemp[0]) != 0 {
+		writeLine(l.out, formatted)
+	} else {
+		// Nothing else is on the current line, so there's nothing to redraw
+		ws.pending = append(ws.pending, formatted...)
+		ws.pending = append(ws.pending, getActiveAnsiCodes(formatted).getResetBytes()...)
+		ws.pending = append(ws.pending, byteNewline)
+	}
 }
 
 func (l *Logger) truncateBuf() {
@@ -933,95 +1776,146 @@ func (l *Logger) truncateBuf() {
 	l.cursorByteIndex = 0
 }
 
-// Printf calls l.Output to print to the logger.
-// Arguments are handled in the manner of fmt.Printf.
-func (l *Logger) Printf(format string, v ...interface{}) {
+// emit sends a formatted message on to the writer, or to the async queue if
+// the Logger has one. If replace is set, the current partial line is discarded
+// first. The level and queue are read from the Logger's configSnapshot, so
+// that neither hidden lines nor those for the queue wait on the writer's lock.
+func (l *Logger) emit(e entry, s []byte, replace bool) error {
+	if l.isDiscarded() {
+		return nil
+	}
+	c := l.snapshot()
+	if e.level < c.level {
+		if c.keepHidden {
+			ws := getWriterState(l.out)
+			ws.lock()
+			if l.ring != nil {
+				l.ring.addHidden(s, &e)
+			}
+			ws.unlock()
+		}
+		return nil
+	}
+	countLevel(e.level)
+	if c.async != nil {
+		err := c.async.enqueue(asyncMessage{e: e, s: append([]byte{}, s...), replace: replace})
+		if err != errAsyncClosed {
+			return err
+		}
+		// The queue was stopped after the snapshot was taken
+	}
 	ws := getWriterState(l.out)
 	ws.lock()
+	if q := l.async; q != nil {
+		ws.unlock()
+		return q.enqueue(asyncMessage{e: e, s: append([]byte{}, s...), replace: replace})
+	}
 	defer ws.unlock()
-	l.intOutput(2, []byte(fmt.Sprintf(l.applyColorTemplates(format), v...)), true)
+	if replace {
+		l.truncateBuf()
+	}
+	return l.intOutput(&e, s, true)
+}
+
+// Printf calls l.Output to print to the logger.
+// Arguments are handled in the manner of fmt.Printf, except that any left over
+// once the format's verbs have theirs are printed as key=value fields, as by
+// Printkv.
+func (l *Logger) Printf(format string, v ...interface{}) {
+	if !l.isPrinting(LevelInfo) {
+		return
+	}
+	msg := getMessageBuffer()
+	l.fprintf(msg, format, v)
+	l.emit(l.newEntry(2), msg.Bytes(), false)
+	putMessageBuffer(msg)
 }
 
 // Print calls l.Output to print to the logger.
 // Arguments are handled in the manner of fmt.Print.
-func (l *Logger) Print(v ...interface{}) { l.intOutput(2, []byte(fmt.Sprint(v...)), false) }
+func (l *Logger) Print(v ...interface{}) {
+	if !l.isPrinting(LevelInfo) {
+		return
+	}
+	msg := getMessageBuffer()
+	fmt.Fprint(msg, v...)
+	l.emit(l.newEntry(2), msg.Bytes(), false)
+	putMessageBuffer(msg)
+}
+
+// PrintBytes prints b as-is to the logger, like Write. b is not retained.
+func (l *Logger) PrintBytes(b []byte) {
+	if l.isDiscarded() {
+		return
+	}
+	l.emit(l.newEntry(2), b, false)
+}
 
 func (l *Logger) Replacef(format string, v ...interface{}) {
-	ws := getWriterState(l.out)
-	ws.lock()
-	defer ws.unlock()
-	l.truncateBuf()
-	l.intOutput(2, []byte(fmt.Sprintf(l.applyColorTemplates(format), v...)), true)
+	if l.isDiscarded() {
+		return
+	}
+	l.emit(l.newEntry(2), []byte(fmt.Sprintf(l.expandFormat(format, v), v...)), true)
 }
 
 func (l *Logger) Replace(v ...interface{}) {
-	ws := getWriterState(l.out)
-	ws.lock()
-	defer ws.unlock()
-	l.truncateBuf()
-	l.intOutput(2, []byte(fmt.Sprint(v...)), true)
+	if l.isDiscarded() {
+		return
+	}
+	l.emit(l.newEntry(2), []byte(fmt.Sprint(v...)), true)
 }
 
 // Println calls l.intOutput to print to the logger.
 // Arguments are handled in the manner of fmt.Println.
-func (l *Logger) Println(v ...interface{}) { l.intOutput(2, []byte(fmt.Sprintln(v...)), false) }
+func (l *Logger) Println(v ...interface{}) {
+	if !l.isPrinting(LevelInfo) {
+		return
+	}
+	msg := getMessageBuffer()
+	fmt.Fprintln(msg, v...)
+	l.emit(l.newEntry(2), msg.Bytes(), false)
+	putMessageBuffer(msg)
+}
 
+// Error prints a line at LevelError.
 func (l *Logger) Error(format string, v ...interface{}) {
-	if !strings.HasSuffix(format, "\n") {
-		format += "\n"
-	}
-	l.Printf(format, v...)
+	l.logf(2, LevelError, format, v)
 }
 
 // Fatal is equivalent to l.Print() followed by a call to os.Exit(1).
 func (l *Logger) Fatal(v ...interface{}) {
-	l.intOutput(2, []byte(fmt.Sprint(v...)), false)
-	osExit()
+	l.exitFatal(l.newEntry(2), fmt.Sprint(v...))
 }
 
 // Fatalf is equivalent to l.Printf() followed by a call to os.Exit(1).
 func (l *Logger) Fatalf(format string, v ...interface{}) {
-	ws := getWriterState(l.out)
-	ws.lock()
-	l.intOutput(2, []byte(fmt.Sprintf(l.applyColorTemplates(format), v...)), true)
-	ws.unlock()
-	osExit()
+	l.exitFatal(l.newEntry(2), fmt.Sprintf(l.expandFormat(format, v), v...))
 }
 
 // Fatalln is equivalent to l.Println() followed by a call to os.Exit(1).
 func (l *Logger) Fatalln(v ...interface{}) {
-	l.intOutput(2, []byte(fmt.Sprintln(v...)), false)
-	osExit()
+	l.exitFatal(l.newEntry(2), fmt.Sprintln(v...))
 }
 
 // Panic is equivalent to l.Print() followed by a call to panic().
 func (l *Logger) Panic(v ...interface{}) {
-	s := fmt.Sprint(v...)
-	l.intOutput(2, []byte(s), false)
-	l.flushInt()
-	panic(s)
+	l.panicWith(l.newEntry(2), fmt.Sprint(v...))
 }
 
 // Panicf is equivalent to l.Printf() followed by a call to panic().
 func (l *Logger) Panicf(format string, v ...interface{}) {
-	ws := getWriterState(l.out)
-	ws.lock()
-	s := fmt.Sprintf(l.applyColorTemplates(format), v...)
-	l.intOutput(2, []byte(s), true)
-	l.flushInt()
-	ws.unlock()
-	panic(s)
+	l.panicWith(l.newEntry(2), fmt.Sprintf(l.expandFormat(format, v), v...))
 }
 
 // Panicln is equivalent to l.Println() followed by a call to panic().
 func (l *Logger) Panicln(v ...interface{}) {
-	s := fmt.Sprintln(v...)
-	l.intOutput(2, []byte(s), false)
-	panic(s)
+	l.panicWith(l.newEntry(2), fmt.Sprintln(v...))
 }
 
 func (l *Logger) Bail(err error) {
 	// This works best if l.out == os.Stderr, but it should kind of work regardless
+	e := l.newEntry(2)
+	l.drainAsync()
 	ws := getWriterState(l.out)
 	ws.lock()
 	l.flushInt()
@@ -1043,11 +1937,11 @@ func (l *Logger) Bail(err error) {
 			if (i == 2 || i == 4) && strings.Contains(line, "tillberg/ansi-log/log.go") {
 				continue
 			}
-			l.intOutput(2, []byte(line+"\n"), true)
+			l.intOutput(&e, []byte(line+"\n"), true)
 		}
 		break
 	}
-	l.intOutput(2, []byte(fmt.Sprintf("Bailed due to error: %s\n", err.Error())), true)
+	l.intOutput(&e, []byte(fmt.Sprintf("Bailed due to error: %s\n", err.Error())), true)
 	ws.unlock()
 	panic(err)
 }
@@ -1072,6 +1966,28 @@ func (l *Logger) SetFlags(flag int) {
 	ws.lock()
 	defer ws.unlock()
 	l.flag = flag
+	configChanged()
+}
+
+// SetNowFunc replaces time.Now as the source of the Logger's timestamps, e.g.
+// to get the same output on every run in tests. nil restores the default.
+func (l *Logger) SetNowFunc(now func() time.Time) {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	l.nowFunc = now
+	configChanged()
+}
+
+// SetCallDepthOffset makes the Logger skip n more stack frames when finding
+// the caller for Lshortfile and Llongfile, so that wrappers around the Logger
+// report their own callers. It applies to every method, including Output.
+func (l *Logger) SetCallDepthOffset(n int) {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	l.callDepthOffset = n
+	configChanged()
 }
 
 // Prefix returns the output prefix for the logger.
@@ -1092,20 +2008,21 @@ func (l *Logger) SetPrefix(prefix string) {
 }
 
 func (l *Logger) Write(p []byte) (n int, err error) {
-	err = l.intOutput(2, p, false)
+	if l.isDiscarded() {
+		return len(p), nil
+	}
+	err = l.emit(l.newEntry(2), p, false)
 	return len(p), err
 }
 
 func (l *Logger) Colorify(s string) string {
-	ws := getWriterState(l.out)
-	ws.lock()
-	defer ws.unlock()
-	return l.applyColorTemplates(s)
+	return l.colorify(l.snapshot(), s)
 }
 
 func (l *Logger) flushInt() {
 	if len(l.buf) > 0 {
-		l.intOutput(2, []byte("\n"), true)
+		e := l.lockedEntry(2)
+		l.intOutput(&e, []byte("\n"), true)
 	}
 }
 
@@ -1114,22 +2031,59 @@ func (l *Logger) closeInt() {
 }
 
 func (l *Logger) Flush() {
+	l.drainAsync()
 	ws := getWriterState(l.out)
 	ws.lock()
 	defer ws.unlock()
 	l.flushInt()
+	ws.waitBackground(l.out)
+	ws.flushBuffered(l.out)
 }
 
 func (l *Logger) Close() error {
+	l.stopAsync()
+	var err error
+	for _, r := range l.stepReporters(true) {
+		if rerr := r.finish(); err == nil {
+			err = rerr
+		}
+	}
 	ws := getWriterState(l.out)
 	ws.lock()
+	closers := l.closers
+	l.closers = nil
+	defer func() {
+		for _, f := range closers {
+			f()
+		}
+	}()
+	stopLiveHeaders := l.stopLiveHeaders()
+	defer stopLiveHeaders()
 	defer ws.unlock()
 	if len(l.buf) > 0 {
 		l.flushInt()
 	}
 	ws.removeTempLogger(l)
 	l.closeInt()
-	return nil
+	ws.waitBackground(l.out)
+	ws.flushBuffered(l.out)
+	return err
+}
+
+// Destroy closes the Logger and releases everything it holds, including the
+// state kept for its writer once no other Logger writes there. Use this for
+// short-lived Loggers (e.g. one per request or job); the Logger must not be
+// used afterward.
+func (l *Logger) Destroy() {
+	if !l.attached {
+		return
+	}
+	l.Close()
+	ws := getWriterState(l.out)
+	ws.lock()
+	l.buf = nil
+	ws.unlock()
+	l.detachWriterState()
 }
 
 func (l *Logger) SetPartialLinesEnabled(flag bool) {
@@ -1156,6 +2110,7 @@ func (l *Logger) SetColorTemplateEnabled(flag bool) {
 	defer ws.unlock()
 	l.colorTemplateEnabled = boolPointer(flag)
 	l.reprocessPrefix()
+	configChanged()
 }
 func (l *Logger) EnableColorTemplate()  { l.SetColorTemplateEnabled(true) }
 func (l *Logger) DisableColorTemplate() { l.SetColorTemplateEnabled(false) }
@@ -1169,11 +2124,27 @@ func (l *Logger) SetAutoNewlines(flag bool) {
 func (l *Logger) EnableAutoNewlines()  { l.SetAutoNewlines(true) }
 func (l *Logger) DisableAutoNewlines() { l.SetAutoNewlines(false) }
 
+// SetCarryColorsEnabled determines whether colors left active at the end of a
+// line carry over to the following lines. By default, each line starts out
+// unstyled (after the prefix).
+func (l *Logger) SetCarryColorsEnabled(flag bool) {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	l.carryColors = boolPointer(flag)
+}
+func (l *Logger) EnableCarryColors()  { l.SetCarryColorsEnabled(true) }
+func (l *Logger) DisableCarryColors() { l.SetCarryColorsEnabled(false) }
+
+// SetColorTemplateRegexp replaces the regexp that finds color templates. The
+// prefix's cached expansion was made with the old one, so it's redone here.
 func (l *Logger) SetColorTemplateRegexp(rgx *regexp.Regexp) {
 	ws := getWriterState(l.out)
 	ws.lock()
 	defer ws.unlock()
 	l.colorRegexp = rgx
+	l.reprocessPrefix()
+	configChanged()
 }
 
 func (l *Logger) SetTerminalWidth(width int) {
@@ -1201,11 +2172,7 @@ func (l *Logger) EnableSinglelineMode() { l.SetMultilineEnabled(false) }
 
 // SetOutput sets the output destination for the standard logger.
 func SetOutput(w io.Writer) {
-	ws := getWriterState(DefaultLogger.out)
-	ws.lock()
-	defer ws.unlock()
-	DefaultLogger.flushInt()
-	DefaultLogger.out = w
+	DefaultLogger.SetOutput(w)
 }
 
 // Flags returns the output flags for the standard logger.
@@ -1233,89 +2200,93 @@ func SetPrefix(prefix string) {
 // Print calls Output to print to the standard logger.
 // Arguments are handled in the manner of fmt.Print.
 func Print(v ...interface{}) {
-	DefaultLogger.intOutput(2, []byte(fmt.Sprint(v...)), false)
+	if !DefaultLogger.isPrinting(LevelInfo) {
+		return
+	}
+	msg := getMessageBuffer()
+	fmt.Fprint(msg, v...)
+	DefaultLogger.emit(DefaultLogger.newEntry(2), msg.Bytes(), false)
+	putMessageBuffer(msg)
 }
 
 // Printf calls Output to print to the standard logger.
 // Arguments are handled in the manner of fmt.Printf.
 func Printf(format string, v ...interface{}) {
-	ws := getWriterState(DefaultLogger.out)
-	ws.lock()
-	defer ws.unlock()
-	DefaultLogger.intOutput(2, []byte(fmt.Sprintf(DefaultLogger.applyColorTemplates(format), v...)), true)
+	if !DefaultLogger.isPrinting(LevelInfo) {
+		return
+	}
+	msg := getMessageBuffer()
+	DefaultLogger.fprintf(msg, format, v)
+	DefaultLogger.emit(DefaultLogger.newEntry(2), msg.Bytes(), false)
+	putMessageBuffer(msg)
+}
+
+// PrintBytes prints b as-is to the standard logger. b is not retained.
+func PrintBytes(b []byte) {
+	if DefaultLogger.isDiscarded() {
+		return
+	}
+	DefaultLogger.emit(DefaultLogger.newEntry(2), b, false)
 }
 
 func Replace(v ...interface{}) {
-	ws := getWriterState(DefaultLogger.out)
-	ws.lock()
-	defer ws.unlock()
-	DefaultLogger.truncateBuf()
-	DefaultLogger.intOutput(2, []byte(fmt.Sprint(v...)), true)
+	if DefaultLogger.isDiscarded() {
+		return
+	}
+	DefaultLogger.emit(DefaultLogger.newEntry(2), []byte(fmt.Sprint(v...)), true)
 }
 
 func Replacef(format string, v ...interface{}) {
-	ws := getWriterState(DefaultLogger.out)
-	ws.lock()
-	defer ws.unlock()
-	DefaultLogger.truncateBuf()
-	DefaultLogger.intOutput(2, []byte(fmt.Sprintf(DefaultLogger.applyColorTemplates(format), v...)), true)
+	if DefaultLogger.isDiscarded() {
+		return
+	}
+	DefaultLogger.emit(DefaultLogger.newEntry(2), []byte(fmt.Sprintf(DefaultLogger.expandFormat(format, v), v...)), true)
 }
 
 // Println calls Output to print to the standard logger.
 // Arguments are handled in the manner of fmt.Println.
 func Println(v ...interface{}) {
-	DefaultLogger.intOutput(2, []byte(fmt.Sprintln(v...)), false)
+	if !DefaultLogger.isPrinting(LevelInfo) {
+		return
+	}
+	msg := getMessageBuffer()
+	fmt.Fprintln(msg, v...)
+	DefaultLogger.emit(DefaultLogger.newEntry(2), msg.Bytes(), false)
+	putMessageBuffer(msg)
 }
 
 func Error(format string, v ...interface{}) {
-	DefaultLogger.Error(format, v...)
+	DefaultLogger.logf(2, LevelError, format, v)
 }
 
 // Fatal is equivalent to Print() followed by a call to os.Exit(1).
 func Fatal(v ...interface{}) {
-	DefaultLogger.intOutput(2, []byte(fmt.Sprint(v...)), false)
-	osExit()
+	DefaultLogger.exitFatal(DefaultLogger.newEntry(2), fmt.Sprint(v...))
 }
 
 // Fatalf is equivalent to Printf() followed by a call to os.Exit(1).
 func Fatalf(format string, v ...interface{}) {
-	ws := getWriterState(DefaultLogger.out)
-	ws.lock()
-	DefaultLogger.intOutput(2, []byte(fmt.Sprintf(DefaultLogger.applyColorTemplates(format), v...)), true)
-	ws.unlock()
-	osExit()
+	DefaultLogger.exitFatal(DefaultLogger.newEntry(2), fmt.Sprintf(DefaultLogger.expandFormat(format, v), v...))
 }
 
 // Fatalln is equivalent to Println() followed by a call to os.Exit(1).
 func Fatalln(v ...interface{}) {
-	DefaultLogger.intOutput(2, []byte(fmt.Sprintln(v...)), false)
-	osExit()
+	DefaultLogger.exitFatal(DefaultLogger.newEntry(2), fmt.Sprintln(v...))
 }
 
 // Panic is equivalent to Print() followed by a call to panic().
 func Panic(v ...interface{}) {
-	s := fmt.Sprint(v...)
-	DefaultLogger.intOutput(2, []byte(s), false)
-	DefaultLogger.flushInt()
-	panic(s)
+	DefaultLogger.panicWith(DefaultLogger.newEntry(2), fmt.Sprint(v...))
 }
 
 // Panicf is equivalent to Printf() followed by a call to panic().
 func Panicf(format string, v ...interface{}) {
-	ws := getWriterState(DefaultLogger.out)
-	ws.lock()
-	s := fmt.Sprintf(DefaultLogger.applyColorTemplates(format), v...)
-	DefaultLogger.intOutput(2, []byte(s), true)
-	DefaultLogger.flushInt()
-	ws.unlock()
-	panic(s)
+	DefaultLogger.panicWith(DefaultLogger.newEntry(2), fmt.Sprintf(DefaultLogger.expandFormat(format, v), v...))
 }
 
 // Panicln is equivalent to Println() followed by a call to panic().
 func Panicln(v ...interface{}) {
-	s := fmt.Sprintln(v...)
-	DefaultLogger.intOutput(2, []byte(s), false)
-	panic(s)
+	DefaultLogger.panicWith(DefaultLogger.newEntry(2), fmt.Sprintln(v...))
 }
 
 func Bail(err error) {
@@ -1333,30 +2304,50 @@ func DisableColor()                             { DefaultLogger.DisableColor() }
 func EnableColorTemplate()                      { DefaultLogger.EnableColorTemplate() }
 func DisableColorTemplate()                     { DefaultLogger.DisableColorTemplate() }
 func EnableAutoNewlines()                       { DefaultLogger.SetAutoNewlines(true) }
+func EnableCarryColors()                        { DefaultLogger.EnableCarryColors() }
+func DisableCarryColors()                       { DefaultLogger.DisableCarryColors() }
 func DisableAutoNewlines()                      { DefaultLogger.SetAutoNewlines(false) }
 func SetColorTemplateRegexp(rgx *regexp.Regexp) { DefaultLogger.SetColorTemplateRegexp(rgx) }
 func SetTerminalWidth(width int)                { DefaultLogger.SetTerminalWidth(width) }
+func SetNowFunc(now func() time.Time)           { DefaultLogger.SetNowFunc(now) }
 func EnableMultilineMode()                      { DefaultLogger.EnableMultilineMode() }
 func EnableSinglelineMode()                     { DefaultLogger.EnableSinglelineMode() }
 func Colorify(s string) string                  { return DefaultLogger.Colorify(s) }
 
 func AddAnsiColorCode(s string, code ColorCode) {
+	ansiColorCodesMutex.Lock()
+	defer ansiColorCodesMutex.Unlock()
 	ansiColorCodes[s] = code
 }
 
+// exitProcess is os.Exit, but for tests.
+var exitProcess = os.Exit
+
 func osExit() {
 	// Lock everything and hold the locks permanently. Close (and flush) all Loggers,
 	// then exit with error code 1.
-	// We only hold an RLock on the global mutex to prevent new Loggers from being
-	// added (and mutating the writers map) before we exit. And because use Lock
-	// would result in a deadlock when we try to RLock during a flush operation when
-	// we try to call getWriterState()
-	mutexGlobal.RLock()
-	for _, ws := range writers {
+	for _, ws := range allWriterStates() {
 		ws.lock()
 		ws.closeAll()
 	}
-	os.Exit(1)
+	exitProcess(1)
+}
+
+// allWriterStates returns every WriterState, each once, though several writers
+// may share one. The global mutex is only held long enough to copy the writers
+// map, as WriterStates must be locked before it, never after.
+func allWriterStates() []*WriterState {
+	mutexGlobal.RLock()
+	defer mutexGlobal.RUnlock()
+	seen := make(map[*WriterState]bool, len(writers))
+	states := make([]*WriterState, 0, len(writers))
+	for _, ws := range writers {
+		if !seen[ws] {
+			seen[ws] = true
+			states = append(states, ws)
+		}
+	}
+	return states
 }
 
 // Output writes the output for a logging event.  The string s contains
@@ -1369,3 +2360,8 @@ func osExit() {
 func Output(calldepth int, s string) error {
 	return DefaultLogger.Output(calldepth+1, s) // +1 for this frame.
 }
+
+// OutputBytes is like Output, but takes the text as a []byte.
+func OutputBytes(calldepth int, b []byte) error {
+	return DefaultLogger.OutputBytes(calldepth+1, b) // +1 for this frame.
+}
@@ -0,0 +1,153 @@
+package alog
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// Performance budget for the render path. These are the baselines measured
+// with `go test -run XXX -bench . -benchmem` on a single-core x86-64 Linux VM;
+// changes that push a benchmark well past its budget need a good reason.
+//
+//   BenchmarkPrintPlain              ~0.25µs/op   0 allocs/op
+//   BenchmarkPrintfPlainMode         ~0.35µs/op   0 allocs/op
+//   BenchmarkPrintfColored            ~2.7µs/op  19 allocs/op
+//   BenchmarkPrintfTemplateHeavy      ~6.9µs/op  48 allocs/op
+//   BenchmarkCompiledTemplate        ~0.45µs/op   0 allocs/op
+//   BenchmarkPrintPartial             ~0.7µs/op   5 allocs/op
+//   BenchmarkPrintConcurrentLoggers  ~0.35µs/op   0 allocs/op (per line, 100 loggers)
+//   BenchmarkUnchangedStatus          ~1.1µs/op   2 allocs/op (10 partial lines)
+//   BenchmarkDebugfHiddenParallel     ~5.5ns/op   0 allocs/op (hidden lines)
+
+func BenchmarkPrintPlain(b *testing.B) {
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		writer.Print("the quick brown fox jumps over the lazy dog\n")
+		buf.Reset()
+	}
+}
+
+func BenchmarkPrintfPlainMode(b *testing.B) {
+	var buf bytes.Buffer
+	writer := New(&buf, "", Ldate|Ltime)
+	defer writer.Close()
+	writer.HidePartialLines()
+	writer.DisableColor()
+	writer.DisableColorTemplate()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		writer.Printf("the quick brown fox jumps over the lazy dog\n")
+		buf.Reset()
+	}
+}
+
+func BenchmarkPrintfColored(b *testing.B) {
+	var buf bytes.Buffer
+	writer := New(&buf, "@(dim:$$) ", 0)
+	defer writer.Close()
+	writer.EnableColorTemplate()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		writer.Printf("the @(green:quick) brown @(red:fox) jumps over the %s dog\n", "lazy")
+		buf.Reset()
+	}
+}
+
+func BenchmarkPrintfTemplateHeavy(b *testing.B) {
+	var buf bytes.Buffer
+	writer := New(&buf, "@(dim:{isodate micros}) @(cyan:worker) ", 0)
+	defer writer.Close()
+	writer.EnableColorTemplate()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		writer.Printf("@(bright)%s@(r): @(green:%d) ok, @(yellow:%d) slow, @(red:%d) failed in @(magenta:%s) on @(blue:%s)\n",
+			"summary", 10, 2, 1, "1.5s", "host-1")
+		buf.Reset()
+	}
+}
+
+func BenchmarkCompiledTemplate(b *testing.B) {
+	var buf bytes.Buffer
+	writer := New(&buf, "@(dim:$$) ", 0)
+	defer writer.Close()
+	writer.EnableColorTemplate()
+	tmpl := writer.CompileTemplate("the @(green:quick) brown @(red:fox) jumps over the %s dog\n")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		tmpl.Printf("lazy")
+		buf.Reset()
+	}
+}
+
+func BenchmarkPrintPartial(b *testing.B) {
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	writer.SetTerminalWidth(80)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		writer.Replace("working on item ", i)
+		buf.Reset()
+	}
+}
+
+func BenchmarkPrintConcurrentLoggers(b *testing.B) {
+	const numLoggers = 100
+	var buf bytes.Buffer
+	writers := make([]*Logger, numLoggers)
+	for i := range writers {
+		writers[i] = New(&buf, fmt.Sprintf("[%d] ", i), 0)
+		defer writers[i].Close()
+	}
+	writers[0].SetTerminalWidth(200)
+	var wg sync.WaitGroup
+	b.ReportAllocs()
+	b.ResetTimer()
+	for _, writer := range writers {
+		wg.Add(1)
+		go func(writer *Logger) {
+			defer wg.Done()
+			for i := 0; i < b.N/numLoggers+1; i++ {
+				writer.Print("the quick brown fox jumps over the lazy dog\n")
+			}
+		}(writer)
+	}
+	wg.Wait()
+}
+
+func BenchmarkUnchangedStatus(b *testing.B) {
+	const numLoggers = 10
+	var buf bytes.Buffer
+	writers := make([]*Logger, numLoggers)
+	for i := range writers {
+		writers[i] = New(&buf, fmt.Sprintf("[%d] ", i), 0)
+		defer writers[i].Close()
+	}
+	writers[0].SetTerminalWidth(80)
+	for i, writer := range writers {
+		writer.Printf("worker %d is partway through its task", i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		writers[i%numLoggers].Print("")
+		buf.Reset()
+	}
+}
+
+func BenchmarkDebugfHiddenParallel(b *testing.B) {
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			writer.Debugf("the quick brown fox jumps over the %s dog", "lazy")
+		}
+	})
+}
@@ -2,8 +2,24 @@ package alog
 
 import (
 	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -254,6 +270,17 @@ func TestApplyTemplateEarly(t *testing.T) {
 	buf.Reset()
 }
 
+func TestColorTemplateRegexpPrefix(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "<red>$$</> ", 0)
+	defer writer.Close()
+	writer.EnableColorTemplate()
+	writer.SetColorTemplateRegexp(regexp.MustCompile("<([\\w,]+?)>(([^<]*?)</>)?"))
+	writer.Print("hi\n")
+	assert.Equal("\033[31m$$\033[39m hi\n", buf.String(), "the prefix is recompiled along with the regexp")
+}
+
 func TestCarriageReturns(t *testing.T) {
 	assert := assert.New(t)
 	var buf bytes.Buffer
@@ -387,11 +414,14 @@ func TestFlagElapsed(t *testing.T) {
 	var buf bytes.Buffer
 	var writer = New(&buf, "$$ ", Lelapsed)
 	defer writer.Close()
+	now := time.Now()
+	writer.SetNowFunc(func() time.Time { return now })
 	writer.Print("Testing... ")
 	assert.Equal("$$ Testing... ", buf.String())
 	buf.Reset()
+	now = now.Add(1500 * time.Millisecond)
 	writer.Print("done.\n")
-	assert.Equal("\r$$ (", buf.String()[:5])
+	assert.Equal("\r$$ ("+FormatDuration(1500*time.Millisecond)+") Testing... done.\n", buf.String())
 	buf.Reset()
 }
 
@@ -440,33 +470,2760 @@ func TestLoggerInception(t *testing.T) {
 	buf.Reset()
 }
 
-// XXX To make this really work, we'd need to stub out time.Now() in log.go.
-// func TestPrefix(t *testing.T) {
-// 	assert := assert.New(t)
-// 	testEquivalence := func(template string, flags int) {
-// 		var buf1 bytes.Buffer
-// 		writer1 := New(&buf1, template, 0)
-// 		defer writer1.Close()
-// 		var buf2 bytes.Buffer
-// 		writer2 := New(&buf2, "", flags)
-// 		defer writer2.Close()
-// 		readBuf1 := func() string {
-// 			defer buf1.Reset()
-// 			return buf1.String()
-// 		}
-// 		readBuf2 := func() string {
-// 			defer buf2.Reset()
-// 			return buf2.String()
-// 		}
-// 		writer1.Printf("Hi\nHello")
-// 		writer2.Printf("Hi\nHello")
-// 		assert.Equal(readBuf1(), readBuf2())
-// 	}
-// 	testEquivalence("{date} {time} ", Ldate|Ltime)
-// 	testEquivalence("{date} {time micros} ", Ldate|Ltime|Lmicroseconds)
-// 	testEquivalence("{isodate} ", Lisodate)
-// 	testEquivalence("{isodate micros} ", Lisodate|Lmicroseconds)
-// }
+func TestDestroy(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	var writer1 = New(&buf, "", 0)
+	var writer2 = New(&buf, "", 0)
+	writer1.Print("unfinished")
+	writer1.Destroy()
+	assert.Equal("unfinished\n", buf.String(), "Destroy should flush like Close")
+	buf.Reset()
+	mutexGlobal.RLock()
+	_, ok := writers[&buf]
+	mutexGlobal.RUnlock()
+	assert.True(ok, "writer state is kept while another Logger still uses it")
+	writer2.Destroy()
+	mutexGlobal.RLock()
+	_, ok = writers[&buf]
+	mutexGlobal.RUnlock()
+	assert.False(ok, "writer state is released along with the last Logger")
+	writer2.Destroy()
+	assert.Equal("", buf.String())
+}
+
+func TestReleaseWriter(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	var writer = New(&buf, "", 0)
+	defer writer.Close()
+	writer.SetTerminalWidth(40)
+	writer.Print("partial")
+	ReleaseWriter(&buf)
+	assert.Equal("partial\n", buf.String(), "ReleaseWriter should flush partial lines")
+	mutexGlobal.RLock()
+	_, ok := writers[&buf]
+	mutexGlobal.RUnlock()
+	assert.False(ok)
+}
+
+func TestConcurrentWriters(t *testing.T) {
+	assert := assert.New(t)
+	bufs := make([]bytes.Buffer, 8)
+	var wg sync.WaitGroup
+	for i := range bufs {
+		wg.Add(1)
+		go func(buf *bytes.Buffer) {
+			defer wg.Done()
+			writer := New(buf, "", 0)
+			defer writer.Destroy()
+			writer.EnableColorTemplate()
+			for j := 0; j < 100; j++ {
+				writer.Printf("@(green:line) %d\n", j)
+			}
+		}(&bufs[i])
+	}
+	wg.Wait()
+	for i := range bufs {
+		assert.Equal(100, strings.Count(bufs[i].String(), "\n"), "Loggers on separate writers should not interfere")
+	}
+}
+
+func TestConcurrentCallers(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	var writer = New(&buf, "", Lshortfile)
+	defer writer.Close()
+	writer.HidePartialLines()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				writer.Printf("line %d\n", j)
+			}
+		}()
+	}
+	wg.Wait()
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		assert.True(strings.HasPrefix(line, "log_test.go:"), "every line should carry its own caller: %q", line)
+	}
+}
+
+func TestSGRScanner(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("plain", string(Uncolorize([]byte("plain"))))
+	assert.Equal("red and bold", string(Uncolorize([]byte("\033[31mred\033[39m and \033[1;33mbold\033[0m"))))
+	assert.Equal("lone \033 and \033[31 stay", string(Uncolorize([]byte("lone \033 and \033[31 stay"))))
+	assert.Equal(3, VisibleStringLen([]byte("\033[1;31mabc\033[m")))
+	codes := getActiveAnsiCodes([]byte("\033[1;31mabc"))
+	assert.Equal(1, codes.intensity, "multiple params in one sequence are all applied")
+	assert.Equal(31, codes.forecolor)
+	codes = getActiveAnsiCodes([]byte("\033[1;31mabc\033[m"))
+	assert.False(codes.anyActive(), "an empty parameter list resets")
+	assert.Equal("\033[32mab", string(trimString([]byte("\033[32mabc\033[39m"), 2)))
+}
+
+var uncolorizeRegexp = regexp.MustCompile("\033\\[(\\d+(?:;\\d+)*)m")
+
+var benchmarkColoredLine = []byte("\033[2m2016-01-02T03:04:05\033[0m \033[32mINFO\033[39m the quick \033[1;31mbrown\033[0m fox")
+
+func BenchmarkUncolorizeRegexp(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		uncolorizeRegexp.ReplaceAll(benchmarkColoredLine, bytesEmpty)
+	}
+}
+
+func BenchmarkUncolorizeScanner(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Uncolorize(benchmarkColoredLine)
+	}
+}
+
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(p)
+}
+
+func TestSingleWritePerUpdate(t *testing.T) {
+	assert := assert.New(t)
+	var buf countingWriter
+	var writer1 = New(&buf, "", 0)
+	defer writer1.Close()
+	var writer2 = New(&buf, "", 0)
+	defer writer2.Close()
+	writer1.Print("Testing...")
+	writer2.Print("Writing Code...")
+	buf.Reset()
+	buf.writes = 0
+	writer2.Print(" done.\nmore")
+	assert.Equal("\rWriting Code... done.       \nTesting... | more", buf.String())
+	assert.Equal(1, buf.writes, "each update should be issued as a single Write")
+}
+
+func TestAsync(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	writer.SetAsync(16, OverflowBlock)
+	for i := 0; i < 100; i++ {
+		writer.Printf("line %d\n", i)
+	}
+	writer.Print("partial")
+	writer.Flush()
+	assert.Equal(101, strings.Count(buf.String(), "\n"), "Flush should wait for queued messages")
+	assert.True(strings.HasSuffix(buf.String(), "line 99\npartial\n"))
+	writer.Close()
+	assert.Error(writer.Output(1, "after close\n"))
+}
+
+type gateWriter struct {
+	bytes.Buffer
+	gate chan struct{}
+}
+
+func (w *gateWriter) Write(p []byte) (int, error) {
+	<-w.gate
+	return w.Buffer.Write(p)
+}
+
+// slowWriter takes delay over each write, and signals started as it begins.
+type slowWriter struct {
+	mutex   sync.Mutex
+	buf     bytes.Buffer
+	delay   time.Duration
+	started chan struct{}
+}
+
+func (w *slowWriter) Write(p []byte) (int, error) {
+	select {
+	case w.started <- struct{}{}:
+	default:
+	}
+	time.Sleep(w.delay)
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *slowWriter) String() string {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.buf.String()
+}
+
+func TestAsyncSlowWriter(t *testing.T) {
+	assert := assert.New(t)
+	out := &slowWriter{delay: 200 * time.Millisecond, started: make(chan struct{}, 1)}
+	writer := New(out, "", 0)
+	defer writer.Close()
+	writer.SetAsync(16, OverflowBlock)
+	writer.Print("one\n")
+	<-out.started
+	// As when some other Logger's settings change, the next Print has to
+	// refresh its snapshot
+	configChanged()
+	start := time.Now()
+	writer.Print("two\n")
+	writer.Print("three\n")
+	assert.True(time.Since(start) < 100*time.Millisecond, "Print waited %s on the writer", time.Since(start))
+	writer.Flush()
+	assert.Equal("one\ntwo\nthree\n", out.String())
+}
+
+func TestAsyncDropOldest(t *testing.T) {
+	assert := assert.New(t)
+	buf := &gateWriter{gate: make(chan struct{})}
+	writer := New(buf, "", 0)
+	writer.SetAsync(2, OverflowDropOldest)
+	for i := 0; i < 10; i++ {
+		writer.Printf("line %d\n", i)
+	}
+	assert.True(writer.AsyncDropped() > 0)
+	close(buf.gate)
+	writer.Close()
+	assert.True(strings.HasSuffix(buf.String(), "line 9\n"), "the newest messages are kept")
+	assert.True(strings.Count(buf.String(), "\n") < 10, "older messages are dropped rather than blocking")
+}
+
+func TestBuffered(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	writer.SetBuffered(4096, 0)
+	writer.Print("one\ntwo\n")
+	assert.Equal("", buf.String(), "output to non-terminals is held back")
+	writer.Flush()
+	assert.Equal("one\ntwo\n", buf.String())
+	buf.Reset()
+	writer.SetBuffered(4096, time.Millisecond)
+	writer.Print("three\n")
+	time.Sleep(50 * time.Millisecond)
+	buffered := getWriterState(&buf).buffered
+	buffered.mutex.Lock()
+	assert.Equal("three\n", buf.String(), "the interval flushes on its own")
+	buffered.mutex.Unlock()
+	buf.Reset()
+	writer.SetBuffered(4096, 0)
+	other := New(&buf, "", 0)
+	defer other.Close()
+	writer.Print("four\n")
+	other.Print("five\n")
+	writer.Print("six\n")
+	assert.Equal("", buf.String(), "other Loggers on the same writer share the buffer")
+	other.Flush()
+	assert.Equal("four\nfive\nsix\n", buf.String(), "and keep their place in it")
+}
+
+func TestPartialLineMemory(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	writer.HidePartialLines()
+	chunk := strings.Repeat("x", 1000)
+	for i := 0; i < 1000; i++ {
+		writer.Print(chunk + "\n" + chunk)
+	}
+	assert.True(cap(writer.buf) < 64*1024, "written lines should not be retained: cap=%d", cap(writer.buf))
+	buf.Reset()
+	writer.Print("\n")
+	writer.SetMaxPartialLineLength(4096)
+	for i := 0; i < 1000; i++ {
+		writer.Print(chunk)
+	}
+	assert.True(len(writer.buf) <= 4096, "partial lines are capped: len=%d", len(writer.buf))
+	writer.Print("\n")
+	assert.True(strings.HasSuffix(buf.String(), "x...\n"), "truncated lines end with an ellipsis")
+
+	buf.Reset()
+	writer.SetMaxPartialLineLength(10)
+	writer.Print("abcde\033[31mfghij")
+	writer.Print("\n")
+	assert.Equal("abcde...\n", buf.String(), "escape sequences aren't cut in half")
+}
+
+func TestCarryColors(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	var writer = New(&buf, "$$ ", 0)
+	defer writer.Close()
+	writer.EnableCarryColors()
+	writer.Print("\033[1;31mbright red\nstill bright red\033[39m\nonly bright\033[0m\nplain\n")
+	assert.Equal("$$ \033[1;31mbright red\033[0m\n"+
+		"$$ \033[1m\033[31mstill bright red\033[39m\033[0m\n"+
+		"$$ \033[1monly bright\033[0m\n"+
+		"$$ plain\n", buf.String())
+
+	buf.Reset()
+	writer.DisableCarryColors()
+	writer.Print("\033[31mred\n")
+	writer.EnableCarryColors()
+	writer.Print("plain\n")
+	assert.Equal("$$ \033[31mred\033[39m\n$$ plain\n", buf.String(), "nothing is carried over from while it was off")
+}
+
+func TestPlainModeAllocations(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "$$ ", Ldate|Ltime)
+	defer writer.Close()
+	writer.HidePartialLines()
+	writer.DisableColor()
+	writer.DisableColorTemplate()
+	writer.Printf("warm up the pools\n")
+	allocs := testing.AllocsPerRun(100, func() {
+		writer.Printf("a plain line\n")
+		buf.Reset()
+	})
+	if !raceEnabled {
+		assert.Equal(0.0, allocs, "plain lines should not allocate")
+	}
+	buf.Reset()
+	writer.Print("partial, ")
+	writer.Print("then whole\n")
+	assert.True(strings.HasPrefix(buf.String(), "$$ "))
+	assert.True(strings.HasSuffix(buf.String(), " partial, then whole\n"), "partial lines still work alongside the fast path")
+}
+
+func TestPrintBytes(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "$$ ", Lshortfile)
+	defer writer.Close()
+	writer.EnableAutoNewlines()
+	data := []byte("from a pipe\nand the rest")
+	_, _, line, _ := runtime.Caller(0)
+	writer.PrintBytes(data[:11])
+	writer.OutputBytes(1, data[:12])
+	writer.PrintBytes(data[12:])
+	assert.Equal("from a pipe\nand the rest", string(data), "the caller's bytes are left alone")
+	assert.Equal(fmt.Sprintf("$$ log_test.go:%d: from a pipe\n", line+1)+
+		fmt.Sprintf("$$ log_test.go:%d: from a pipe\n", line+2)+
+		fmt.Sprintf("$$ log_test.go:%d: and the rest\n", line+3), buf.String())
+}
+
+func TestUnchangedStatus(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer1 := New(&buf, "", 0)
+	defer writer1.Close()
+	writer2 := New(&buf, "", 0)
+	defer writer2.Close()
+	writer3 := New(&buf, "", 0)
+	defer writer3.Close()
+	writer1.SetTerminalWidth(30)
+	writer1.Print("first partial line")
+	writer2.Print("second partial line")
+	buf.Reset()
+	writer1.Print("")
+	assert.Equal("", buf.String(), "nothing changed, so nothing is redrawn")
+	writer3.Print("a whole line\n")
+	assert.Equal("\ra whole line                 \nfirst part... | second par...", buf.String(),
+		"the status is redrawn after being written over")
+
+	// Each partial line is formatted again only once something in it changes
+	buf.Reset()
+	writer2.SetPrefix("2 ")
+	writer1.Print("")
+	assert.Equal("\rfirst part... | 2 second p...", buf.String())
+	buf.Reset()
+	writer2.SetFlags(Llevel)
+	writer1.Print("")
+	assert.Contains(buf.String(), "| 2 \x1b[37m\x1b[44m[INFO ]")
+}
+
+func TestCompileTemplate(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	writer.EnableColorTemplate()
+	ok := writer.CompileTemplate("@(green:OK) %s\n")
+	assert.Equal("\033[32mOK\033[39m %s\n", ok.String())
+	assert.Equal("\033[32mOK\033[39m @(red:not a template)\n", ok.Sprintf("@(red:not a template)"))
+	ok.Printf("uploaded 3 files")
+	assert.Equal("\033[32mOK\033[39m uploaded 3 files\n", buf.String())
+	buf.Reset()
+	writer.DisableColorTemplate()
+	assert.Equal("@(green:OK) %s\n", writer.CompileTemplate("@(green:OK) %s\n").String())
+}
+
+func printViaWrapper(l *Logger, s string)  { l.Print(s) }
+func printfViaWrapper(l *Logger, s string) { l.Printf("%s", s) }
+
+func TestCallDepthOffset(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", Lshortfile)
+	defer writer.Close()
+	writer.SetCallDepthOffset(1)
+	_, _, line, _ := runtime.Caller(0)
+	printViaWrapper(writer, "hi\n")
+	printfViaWrapper(writer, "there\n")
+	assert.Equal(fmt.Sprintf("log_test.go:%d: hi\nlog_test.go:%d: there\n", line+1, line+2), buf.String())
+}
+
+func TestAddOutput(t *testing.T) {
+	assert := assert.New(t)
+	var buf, plain, warnings, events bytes.Buffer
+	writer := New(&buf, "@(cyan:$$) ", 0)
+	defer writer.Close()
+	writer.EnableColorTemplate()
+	writer.AddOutput(&plain, OutputOptions{StripColor: true})
+	writer.AddOutput(&warnings, OutputOptions{MinLevel: LevelWarn})
+	writer.AddOutput(&events, OutputOptions{MinLevel: LevelDebug, Encoder: JSONEncoder{}})
+	writer.Printf("@(green:one)")
+	assert.Equal("", plain.String(), "partial lines stay on the terminal")
+	writer.Printf(" two\n")
+	writer.Debugf("hidden")
+	writer.Warnf("@(yellow:careful)")
+	writer.Error("@(red:%s)", "oops")
+	assert.Equal("\033[36m$$\033[39m \033[32mone\033[39m two\n"+
+		"\033[36m$$\033[39m \033[33mcareful\033[39m\n"+
+		"\033[36m$$\033[39m \033[31moops\033[39m\n", buf.String())
+	assert.Equal("$$ one two\n$$ careful\n$$ oops\n", plain.String())
+	assert.Equal("\033[36m$$\033[39m \033[33mcareful\033[39m\n"+
+		"\033[36m$$\033[39m \033[31moops\033[39m\n", warnings.String())
+	lines := strings.Split(strings.TrimSuffix(events.String(), "\n"), "\n")
+	assert.Equal(3, len(lines), "the debug line is dropped before reaching any output")
+	assert.Contains(lines[0], `"level":"info","msg":"one two"}`)
+	assert.Contains(lines[2], `"level":"error","msg":"oops"}`)
+
+	writer.RemoveOutput(&plain)
+	writer.SetLevel(LevelDebug)
+	writer.Debugf("shown")
+	assert.Equal("$$ one two\n$$ careful\n$$ oops\n", plain.String())
+	assert.Contains(events.String(), `"level":"debug","msg":"shown"}`)
+}
+
+func TestStripWriter(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	w := NewStripWriter(&buf)
+	input := "\033[1;31mred\033[0m, \033[1Aup, \033]0;title\a\033(Bplain\033[?25l\r\n"
+	for split := 0; split <= len(input); split++ {
+		buf.Reset()
+		w.Write([]byte(input[:split]))
+		w.Write([]byte(input[split:]))
+		assert.Equal("red, up, plain\r\n", buf.String(), "split at %d", split)
+	}
+
+	var colored bytes.Buffer
+	writer := New(io.MultiWriter(&colored, w), "", 0)
+	defer writer.Close()
+	buf.Reset()
+	writer.EnableColorTemplate()
+	writer.Printf("@(green:done)\n")
+	assert.Equal("\033[32mdone\033[39m\n", colored.String())
+	assert.Equal("done\n", buf.String())
+}
+
+func TestErrorOutput(t *testing.T) {
+	assert := assert.New(t)
+	var stdout, stderr bytes.Buffer
+	writer := New(&stdout, "$$ ", 0)
+	defer writer.Close()
+	status := New(&stdout, "", 0)
+	defer status.Close()
+	writer.SetErrorOutput(&stderr, LevelWarn)
+	status.Printf("working")
+	writer.Printf("info\n")
+	writer.Warnf("this is taking a while")
+	writer.Error("failed")
+	status.Printf(", done\n")
+	assert.Equal("working\r$$ info\nworking, done\n", stdout.String())
+	assert.Equal("$$ this is taking a while\n$$ failed\n", stderr.String())
+
+	// Errors go around a partial line on the error output, too
+	stderr.Reset()
+	errStatus := New(&stderr, "", 0)
+	defer errStatus.Close()
+	errStatus.Printf("checking")
+	writer.Error("failed again")
+	errStatus.Printf(", done\n")
+	assert.Equal("checking\r$$ failed again\nchecking, done\n", stderr.String())
+}
+
+func TestSetOutputMigration(t *testing.T) {
+	assert := assert.New(t)
+	var buf1, buf2 bytes.Buffer
+	writer := New(&buf1, "$$ ", 0)
+	defer writer.Close()
+	other := New(&buf1, "", 0)
+	defer other.Close()
+	other.Printf("other")
+	writer.Printf("halfway")
+	buf1.Reset()
+	writer.SetOutput(&buf2)
+	assert.Equal("\rother             ", buf1.String(), "the partial line is cleared from the old writer")
+	assert.Equal("$$ halfway", buf2.String(), "and redrawn on the new one")
+	writer.Printf(" there\n")
+	assert.Equal("$$ halfway there\n", buf2.String())
+}
+
+func TestRingBuffer(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "$$ ", 0)
+	defer writer.Close()
+	writer.EnableColorTemplate()
+	ring := NewRingBuffer(3)
+	writer.SetRingBuffer(ring, true)
+	writer.Debugf("connecting")
+	writer.Printf("starting\n")
+	writer.Debugf("@(red:retrying)\nstill retrying")
+	writer.Debugf("giving up")
+	assert.Equal("$$ starting\n", buf.String())
+	var messages []string
+	for _, e := range ring.Entries() {
+		messages = append(messages, e.Level.String()+" "+e.Message)
+	}
+	assert.Equal([]string{"debug retrying", "debug still retrying", "debug giving up"}, messages)
+
+	var dump bytes.Buffer
+	writer.DumpRecent(&dump)
+	assert.Equal(3, strings.Count(dump.String(), " debug "))
+	assert.Contains(dump.String(), " debug giving up\n")
+
+	buf.Reset()
+	writer.Error("failed")
+	lines := strings.Split(buf.String(), "\n")
+	assert.Equal(5, len(lines))
+	assert.True(strings.HasPrefix(lines[0], "  ") && strings.HasSuffix(lines[0], " debug retrying"), lines[0])
+	assert.True(strings.HasSuffix(lines[2], " debug giving up"), lines[2])
+	assert.Equal("$$ failed", lines[3])
+	assert.Equal(3, len(ring.Entries()), "the lines dumped are kept")
+
+	buf.Reset()
+	writer.Debugf("one more try")
+	writer.Error("failed again")
+	assert.Regexp(`^  \S+ debug one more try\n\$\$ failed again\n$`, buf.String(), "each line is only dumped once")
+}
+
+func TestCrashReport(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	writer.SetRingBuffer(NewRingBuffer(10), false)
+	writer.SetCrashReportDir(dir)
+	writer.Debugf("some context")
+	assert.Panics(func() { writer.Panicf("bad %s", "news") })
+	files, _ := filepath.Glob(filepath.Join(dir, "*-crash-*.txt"))
+	if !assert.Equal(1, len(files)) {
+		return
+	}
+	assert.Equal("bad news\ncrash report written to "+files[0]+"\n", buf.String())
+	report, _ := os.ReadFile(files[0])
+	assert.Contains(string(report), "\nbad news\n")
+	assert.Contains(string(report), " debug some context\n")
+	assert.Contains(string(report), "TestCrashReport")
+	assert.Contains(string(report), runtime.Version())
+}
+
+type panicOnString struct{}
+
+func (panicOnString) String() string { panic("formatted") }
+
+func TestDiscard(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotPanics(func() {
+		Discard.Printf("%s", panicOnString{})
+		Discard.Print(panicOnString{})
+		Discard.Warnf("%s", panicOnString{})
+	}, "Discard doesn't even format its arguments")
+	assert.Equal(0.0, testing.AllocsPerRun(10, func() { Discard.Printf("%d", 42) }))
+
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	DisableAll()
+	writer.Printf("muted\n")
+	writer.Error("also muted")
+	EnableAll()
+	writer.Printf("back\n")
+	assert.Equal("back\n", buf.String())
+}
+
+func TestHold(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	other := New(&buf, "", 0)
+	defer other.Close()
+	writer.Printf("working")
+	buf.Reset()
+	writer.Hold()
+	assert.Equal("\r       \r", buf.String(), "the partial line is cleared")
+	buf.Reset()
+	writer.Hold()
+	other.Printf("while held\n")
+	writer.Printf(", still")
+	writer.Release()
+	assert.Equal("", buf.String(), "nothing is written until the last Release")
+	writer.Release()
+	assert.Equal("while held\nworking, still", buf.String())
+	writer.Release()
+}
+
+func TestOverwriteWithWiderRunes(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	writer.HidePartialLines()
+	writer.Print("abcdef\rᚠᛇ\n")
+	writer.Print("abcdef\r\033[31mx\033[39m\n")
+	writer.Print("a\033\r\033[31\rb\xff\rcd\n")
+	assert.Equal("ᚠᛇcdef\n\033[31mx\033[39mbcdef\ncd31\n", buf.String())
+}
+
+func TestTimer(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	writer.EnableColorTemplate()
+	now := time.Now()
+	writer.SetNowFunc(func() time.Time { return now })
+	done := writer.Timer("load @(cyan:config)")
+	assert.Equal("load \033[36mconfig\033[39m... ", buf.String())
+	buf.Reset()
+	now = now.Add(1500 * time.Millisecond)
+	done()
+	assert.Equal("\033[33m1.50s\033[39m\n", buf.String(), "only the duration needs to be added")
+	buf.Reset()
+	writer.SetTimerThresholds(time.Minute, time.Hour)
+	writer.Timer("quick")()
+	assert.Equal("quick... \033[32m0.0ms\033[39m\n", buf.String())
+}
+
+func TestSlowThresholds(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	writer.EnableColorTemplate()
+	writer.HidePartialLines()
+	var warnings bytes.Buffer
+	writer.AddOutput(&warnings, OutputOptions{MinLevel: LevelWarn, StripColor: true})
+	now := time.Now()
+	writer.SetNowFunc(func() time.Time { return now })
+	writer.SetSlowThresholds(time.Second, time.Minute)
+	for _, elapsed := range []time.Duration{100 * time.Millisecond, 2 * time.Second, 2 * time.Minute} {
+		done := writer.Timer("@(cyan:step)")
+		now = now.Add(elapsed)
+		done()
+	}
+	assert.Equal("\033[36mstep\033[39m... \033[32m100ms\033[39m\n"+
+		"\033[33mstep... 2.00s\033[39m\n"+
+		"\033[31mstep...  120s\033[39m\n", buf.String())
+	assert.Equal("step... 2.00s\nstep...  120s\n", warnings.String())
+
+	buf.Reset()
+	warnings.Reset()
+	step := writer.Step("deploy")
+	now = now.Add(2 * time.Second)
+	step.Done()
+	assert.Equal("\033[33mdeploy... ok 2.00s\033[39m\n", buf.String(), "Steps are timed like Timers")
+	assert.Equal("deploy... ok 2.00s\n", warnings.String())
+}
+
+func TestStopwatch(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	now := time.Now()
+	writer.SetNowFunc(func() time.Time { return now })
+	sw := writer.Stopwatch("migration")
+	now = now.Add(1500 * time.Millisecond)
+	assert.Equal(1500*time.Millisecond, sw.Lap("schema"))
+	now = now.Add(3 * time.Second)
+	sw.Lap("data and indexes")
+	now = now.Add(500 * time.Millisecond)
+	assert.Equal(5*time.Second, sw.Stop())
+	assert.Equal("migration took 5.00s\n"+
+		"  schema            1.50s   30%\n"+
+		"  data and indexes  3.00s   60%\n"+
+		"  (rest)            500ms   10%\n", buf.String())
+}
+
+func TestBanner(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "[x] ", 0)
+	defer writer.Close()
+	writer.EnableColorTemplate()
+	writer.SetTerminalWidth(21)
+	writer.EnableUnicode()
+	writer.Rule()
+	assert.Equal("[x] \033[34m────────────────\033[39m\n", buf.String())
+	buf.Reset()
+	writer.Banner("@(cyan:v1.2.3)")
+	assert.Equal("[x] \033[34m════════════════\033[39m\n"+
+		"[x]      \033[1m\033[36mv1.2.3\033[39m\033[0m\n"+
+		"[x] \033[34m════════════════\033[39m\n", buf.String())
+	buf.Reset()
+	writer.Banner("Deploying something rather long")
+	assert.Equal("[x] Deploying som...", strings.Split(string(Uncolorize(buf.Bytes())), "\n")[1])
+}
+
+func TestBox(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	writer.EnableColorTemplate()
+	writer.SetTerminalWidth(21)
+	writer.EnableUnicode()
+	writer.Box(ColorYellow, "Done!", "@(cyan:see the notes below)")
+	assert.Equal("\033[33m╭───────────────╮\033[39m\n"+
+		"\033[33m│\033[39m Done!         \033[33m│\033[39m\n"+
+		"\033[33m│\033[39m \033[36msee the notes\033[39m \033[33m│\033[39m\n"+
+		"\033[33m│\033[39m \033[36mbelow\033[39m         \033[33m│\033[39m\n"+
+		"\033[33m╰───────────────╯\033[39m\n", buf.String())
+	buf.Reset()
+	writer.DisableUnicode()
+	writer.DisableColor()
+	writer.Box(ColorRed, "ok")
+	assert.Equal("+----+\n| ok |\n+----+\n", buf.String())
+}
+
+func TestWrapLine(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal([]string{""}, wrapLine("", 5))
+	assert.Equal([]string{"one", "two", "three"}, wrapLine("one two three", 5))
+	assert.Equal([]string{"a", "verylo", "ngword", "b c"}, wrapLine("a verylongword b c", 6))
+	assert.Equal([]string{"\033[31mab\033[39m", "\033[31mcd\033[39m"}, wrapLine("\033[31mab cd\033[39m", 3))
+}
+
+func TestList(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	writer.SetTerminalWidth(21)
+	writer.EnableUnicode()
+	writer.List([]string{"commit your changes", "push"}, ColorNone, ColorGreen)
+	assert.Equal("  • commit your\n"+
+		"    changes\n"+
+		"\033[32m  • push\033[39m\n", buf.String())
+	buf.Reset()
+	items := make([]string, 10)
+	for i := range items {
+		items[i] = "step"
+	}
+	items[9] = "one last step to go"
+	writer.NumberedList(items)
+	lines := strings.Split(buf.String(), "\n")
+	assert.Equal("   1. step", lines[0])
+	assert.Equal("  10. one last step", lines[9])
+	assert.Equal("      to go", lines[10])
+}
+
+func TestColumns(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	writer.SetTerminalWidth(50)
+	writer.DisableUnicode()
+	writer.DisableColor()
+	columns := writer.Columns(2)
+	defer columns[0].Close()
+	defer columns[1].Close()
+	columns[1].SetPrefix("db: ")
+	columns[0].Printf("web: starting up\n")
+	columns[1].Printf("ready to accept connections\n")
+	assert.Equal("web: starting up        |                        \n"+
+		"                        | db: ready to accept    \n"+
+		"                        | connections            \n", buf.String())
+	buf.Reset()
+	writer.SetTerminalWidth(40)
+	columns[0].Printf("too narrow\n")
+	assert.Equal("too narrow\n", buf.String())
+}
+
+func TestAutoColor(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "web: ", 0)
+	defer writer.Close()
+	writer.EnableColorTemplate()
+	writer.EnableAutoColor()
+	writer.Printf("up\n")
+	assert.Equal(colorize("web: ", autoColor([]byte("web: ")))+"up\n", buf.String())
+	assert.Equal(autoColor([]byte("web: ")), autoColor([]byte("web: ")))
+	assert.NotEqual(autoColor([]byte("web: ")), autoColor([]byte("db: ")))
+	buf.Reset()
+	writer.SetPrefix("@(cyan:web:) ")
+	writer.Printf("up\n")
+	assert.Equal("\033[36mweb:\033[39m up\n", buf.String())
+	buf.Reset()
+	writer.DisableAutoColor()
+	writer.SetPrefix("web: ")
+	writer.Printf("up\n")
+	assert.Equal("web: up\n", buf.String())
+}
+
+func TestAlignPrefixes(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	web := New(&buf, "web: ", 0)
+	defer web.Close()
+	worker := New(&buf, "@(cyan:worker:) ", 0)
+	defer worker.Close()
+	worker.EnableColorTemplate()
+	AlignPrefixes(web, worker)
+	web.Printf("up\n")
+	worker.Printf("up\n")
+	assert.Equal("web:    up\n\033[36mworker:\033[39m up\n", buf.String())
+	buf.Reset()
+	web.SetPrefixWidth(0)
+	web.Printf("up\n")
+	assert.Equal("web: up\n", buf.String())
+}
+
+func TestPrefixPipe(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	r, w := io.Pipe()
+	done := make(chan error)
+	go func() { done <- writer.PrefixPipe(r, "web", "cyan") }()
+	w.Write([]byte("listening"))
+	w.Write([]byte(" on :8080\nGET /"))
+	w.Write([]byte("\n"))
+	w.Write([]byte("shutting down"))
+	w.Close()
+	assert.NoError(<-done)
+	assert.Equal("\033[36mweb |\033[39m listening on :8080\n"+
+		"\033[36mweb |\033[39m GET /\n"+
+		"\033[36mweb |\033[39m shutting down\n", buf.String())
+	assert.Equal("\033[1m\033[31mx\033[0m", colorize("x", parseStyle("bright, red,bogus")...))
+}
+
+func TestPrintTemplate(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	writer.PrintTemplate("deploy {service} x{n} to {env} {{literal} {\n", map[string]interface{}{
+		"service": "api",
+		"n":       3,
+	})
+	assert.Equal("deploy \033[36mapi\033[39m x\033[33m3\033[39m to \033[31m{env?}\033[39m {literal} {\n", buf.String())
+}
+
+func TestTranslator(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	writer.EnableColorTemplate()
+	catalog := map[string]string{
+		"%d file(s) copied\n": "@(cyan:%d) fichiers copiés\n",
+		"deploy {env}\n":      "déployer {env}\n",
+	}
+	writer.SetTranslator(func(msgID string, args ...interface{}) string {
+		if len(args) == 1 && args[0] == 1 {
+			return "%d fichier copié\n"
+		}
+		if format, ok := catalog[msgID]; ok {
+			return format
+		}
+		return msgID
+	})
+	writer.Printf("%d file(s) copied\n", 3)
+	writer.Printf("%d file(s) copied\n", 1)
+	writer.Printf("untranslated\n")
+	writer.PrintTemplate("deploy {env}\n", map[string]interface{}{"env": "prod"})
+	assert.Equal("\033[36m3\033[39m fichiers copiés\n"+
+		"1 fichier copié\n"+
+		"untranslated\n"+
+		"déployer \033[36mprod\033[39m\n", buf.String())
+	buf.Reset()
+	writer.SetTranslator(nil)
+	writer.Printf("%d file(s) copied\n", 3)
+	assert.Equal("3 file(s) copied\n", buf.String())
+}
+
+func TestMonotonicTiming(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	now := time.Now()
+	writer.SetNowFunc(func() time.Time { return now })
+	sw := writer.Stopwatch("step")
+	now = now.Add(-time.Minute) // the clock jumps back
+	assert.Equal(time.Duration(0), sw.Lap("one"), "durations are never negative")
+
+	start := time.Now()
+	assert.Equal(time.Second, writer.since(start, start.Add(time.Second)))
+	writer.DisableMonotonicTiming()
+	assert.Equal(time.Second, writer.since(start, start.Add(time.Second)))
+	assert.Equal(time.Duration(0), writer.since(start, start.Add(-time.Second)))
+}
+
+func TestFormatHint(t *testing.T) {
+	assert := assert.New(t)
+	writer := New(&bytes.Buffer{}, "", 0)
+	defer writer.Close()
+	writer.SetTerminalWidth(31)
+	e := writer.newEntry(1)
+	assert.Equal("\033[31merror: connection refused\033[39m\n"+
+		"\033[2m  hint: Check that the server\033[0m\n"+
+		"\033[2m        is running.\033[0m\n",
+		writer.formatHint(&e, errors.New("connection refused"), "Check that the server is running."))
+}
+
+func TestSummary(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	ResetCounts()
+	defer ResetCounts()
+	writer.Summary()
+	assert.Equal("\033[32mcompleted with no warnings or errors\033[39m\n", buf.String())
+	buf.Reset()
+	writer.Debugf("hidden")
+	writer.Warnf("careful")
+	writer.Warnf("careful")
+	writer.Warnf("careful")
+	assert.Equal(0, Count(LevelDebug))
+	assert.Equal(3, Count(LevelWarn))
+	writer.Error("oops")
+	assert.Equal(1, Count(LevelError))
+	buf.Reset()
+	writer.Summary()
+	assert.Equal("\033[31mcompleted with 3 warnings, 1 error\033[39m\n", buf.String())
+}
+
+func TestDeprecated(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	other := New(&buf, "", 0)
+	defer other.Close()
+	for i := 0; i < 3; i++ {
+		writer.Deprecated("flag --foo", "use --bar instead")
+		other.Deprecated("flag --foo", "use --bar instead")
+		writer.Oncef("slow disk", "disk %d is slow", i)
+	}
+	writer.Deprecated("flag --baz", "")
+	assert.Equal("\033[33mdeprecated:\033[39m flag --foo (use --bar instead)\n"+
+		"disk 0 is slow\n"+
+		"\033[33mdeprecated:\033[39m flag --baz\n", buf.String())
+}
+
+func TestErrWithCaller(t *testing.T) {
+	assert := assert.New(t)
+	writer := New(&bytes.Buffer{}, "", 0)
+	defer writer.Close()
+	s := writer.errWithCaller(1, errors.New("no such file"))
+	_, _, line, _ := runtime.Caller(0)
+	assert.Equal("\033[31merror: no such file\033[39m\n"+
+		fmt.Sprintf("\033[2m  at github.com/tillberg/ansi-log.TestErrWithCaller (log_test.go:%d)\033[0m\n", line-1), s)
+
+	// The offset may be changed while errors are being formatted
+	done := make(chan struct{})
+	go func() {
+		writer.SetCallDepthOffset(0)
+		close(done)
+	}()
+	assert.Contains(writer.errWithCaller(1, errors.New("again")), "TestErrWithCaller")
+	<-done
+}
+
+func TestRecoverAndLog(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	writer.DisableColor()
+	func() {
+		defer RecoverAndLog(writer)
+		writer.Printf("working")
+		panic("oh no")
+	}()
+	lines := strings.Split(buf.String(), "\n")
+	assert.Equal("working", lines[0])
+	assert.Equal("panic: oh no", lines[1])
+	assert.True(strings.HasPrefix(lines[2], "goroutine "), lines[2])
+	assert.Contains(lines[3], "TestRecoverAndLog.func", "the stack starts at the panicking function")
+	assert.NotContains(buf.String(), "runtime/debug.Stack")
+
+	assert.PanicsWithValue("again", func() {
+		defer RecoverAndLog(writer, RecoverRepanic)
+		panic("again")
+	})
+	assert.NotPanics(func() { defer RecoverAndLog(writer) }, "nothing to recover")
+}
+
+func TestForGoroutine(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "> ", 0)
+	defer writer.Close()
+	writer.DisableColor()
+	worker := writer.ForGoroutine("worker 3")
+	worker.Printf("fetching")
+	writer.Printf("main\n")
+	worker.Printf(" done\n")
+	worker.Destroy()
+	assert.Equal("> [worker 3] fetching\r> main               \n> [worker 3] fetching done\n", buf.String())
+
+	buf.Reset()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		l := writer.ForGoroutine("")
+		defer l.Destroy()
+		l.Printf("hi\n")
+	}()
+	<-done
+	assert.Regexp(`^> \[g\d+\] hi\n$`, buf.String())
+}
+
+func TestLogMemStats(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	writer.DisableColor()
+	runtime.GC()
+	writer.LogMemStats()
+	assert.Regexp(`^mem: heap [\d.]+[KMG]?B, sys [\d.]+[KMG]?B, \d+ goroutines, \d+ GCs, last pause \S+\n$`, buf.String())
+	buf.Reset()
+	writer.LogResourceUsage()
+	assert.True(strings.HasPrefix(buf.String(), "mem: heap "))
+	if runtime.GOOS == "linux" {
+		assert.Regexp(`, max rss [\d.]+[KMG]?B, cpu \S+ user \S+ sys\n$`, buf.String())
+	}
+	assert.Equal("512B", formatBytes(512))
+	assert.Equal("1.5KB", formatBytes(1536))
+	assert.Equal("12.3MB", formatBytes(12900000))
+}
+
+func TestLogEvery(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	var mutex sync.Mutex
+	n := 0
+	calls := make(chan struct{}, 10)
+	stop := writer.LogEvery(time.Millisecond, func() string {
+		mutex.Lock()
+		defer mutex.Unlock()
+		n++
+		if n <= 3 {
+			calls <- struct{}{}
+		}
+		if n == 2 {
+			return "checkpoint\n"
+		}
+		return fmt.Sprintf("tick %d", n)
+	})
+	for i := 0; i < 3; i++ {
+		<-calls
+	}
+	stop()
+	stop()
+	out := buf.String()
+	assert.True(strings.HasPrefix(out, "tick 1\rcheckpoint\ntick 3"), out)
+	assert.True(strings.HasSuffix(out, "\n"), "stop finishes off the status line")
+}
+
+func TestLevelBadges(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", Llevel)
+	defer writer.Close()
+	writer.Printf("started\n")
+	writer.Error("failed")
+	assert.Equal("\033[37m\033[44m[INFO ]\033[0m started\n"+
+		"\033[37m\033[41m[ERROR]\033[0m failed\n", buf.String())
+	buf.Reset()
+	writer.SetBadgeTheme(GlyphBadges)
+	writer.DisableColor()
+	writer.Warnf("careful")
+	assert.Equal("▲ careful\n", buf.String())
+	buf.Reset()
+	writer.SetBadgeTheme(&BadgeTheme{Labels: map[Level]string{LevelInfo: "i", LevelError: "err"}})
+	writer.Printf("ok\n")
+	writer.Warnf("careful")
+	assert.Equal("i   ok\nwarn careful\n", buf.String(), "unknown levels get their name")
+}
+
+func TestQuietAndVerbose(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	writer.SetQuiet(true)
+	writer.Printf("partial")
+	writer.Printf(" line\n")
+	writer.Warnf("careful")
+	assert.Equal("careful\n", buf.String())
+	buf.Reset()
+	writer.SetQuiet(false)
+	writer.Printf("partial")
+	assert.Equal("partial", buf.String(), "partial lines are back")
+	writer.Printf("\n")
+	buf.Reset()
+
+	writer.SetVerbose(true)
+	writer.Debugf("details")
+	_, _, line, _ := runtime.Caller(0)
+	assert.Equal(fmt.Sprintf("log_test.go:%d: details\n", line-1), buf.String())
+	buf.Reset()
+	writer.SetVerbose(false)
+	writer.Debugf("details")
+	writer.Printf("plain\n")
+	assert.Equal("plain\n", buf.String())
+
+	writer.SetLevel(LevelError)
+	writer.SetQuiet(true)
+	writer.SetQuiet(false)
+	writer.SetVerbose(true)
+	writer.SetVerbose(false)
+	assert.Equal(LevelError, writer.level, "the level from before is restored")
+	writer.SetLevel(LevelInfo)
+	writer.SetVerbose(true)
+	writer.SetQuiet(true)
+	assert.Equal(LevelWarn, writer.level)
+	writer.SetVerbose(false)
+	assert.Equal(LevelWarn, writer.level, "still quiet")
+	writer.SetQuiet(false)
+	assert.Equal(LevelInfo, writer.level)
+	writer.SetQuiet(true)
+	writer.SetVerbose(true)
+	writer.SetQuiet(false)
+	assert.Equal(LevelDebug, writer.level, "still verbose")
+	writer.SetVerbose(false)
+	assert.Equal(LevelInfo, writer.level)
+}
+
+func TestRegisterFlags(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	writer.EnableColorTemplate()
+	path := filepath.Join(t.TempDir(), "out.log")
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	writer.RegisterFlags(fs)
+	assert.NoError(fs.Parse([]string{"-log-file", path, "-log-format=json", "-no-color", "-log-level", "warn", "rest"}))
+	assert.Equal([]string{"rest"}, fs.Args())
+	writer.Printf("hidden\n")
+	writer.Warnf("@(yellow:careful)")
+	assert.Equal("careful\n", buf.String())
+	data, _ := os.ReadFile(path)
+	assert.Contains(string(data), `"level":"warn","msg":"careful"}`)
+
+	assert.Error(fs.Parse([]string{"-log-level", "loud"}))
+	assert.Error(fs.Parse([]string{"-log-format", "xml"}))
+	assert.NoError(fs.Parse([]string{"-v"}))
+	buf.Reset()
+	writer.Debugf("details")
+	assert.Contains(buf.String(), "log_test.go:")
+	assert.Error(fs.Parse([]string{"-q=maybe"}))
+	assert.Error(fs.Parse([]string{"-v=maybe"}))
+	buf.Reset()
+	writer.Debugf("still verbose")
+	assert.Contains(buf.String(), "still verbose")
+
+	writer.Close()
+	assert.Empty(writer.outputs)
+	assert.Empty(writer.closers)
+}
+
+func TestContext(t *testing.T) {
+	assert := assert.New(t)
+	writer := New(&bytes.Buffer{}, "", 0)
+	defer writer.Close()
+	ctx := NewContext(context.Background(), writer)
+	assert.Same(writer, FromContext(ctx))
+	assert.Same(DefaultLogger, FromContext(context.Background()))
+
+	fs := writer.FlagSet("log")
+	assert.NotNil(fs.Lookup("log-level"))
+	assert.NotNil(fs.Lookup("v"))
+}
+
+func TestHijackStdlib(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "> ", 0)
+	defer writer.Close()
+	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
+	log.SetPrefix("lib: ")
+	restore := writer.HijackStdlib()
+	writer.Printf("working")
+	log.Printf("hello from the stdlib")
+	writer.Printf(", done\n")
+	assert.Equal("> working\r> hello from the stdlib\n> working, done\n", buf.String())
+
+	buf.Reset()
+	writer.SetFlags(Lshortfile)
+	restore()
+	restore = writer.HijackStdlib()
+	log.Print("with caller")
+	_, _, line, _ := runtime.Caller(0)
+	assert.Equal(fmt.Sprintf("> log_test.go:%d: with caller\n", line-1), buf.String())
+	restore()
+	assert.Equal(log.LstdFlags|log.Lmicroseconds, log.Flags())
+	assert.Equal("lib: ", log.Prefix())
+	log.SetFlags(log.LstdFlags)
+	log.SetPrefix("")
+	log.SetOutput(os.Stderr)
+}
+
+func TestCaptureStdout(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	writer.DisableColor()
+	restore := writer.CaptureStdout()
+	fmt.Println("stray output")
+	fmt.Fprint(os.Stderr, "stray error")
+	restore()
+	restore()
+	out := buf.String()
+	assert.Contains(out, "stdout | stray output\n")
+	assert.Contains(out, "stderr | stray error\n")
+}
+
+func TestAnsiDebug(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "@(green:$) ", 0)
+	defer writer.Close()
+	writer.EnableColorTemplate()
+	writer.DisableColor()
+	writer.EnableAnsiDebug()
+	writer.Printf("@(red:red) then @(bright)bright\n")
+	assert.Equal("⟨ESC[32m⟩$⟨ESC[39m⟩ ⟨ESC[31m⟩red⟨ESC[39m⟩ then ⟨ESC[1m⟩bright ⟨active: intensity=1⟩\n", buf.String())
+	buf.Reset()
+	writer.DisableAnsiDebug()
+	writer.Printf("@(red:red)\n")
+	assert.Equal("$ red\n", buf.String())
+}
+
+func TestStrictTemplates(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	writer.EnableColorTemplate()
+	writer.Printf("@(grene:ok)\n")
+	assert.Equal("@(grene:ok)\n", buf.String())
+	buf.Reset()
+	writer.EnableStrictTemplates()
+	writer.Printf("@(gren:ok)\n")
+	writer.Printf("@(bright,gren:ok) @(red:fine)\n")
+	assert.Equal("unknown color template code \"gren\" in \"@(gren:ok)\\n\"\n"+
+		"@(gren:ok)\n"+
+		"@(bright,gren:ok) \033[31mfine\033[39m\n", buf.String())
+}
+
+func TestValidateTemplate(t *testing.T) {
+	assert := assert.New(t)
+	assert.NoError(ValidateTemplate("plain"))
+	assert.NoError(ValidateTemplate("@(red:ok) @(bright,cyan)on@(r) %d"))
+	assert.EqualError(ValidateTemplate("@(gren:ok)"), `invalid template "@(gren:ok)": unknown color code "gren" at offset 0`)
+	assert.EqualError(ValidateTemplate("x @(red:oops"), `invalid template "x @(red:oops": unclosed template at offset 2`)
+	err := ValidateTemplate("@(red:a @(bright:b)) @(grey,blu)")
+	if assert.IsType(&TemplateError{}, err) {
+		assert.Equal([]string{
+			"template nested inside another at offset 0",
+			`unknown color code "blu" at offset 21`,
+		}, err.(*TemplateError).Problems)
+	}
+}
+
+func TestNarrowMode(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "[a-long-prefix] ", 0)
+	defer writer.Close()
+	writer.SetTerminalWidth(21)
+	writer.Print("downloading 12/40")
+	assert.Equal("[a-long-prefix] d...", buf.String())
+	buf.Reset()
+	writer.SetNarrowMode(NarrowDropHeader)
+	writer.Print(" ")
+	assert.Equal("\rdownloading 12/40   ", buf.String())
+	buf.Reset()
+	writer.SetNarrowMode(NarrowTruncateLeft)
+	writer.Print("files")
+	assert.Equal("\r...ading 12/40 files", buf.String())
+	buf.Reset()
+	writer.SetNarrowMode(NarrowClamp)
+	writer.Print(".")
+	assert.Equal("\r[a-long-prefix] downloa...", buf.String())
+	writer.SetTerminalWidth(2)
+	writer.SetNarrowMode(NarrowTruncate)
+	buf.Reset()
+	writer.Print("x")
+	assert.Equal("[", buf.String())
+}
+
+func TestStatusSegments(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	writer.SetTerminalWidth(31)
+	writer.Print("Installing")
+	buf.Reset()
+	writer.SetRightStatus("%d%%", 42)
+	assert.Equal("                 42%", buf.String())
+	buf.Reset()
+	writer.SetCenterStatus("3/7")
+	assert.Equal("\rInstalling   3/7           42%", buf.String())
+	buf.Reset()
+	writer.Print(" the-package-with-a-long-name")
+	assert.Equal("\rInstalling the-pack... 3/7 42%", buf.String())
+
+	// Sharing the status line, the text just follows the partial line
+	var buf2 bytes.Buffer
+	writer2 := New(&buf2, "", 0)
+	defer writer2.Close()
+	writer3 := New(&buf2, "", 0)
+	defer writer3.Close()
+	writer2.SetTerminalWidth(31)
+	writer2.Print("Building")
+	writer2.SetRightStatus("12s")
+	buf2.Reset()
+	writer3.Print("Testing")
+	assert.Equal("\rBuilding 12s | Testing        ", buf2.String())
+}
+
+func TestMirrorTo(t *testing.T) {
+	assert := assert.New(t)
+	var buf, recording bytes.Buffer
+	writer1 := New(&buf, "", 0)
+	defer writer1.Close()
+	writer2 := New(&buf, "", 0)
+	defer writer2.Close()
+	stop := writer1.MirrorTo(&recording)
+	writer1.Print("Testing...")
+	writer2.Print("Building...")
+	writer1.Print(" done.\n")
+	assert.Equal(buf.String(), recording.String())
+	stop()
+	writer2.Print(" done.\n")
+	assert.NotEqual(buf.String(), recording.String())
+}
+
+func TestLineWriter(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	writer.HidePartialLines()
+	lw := NewLineWriter(writer, LineWriterOptions{Prefix: "gz: ", HidePartialLines: true})
+	io.WriteString(lw, "first li")
+	io.WriteString(lw, "ne\nsecond line\nthi")
+	assert.Equal("gz: first line\ngz: second line\n", buf.String())
+	buf.Reset()
+	lw.Close()
+	assert.Equal("gz: thi\n", buf.String())
+	buf.Reset()
+
+	writer.SetLevel(LevelWarn)
+	lw = NewLineWriter(writer, LineWriterOptions{Level: LevelDebug})
+	io.WriteString(lw, "hidden\n")
+	lw.Close()
+	assert.Equal("", buf.String())
+}
+
+func TestLiveHeader(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", Ltime)
+	defer writer.Close()
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.Local)
+	writer.SetNowFunc(func() time.Time { return now })
+	writer.Print("Waiting")
+	assert.Equal("03:04:05 Waiting", buf.String())
+	buf.Reset()
+	now = now.Add(time.Second)
+	writer.Print(".")
+	assert.Equal("\r03:04:06 Waiting.", buf.String())
+	buf.Reset()
+
+	// Redraws have the time of the last write, unless the header is live
+	now = now.Add(time.Second)
+	writer.SetRightStatus("x")
+	assert.NotContains(buf.String(), "03:04:07")
+	buf.Reset()
+	writer.EnableLiveHeader()
+	defer writer.DisableLiveHeader()
+	now = now.Add(time.Second)
+	writer.SetRightStatus("y")
+	assert.Contains(buf.String(), "03:04:08 Waiting.")
+}
+
+func TestMaxLineLength(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "> ", 0)
+	defer writer.Close()
+	writer.SetMaxLineLength(12, LineTruncate)
+	writer.Print("short line\n")
+	writer.Print("this line is too long\n")
+	assert.Equal("> short line\n> this line...\n", buf.String())
+	buf.Reset()
+	writer.SetMaxLineLength(12, LineElide)
+	writer.Print("this line is too long\n")
+	assert.Equal("> this ...long\n", buf.String())
+	buf.Reset()
+	writer.SetMaxLineLength(12, LineWrap)
+	writer.Print("this line is too long\n")
+	assert.Equal("> this line is\n> too long\n", buf.String())
+	buf.Reset()
+	writer.SetMaxLineLength(0, LineTruncate)
+	writer.Print("this line is too long\n")
+	assert.Equal("> this line is too long\n", buf.String())
+}
+
+func TestPrintBinary(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	writer.DisableColor()
+	writer.PrintBinary("request", []byte("GET / HTTP/1.1\r\n\033[31m"), -1)
+	assert.Equal("request (21 bytes): \"GET / HTTP/1.1\\r\\n\\x1b[31m\"\n", buf.String())
+	buf.Reset()
+	writer.PrintBinary("frame", []byte{0x00, 0xff, 0x1b, 0x7f, 0x80}, 3)
+	assert.Equal("frame (5 bytes): 00 ff 1b ... 2 more\n", buf.String())
+	buf.Reset()
+	writer.PrintBinary("empty", nil, 10)
+	assert.Equal("empty (0 bytes): \"\"\n", buf.String())
+}
+
+func TestPrintkv(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	writer.DisableColor()
+	writer.Printkv("connected", "addr", "10.0.0.1:5432", "retries", 3)
+	assert.Equal("connected addr=10.0.0.1:5432 retries=3\n", buf.String())
+	buf.Reset()
+	writer.Printkv("failed", "err", errors.New("no route to host"), "user", "", "extra")
+	assert.Equal("failed err=\"no route to host\" user=\"\" ?=extra\n", buf.String())
+	buf.Reset()
+	writer.EnableColor()
+	writer.EnableColorTemplate()
+	writer.Printkv("@(green:ok)", "n", 1)
+	assert.Equal("\033[32mok\033[39m n=\033[33m1\033[39m\n", buf.String())
+
+	buf.Reset()
+	writer.DisableColor()
+	writer.Printf("connected\n", "addr", "10.0.0.1:5432", "retries", 3)
+	assert.Equal("connected addr=10.0.0.1:5432 retries=3\n", buf.String(), "Printf takes fields after the format's arguments")
+	buf.Reset()
+	writer.Infof("%d%% of %*d done", 50, 3, 10, "phase", "copy")
+	assert.Equal("50% of  10 done phase=copy\n", buf.String())
+	buf.Reset()
+	writer.Printf("%[2]s %[1]s\n", "a", "b")
+	assert.Equal("b a\n", buf.String())
+	assert.Equal(-1, countFormatArgs("%[1]d"))
+}
+
+func TestPrintStyled(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	writer.EnableColorTemplate()
+	writer.PrintStyled("red", errors.New("bad @(green:input)"), "\n")
+	assert.Equal("\033[31mbad @(green:input)\033[39m\n", buf.String())
+	buf.Reset()
+	writer.PrintStyled("yellow,bright", "two\nlines\n")
+	assert.Equal("\033[33m\033[1mtwo\033[0m\n\033[33m\033[1mlines\033[0m\n", buf.String())
+	buf.Reset()
+	writer.PrintStyled("red", "bad \033[2Jinput\r ok\n")
+	assert.Equal("\033[31mbad [2Jinput ok\033[39m\n", buf.String())
+}
+
+func TestRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	writer.HidePartialLines()
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	writer.SetNowFunc(func() time.Time { return now })
+	ResetCounts()
+	defer ResetCounts()
+	assert.NoError(writer.Run(exec.Command("sh", "-c", "echo hello"), "sh| "))
+	assert.Equal("sh| hello\nsh| \033[32mexit 0 (0.0ms)\033[39m\n", buf.String())
+	buf.Reset()
+	assert.Error(writer.Run(exec.Command("sh", "-c", "echo oops >&2; exit 3"), "sh| "))
+	assert.Equal("sh| \033[31m!\033[39m oops\nsh| \033[31mexit 3 (0.0ms)\033[39m\n", buf.String())
+	run, failed := CommandCounts()
+	assert.Equal(2, run)
+	assert.Equal(1, failed)
+	buf.Reset()
+	writer.Summary()
+	assert.Equal("\033[31mcompleted with 1 failed command\033[39m\n", buf.String())
+}
+
+func TestRunStderr(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	writer.DisableColor()
+	writer.HidePartialLines()
+	writer.Run(exec.Command("sh", "-c", "printf 'out '; sleep 0.1; echo err >&2; sleep 0.1; echo line"), "sh| ")
+	lines := strings.Split(buf.String(), "\n")
+	assert.Equal([]string{"sh| ! err", "sh| out line"}, lines[:2])
+}
+
+func TestRunProgress(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	writer.DisableColor()
+	cmd := exec.Command("sh", "-c", `printf '{"event":"partial","msg":"50%%"}\n' >&$`+ProgressFDEnv+`; sleep 0.1; echo done`)
+	assert.NoError(writer.Run(cmd, "sh| "))
+	assert.Regexp(`^sh\| 50%\rsh\| done\nsh\| 50%\r +\rsh\| exit 0 \(.*\)\n$`, buf.String(), "the progress is cleared when the command exits")
+
+	os.Unsetenv(ProgressFDEnv)
+	assert.False(writer.ReportProgressToParent())
+}
+
+func TestWatchdog(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	writer.SetMultilineEnabled(true)
+	writer.DisableUnicode()
+	start := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	writer.SetNowFunc(func() time.Time { return start })
+	w := &watchdog{l: writer, wl: writer.derive(""), after: 30 * time.Second}
+	defer w.wl.Destroy()
+	w.check(start.Add(time.Hour))
+	assert.Equal("", buf.String(), "Nothing to wait on without a partial line")
+	writer.Print("downloading foo")
+	buf.Reset()
+	w.check(start.Add(10 * time.Second))
+	assert.Equal("", buf.String())
+	w.check(start.Add(45 * time.Second))
+	assert.Contains(buf.String(), "still waiting on downloading foo (45.0s)...")
+	buf.Reset()
+	w.check(start.Add(46 * time.Second))
+	assert.Contains(buf.String(), "(46.0s)")
+	writer.Print(" done\n")
+	buf.Reset()
+	w.check(start.Add(47 * time.Second))
+	assert.False(w.showing)
+
+	stop := writer.StartWatchdog(time.Hour)
+	stop()
+}
+
+func TestStepLevel(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	writer.DisableColor()
+	writer.stepLevel(-1)
+	assert.Equal("log level is now debug\n", buf.String())
+	buf.Reset()
+	writer.stepLevel(-1)
+	assert.Equal("log level is now debug\n", buf.String())
+	buf.Reset()
+	writer.stepLevel(1)
+	writer.stepLevel(1)
+	writer.Infof("hidden")
+	assert.Equal("log level is now info\nlog level is now warn\n", buf.String())
+	writer.SetQuiet(true)
+	writer.stepLevel(-1)
+	writer.SetQuiet(false)
+	assert.Equal(LevelInfo, writer.level, "turning quiet mode off keeps the level stepped to")
+	stop := writer.HandleLevelSignals()
+	stop()
+}
+
+func TestAdminHandler(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	h := NewAdminHandler(map[string]*Logger{"db": writer})
+
+	get := func(path string) (int, string) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code, rec.Body.String()
+	}
+	code, body := get("/db")
+	assert.Equal(http.StatusOK, code)
+	assert.Equal(`{"level":"info","color":true,"partialLines":true}`+"\n", body)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/db", strings.NewReader(`{"level": "debug", "color": false}`)))
+	assert.Equal(http.StatusOK, rec.Code)
+	assert.Equal(`{"level":"debug","color":false,"partialLines":true}`+"\n", rec.Body.String())
+	writer.Debugf("visible")
+	assert.Equal("visible\n", buf.String())
+
+	code, body = get("/")
+	assert.Equal(`{"db":{"level":"debug","color":false,"partialLines":true}}`+"\n", body)
+	code, _ = get("/web")
+	assert.Equal(http.StatusNotFound, code)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/db", strings.NewReader(`{"level": "loud"}`)))
+	assert.Equal(http.StatusBadRequest, rec.Code)
+}
+
+func TestWatchConfig(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	db := New(&buf, "db: ", 0)
+	defer db.Close()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "log.json")
+	output := filepath.Join(dir, "app.log")
+	os.WriteFile(path, []byte(`{"level": "warn", "output": "`+output+`", "format": "json", "loggers": {"db": "debug"}}`), 0644)
+	stop, err := writer.WatchConfig(path, map[string]*Logger{"db": db})
+	assert.NoError(err)
+	defer stop()
+	writer.Infof("hidden")
+	writer.Warnf("shown")
+	db.Debugf("query")
+	assert.Equal("shown\ndb: query\n", buf.String())
+	data, _ := os.ReadFile(output)
+	assert.Contains(string(data), `"msg":"shown"`)
+
+	w := &configWatcher{l: writer, path: path, file: &logFileFlag{l: writer, format: "text"}}
+	assert.EqualError(w.apply(&Config{Theme: "plaid"}), `unknown theme "plaid"`)
+	assert.EqualError(w.apply(&Config{Level: "info", Loggers: map[string]string{"web": "info"}}), `no logger named "web"`)
+	assert.Equal(LevelWarn, writer.level, "Nothing should be applied from a config with a mistake in it")
+	assert.Error(w.apply(&Config{Level: "info", Output: filepath.Join(dir, "missing", "app.log")}))
+	assert.Equal(LevelWarn, writer.level, "Nothing should be applied if the output can't be opened")
+
+	_, err = writer.WatchConfig(filepath.Join(dir, "log.yaml"), nil)
+	assert.Error(err)
+	os.WriteFile(filepath.Join(dir, "log.yaml"), []byte("loggers:\n  db:\n"), 0644)
+	_, err = writer.WatchConfig(filepath.Join(dir, "log.yaml"), nil)
+	if assert.Error(err) {
+		assert.Contains(err.Error(), "line 2: empty value")
+	}
+	assert.Equal(LevelWarn, writer.level)
+
+	stop()
+	writer.Warnf("after")
+	data, _ = os.ReadFile(output)
+	assert.NotContains(string(data), "after", "Stopping should close the output")
+}
+
+func TestParseConfig(t *testing.T) {
+	assert := assert.New(t)
+	yes := true
+	want := &Config{Level: "info", Color: &yes, Output: "/var/log/app.log", Loggers: map[string]string{"db": "debug", "web": "warn"}}
+	config, err := parseConfig(".yaml", []byte(`# app logging
+level: info
+color: true
+output: "/var/log/app.log"  # appended to
+loggers:
+  db: debug
+  web: 'warn'
+`))
+	assert.NoError(err)
+	assert.Equal(want, config)
+	config, err = parseConfig(".toml", []byte(`level = "info" # comment
+color = true
+output = "/var/log/app.log"
+
+[loggers]
+db = "debug"
+web = 'warn'
+`))
+	assert.NoError(err)
+	assert.Equal(want, config)
+	_, err = parseConfig(".yaml", []byte("  db: debug\n"))
+	assert.EqualError(err, "line 1: unexpected indentation")
+	_, err = parseConfig(".toml", []byte("level = info\n"))
+	assert.EqualError(err, "line 1: unsupported value info")
+	_, err = parseConfig(".ini", nil)
+	assert.Error(err)
+}
+
+func TestManualScheduler(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	scheduler := NewManualScheduler()
+	writer.SetScheduler(scheduler)
+	n := 0
+	stop := writer.LogEvery(10*time.Second, func() string {
+		n++
+		return fmt.Sprintf("%d done", n)
+	})
+	scheduler.Advance(9 * time.Second)
+	assert.Equal("", buf.String())
+	scheduler.Advance(time.Second)
+	assert.Equal("1 done", buf.String())
+	scheduler.Advance(25 * time.Second)
+	assert.Equal(3, n)
+	stop()
+	scheduler.Advance(time.Minute)
+	assert.Equal(3, n, "Nothing should run once stopped")
+
+	var order []string
+	cancel1 := scheduler.Every(2*time.Second, func() { order = append(order, "a") })
+	cancel2 := scheduler.Every(3*time.Second, func() { order = append(order, "b") })
+	scheduler.Advance(6 * time.Second)
+	cancel1()
+	cancel2()
+	assert.Equal([]string{"a", "b", "a", "a", "b"}, order)
+
+	// The scheduler may be changed while others are starting work with it
+	done := make(chan struct{})
+	go func() {
+		writer.SetScheduler(NewManualScheduler())
+		close(done)
+	}()
+	writer.LogEvery(time.Second, func() string { return "" })()
+	<-done
+}
+
+// blockingWriter is a writer whose writes wait until it's opened.
+type blockingWriter struct {
+	open  chan struct{}
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.open
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.buf.Write(p)
+}
+
+func TestBackgroundWrites(t *testing.T) {
+	assert := assert.New(t)
+	w := &blockingWriter{open: make(chan struct{})}
+	writer := New(w, "", 0)
+	writer.EnableBackgroundWrites()
+	writer.Print("first\n")
+	writer.Print("downloading")
+	for i := 0; i < 100; i++ {
+		writer.Printf(" %d", i)
+	}
+	writer.Print("\nsecond\n")
+	writer.Print("waiting")
+	close(w.open)
+	writer.Flush()
+	w.mutex.Lock()
+	output := w.buf.String()
+	w.mutex.Unlock()
+	assert.True(strings.HasPrefix(output, "first\ndownloading 0 1 2"))
+	assert.NotContains(output, "downloading 0\r", "Partial line updates should be merged while the writer is busy")
+	assert.True(strings.HasSuffix(output, "second\nwaiting\n"))
+	writer.Close()
+}
+
+func TestBackgroundWritesFlush(t *testing.T) {
+	assert := assert.New(t)
+	w := &blockingWriter{open: make(chan struct{})}
+	writer1 := New(w, "", 0)
+	defer writer1.Close()
+	writer2 := New(w, "", 0)
+	defer writer2.Close()
+	writer1.EnableBackgroundWrites()
+	writer2.Print("downloading")
+	writer2.Print(" 1")
+	ws := getWriterState(w)
+	ws.lock()
+	assert.True(ws.tempDeferred, "the update is put off while the writer is busy")
+	ws.unlock()
+	writer1.Print("done\n")
+	ws.lock()
+	assert.False(ws.tempDeferred, "but not once a finished line has moved the cursor")
+	ws.unlock()
+	writer2.Print(" 2")
+	close(w.open)
+	writer1.Flush()
+	w.mutex.Lock()
+	output := w.buf.String()
+	w.mutex.Unlock()
+	assert.True(strings.HasSuffix(output, "downloading 1 2"), "Flush waits for put-off updates: %q", output)
+}
+
+func TestLineNumbers(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer1 := New(&buf, "", Llinenum)
+	defer writer1.Close()
+	writer2 := New(&buf, "", Llinenum)
+	defer writer2.Close()
+	writer1.DisableColor()
+	writer2.DisableColor()
+	writer1.Print("one\ntwo\n")
+	writer2.Print("three\n")
+	assert.Equal("   1 one\n   2 two\n   3 three\n", buf.String())
+	buf.Reset()
+	writer1.Print("partial")
+	assert.Equal("     partial", buf.String())
+	buf.Reset()
+	writer1.Print("\n")
+	assert.Equal("\r   4 partial\n", buf.String())
+	buf.Reset()
+
+	writer2.SetFlags(Llinenum | Llinenumlocal)
+	writer2.Print("four\n")
+	assert.Equal("   1 four\n", buf.String())
+	buf.Reset()
+	writer2.EnableColor()
+	writer2.Print("five\n")
+	assert.Equal("\033[2m   2\033[0m five\n", buf.String())
+}
+
+func TestGrep(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	_, err := writer.Grep("x")
+	assert.Error(err, "There's nothing to search without a RingBuffer")
+	writer.SetRingBuffer(NewRingBuffer(10), false)
+	writer.SetNowFunc(func() time.Time { return time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC) })
+	writer.Infof("connecting to db")
+	writer.Warnf("db is slow")
+	writer.Error("lost connection to db")
+	writer.Infof("done")
+	buf.Reset()
+	n, err := writer.Grep("db$")
+	assert.NoError(err)
+	assert.Equal(2, n)
+	assert.Equal("03:04:05.000 info connecting to \033[1m\033[33mdb\033[0m\n"+
+		"03:04:05.000 error lost connection to \033[1m\033[33mdb\033[0m\n", buf.String())
+	buf.Reset()
+	n, _ = writer.Grep("^\\S+ warn")
+	assert.Equal(1, n)
+	_, err = writer.Grep("(")
+	assert.Error(err)
+	assert.Equal(4, len(writer.ring.Entries()), "Grep's own output shouldn't be recorded")
+}
+
+func TestBookmarks(t *testing.T) {
+	assert := assert.New(t)
+	var buf, other bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	otherWriter := New(&other, "", 0)
+	defer otherWriter.Close()
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	writer.SetNowFunc(func() time.Time { return now })
+	writer.SetBookmark("start")
+	writer.Print("one\ntwo\n")
+	now = now.Add(time.Minute)
+	writer.Print("three")
+	writer.SetBookmark("phase 2")
+	writer.Print("\n")
+	assert.Equal([]Bookmark{
+		{Name: "start", Time: now.Add(-time.Minute), Line: 1},
+		{Name: "phase 2", Time: now, Line: 3},
+	}, writer.Bookmarks())
+	assert.Empty(otherWriter.Bookmarks(), "Bookmarks are kept per writer")
+	writer.ResetBookmarks()
+	assert.Empty(writer.Bookmarks())
+
+	// With Llinenum, bookmarks match the numbers shown, even among other lines
+	buf.Reset()
+	numbered := New(&buf, "", Llinenum)
+	defer numbered.Close()
+	numbered.Print("first\n")
+	writer.Print("unnumbered\n")
+	numbered.SetBookmark("second")
+	numbered.Print("second\n")
+	assert.Equal(2, numbered.Bookmarks()[0].Line)
+	assert.Contains(buf.String(), "   2\033[0m second\n")
+}
+
+func TestAccessLog(t *testing.T) {
+	assert := assert.New(t)
+	var buf, combined bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	now := time.Date(2000, 10, 10, 13, 55, 36, 0, time.FixedZone("", -7*60*60))
+	writer.SetNowFunc(func() time.Time { return now })
+	writer.AddOutput(&combined, OutputOptions{Encoder: CLFEncoder{Combined: true}})
+	handler := writer.AccessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("hello"))
+	}))
+	req := httptest.NewRequest("GET", "/hello?x=1", nil)
+	req.RemoteAddr = "127.0.0.1:5000"
+	req.SetBasicAuth("frank", "secret")
+	req.Header.Set("User-Agent", "test/1.0")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	writer.Printf("not a request\n")
+	req = httptest.NewRequest("POST", "/missing", nil)
+	req.RemoteAddr = "127.0.0.1:5001"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal("GET /hello?x=1 \033[32m200\033[39m 5B \033[2m0.0ms\033[0m\n"+
+		"not a request\n"+
+		"POST /missing \033[33m404\033[39m 19B \033[2m0.0ms\033[0m\n", buf.String())
+	assert.Equal(`127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /hello?x=1 HTTP/1.1" 200 5 "" "test/1.0"`+"\n"+
+		`127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "POST /missing HTTP/1.1" 404 19 "" ""`+"\n", combined.String())
+
+	var common bytes.Buffer
+	assert.Equal("", string(CLFEncoder{}.Encode(common.Bytes(), &Entry{Message: "not a request"})))
+}
+
+func TestGELFAndLogstashEncoders(t *testing.T) {
+	assert := assert.New(t)
+	var gelf, logstash bytes.Buffer
+	writer := New(io.Discard, "", Lshortfile)
+	defer writer.Close()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 250*int(time.Millisecond), time.UTC)
+	writer.SetNowFunc(func() time.Time { return now })
+	writer.AddOutput(&gelf, OutputOptions{MinLevel: LevelDebug, Encoder: GELFEncoder{Host: "web1", NullDelimited: true}})
+	writer.AddOutput(&logstash, OutputOptions{MinLevel: LevelDebug, Encoder: LogstashEncoder{Host: "web1"}})
+	writer.SetLevel(LevelDebug)
+	writer.EnableColorTemplate()
+	writer.Warnf("@(yellow:careful)")
+	writer.Debugf("details")
+	messages := strings.Split(gelf.String(), "\x00")
+	assert.Equal(3, len(messages))
+	assert.Regexp(`^\{"version":"1.1","host":"web1","short_message":"careful","timestamp":1714564800.25,"level":4,"_file":"log_test.go","_line":\d+\}$`, messages[0])
+	assert.Contains(messages[1], `"short_message":"details","timestamp":1714564800.25,"level":7,`)
+	lines := strings.Split(strings.TrimSuffix(logstash.String(), "\n"), "\n")
+	assert.Equal(2, len(lines))
+	assert.Regexp(`^\{"@timestamp":"2024-05-01T12:00:00.250Z","@version":"1","message":"careful","level":"WARN","level_value":30000,"host":"web1","file":"log_test.go","line_number":\d+\}$`, lines[0])
+	assert.Contains(lines[1], `"level":"DEBUG","level_value":10000,`)
+}
+
+func TestMsgpackEncoder(t *testing.T) {
+	assert := assert.New(t)
+	var packed bytes.Buffer
+	writer := New(io.Discard, "", 0)
+	defer writer.Close()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	writer.SetNowFunc(func() time.Time { return now })
+	writer.AddOutput(&packed, OutputOptions{Encoder: MsgpackEncoder{}})
+	writer.Printf("hi\n")
+	assert.Equal("\x83\xa4time\xd3\x17\xcb\x5b\x99\xf8\x63\x80\x00\xa5level\x00\xa3msg\xa2hi", packed.String())
+	writer.SetFlags(Lshortfile)
+	writer.Error("%s", strings.Repeat("x", 300))
+
+	d := NewMsgpackDecoder(&packed)
+	var e Entry
+	assert.NoError(d.Decode(&e))
+	assert.True(now.Equal(e.Time))
+	assert.Equal(Entry{Time: e.Time, Level: LevelInfo, Message: "hi"}, e)
+	assert.NoError(d.Decode(&e))
+	assert.Equal(LevelError, e.Level)
+	assert.Equal(strings.Repeat("x", 300), e.Message)
+	assert.Equal("log_test.go", e.File)
+	assert.NotZero(e.Line)
+	assert.Equal(io.EOF, d.Decode(&e))
+	assert.Equal(io.ErrUnexpectedEOF, NewMsgpackDecoder(strings.NewReader("\x81\xa3ms")).Decode(&e))
+
+	// Keys it doesn't know are skipped, whatever their values
+	d = NewMsgpackDecoder(strings.NewReader("\x84\xa3msg\xa2hi" +
+		"\xa4tags\x92\xc3\xcb\x3f\xf0\x00\x00\x00\x00\x00\x00" +
+		"\xa4meta\x81\xa1k\xc4\x02ab" +
+		"\xa5level\x01"))
+	assert.NoError(d.Decode(&e))
+	assert.Equal(Entry{Level: LevelWarn, Message: "hi"}, e)
+	assert.Equal(io.EOF, d.Decode(&e))
+}
+
+func TestCIMode(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "$ ", Lshortfile)
+	defer writer.Close()
+	writer.SetCIMode(CIGitHub)
+	end := writer.Group("Build, test")
+	writer.Printf("fine\n")
+	writer.Warnf("100%% slow")
+	end()
+	end()
+	writer.SetFlags(0)
+	writer.Error("failed\n")
+	lines := strings.Split(buf.String(), "\n")
+	assert.Equal("::group::Build, test", lines[0])
+	assert.Regexp(`^\$ log_test.go:\d+: fine$`, lines[1])
+	assert.Regexp(`^::warning file=log_test.go,line=\d+::100%25 slow$`, lines[2])
+	assert.Equal("::endgroup::", lines[3])
+	assert.Equal("::error::failed", lines[4])
+	assert.Equal(6, len(lines))
+
+	buf.Reset()
+	writer.SetCIMode(CIGitLab)
+	now := time.Unix(1700000000, 0)
+	writer.SetNowFunc(func() time.Time { return now })
+	end = writer.Group("Run tests")
+	writer.Error("failed\n")
+	end()
+	assert.Equal("\033[0Ksection_start:1700000000:run_tests\r\033[0KRun tests\n$ failed\n"+
+		"\033[0Ksection_end:1700000000:run_tests\r\033[0K\n", buf.String())
+
+	buf.Reset()
+	writer.SetCIMode(CIBuildkite)
+	end = writer.Group("Run tests")
+	writer.Warnf("careful")
+	writer.Error("failed\n")
+	end()
+	assert.Equal("--- Run tests\n$ careful\n^^^ +++\n$ failed\n", buf.String())
+
+	buf.Reset()
+	writer.SetCIMode(CINone)
+	writer.Group("Run tests")()
+	assert.Equal("$ \033[1mRun tests\033[0m\n", buf.String())
+}
+
+func TestStepTAP(t *testing.T) {
+	assert := assert.New(t)
+	var buf, tap bytes.Buffer
+	writer := New(&buf, "", 0)
+	now := time.Unix(1700000000, 0)
+	writer.SetNowFunc(func() time.Time { return now })
+	writer.SetTAPOutput(&tap, false)
+	step := writer.Step("build")
+	now = now.Add(1250 * time.Millisecond)
+	step.Done()
+	step.Fail(errors.New("ignored"))
+	assert.EqualError(writer.Step("test # 2").End(errors.New("2 failed")), "2 failed")
+	writer.Step("deploy").Skip("dry run")
+	writer.Close()
+	assert.Equal("build... \033[32mok\033[39m \033[33m1.25s\033[39m\n"+
+		"test # 2... \033[31mFAILED\033[39m 0.0ms: 2 failed\n"+
+		"deploy... \033[33mskipped\033[39m: dry run\n", buf.String())
+	assert.Equal("TAP version 13\n"+
+		"ok 1 - build\n"+
+		"not ok 2 - test \\# 2\n  ---\n  message: \"2 failed\"\n  duration_ms: 0.000\n  ...\n"+
+		"ok 3 - deploy # SKIP dry run\n"+
+		"1..3\n", tap.String())
+
+	buf.Reset()
+	tap.Reset()
+	writer = New(&buf, "", 0)
+	writer.SetTAPOutput(&tap, true)
+	writer.Step("build").Done()
+	writer.Step("lint").Skip("no #linter")
+	writer.FinishTAPOutput()
+	writer.Step("ignored").Done()
+	writer.Close()
+	assert.Equal("", buf.String())
+	assert.Equal("TAP version 13\nok 1 - build\nok 2 - lint # SKIP no \\#linter\n1..2\n", tap.String())
+}
+
+func TestStepJUnitReport(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	path := filepath.Join(t.TempDir(), "report.xml")
+	writer := New(&buf, "", 0)
+	now := time.Unix(1700000000, 0)
+	writer.SetNowFunc(func() time.Time { return now })
+	writer.EnableColorTemplate()
+	writer.SetJUnitReport(path)
+	step := writer.Step("build")
+	step.Logger().Printf("@(green:compiling) <main>\n")
+	now = now.Add(1500 * time.Millisecond)
+	step.Done()
+	step = writer.Step("test")
+	step.Logger().Printf("TestFoo failed\n")
+	step.Fail(errors.New("1 test failed"))
+	writer.Step("deploy").Skip("dry run")
+	assert.Equal("build... \r  \033[32mcompiling\033[39m <main>\nbuild... \033[32mok\033[39m \033[33m1.50s\033[39m\n", strings.Split(buf.String(), "test... ")[0])
+	assert.NoError(writer.WriteJUnitReport(), "The report can be written without closing the Logger")
+	data, err := os.ReadFile(path)
+	assert.NoError(err)
+	assert.Equal(xml.Header+`<testsuites>
+  <testsuite name="`+filepath.Base(os.Args[0])+`" tests="3" failures="1" skipped="1" time="1.5">
+    <testcase name="build" classname="`+filepath.Base(os.Args[0])+`" time="1.5">
+      <system-out>  compiling &lt;main&gt;&#xA;</system-out>
+    </testcase>
+    <testcase name="test" classname="`+filepath.Base(os.Args[0])+`" time="0">
+      <failure message="1 test failed">  TestFoo failed&#xA;</failure>
+    </testcase>
+    <testcase name="deploy" classname="`+filepath.Base(os.Args[0])+`" time="0">
+      <skipped message="dry run"></skipped>
+    </testcase>
+  </testsuite>
+</testsuites>
+`, string(data))
+	assert.NoError(os.Remove(path))
+	assert.NoError(writer.Close())
+	_, err = os.Stat(path)
+	assert.NoError(err, "Closing the Logger writes it too")
+}
+
+func TestEventOutput(t *testing.T) {
+	assert := assert.New(t)
+	var buf, events bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	writer.SetNowFunc(func() time.Time { return now })
+	writer.SetEventOutput(&events)
+	writer.EnableColorTemplate()
+	writer.Printf("@(green:50%%)")
+	writer.Printf("\rdone\n")
+	step := writer.Step("build")
+	now = now.Add(1250 * time.Millisecond)
+	step.Fail(errors.New("oops"))
+	assert.Equal(`{"event":"partial","time":"2024-05-01T12:00:00Z","msg":"50%"}
+{"event":"line","time":"2024-05-01T12:00:00Z","level":"info","msg":"done"}
+{"event":"step_start","time":"2024-05-01T12:00:00Z","name":"build"}
+{"event":"partial","time":"2024-05-01T12:00:00Z","msg":"build... "}
+{"event":"line","time":"2024-05-01T12:00:01.25Z","level":"error","msg":"build... FAILED 1.25s: oops"}
+{"event":"step_end","time":"2024-05-01T12:00:01.25Z","name":"build","status":"failed","duration_ms":1250,"error":"oops"}
+`, events.String())
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	writer.RegisterFlags(fs)
+	path := filepath.Join(t.TempDir(), "events.json")
+	assert.NoError(fs.Parse([]string{"-json-events", path}))
+	writer.Printf("hi\n")
+	data, err := os.ReadFile(path)
+	assert.NoError(err)
+	assert.Equal(`{"event":"line","time":"2024-05-01T12:00:01.25Z","level":"info","msg":"hi"}`+"\n", string(data))
+}
+
+// fakeTerminal is a buffer that says it's a terminal of a given width.
+type fakeTerminal struct {
+	bytes.Buffer
+	width int
+}
+
+func (t *fakeTerminal) Width() int  { return t.width }
+func (t *fakeTerminal) IsTTY() bool { return true }
+func (t *fakeTerminal) Fd() uintptr { return ^uintptr(0) }
+
+func TestTerminalInfoProvider(t *testing.T) {
+	assert := assert.New(t)
+	if os.Getenv("COLUMNS") != "" {
+		t.Skip("COLUMNS overrides the width")
+	}
+	term := &fakeTerminal{width: 30}
+	writer := New(term, "", 0)
+	defer writer.Close()
+	assert.Equal(30, getTermWidth(term))
+	writer.SetBuffered(1024, 0)
+	assert.Equal(term, writer.out, "terminals aren't buffered")
+	writer.Printf("%s", strings.Repeat("x", 40))
+	assert.Equal(strings.Repeat("x", 26)+"...", term.String())
+}
+
+func TestWriterDebugInfo(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	assert.Nil(WriterDebugInfo(&buf))
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	writer.SetTerminalWidth(80)
+	writer.EnableColorTemplate()
+	writer.Printf("@(red:one)")
+	writer.Printf(" two")
+	writer.Printf("\rthree")
+	info := WriterDebugInfo(&buf)
+	assert.Equal([]string{"threewo"}, info.TempLines)
+	assert.Equal(1, info.TempLoggers)
+	assert.Equal(3, info.Updates)
+	assert.Equal(1, info.Appends)
+	assert.Equal(2, info.Redraws)
+	assert.Equal("terminal: false, width 80, multiline false\n"+
+		"cursor: line 0, inline true, at beginning false\n"+
+		"partial lines: 1 from 1 loggers, active styling none\n"+
+		"  0 (7 columns): threewo ⟨active: none⟩\n"+
+		"counts: 3 updates, 2 redraws, 1 appends, 0 lines\n", info.String())
+}
+
+func TestNewStandalone(t *testing.T) {
+	assert := assert.New(t)
+	colorTemplateEnabled := DefaultLogger.colorTemplateEnabled
+	defer func() { DefaultLogger.colorTemplateEnabled = colorTemplateEnabled }()
+	DefaultLogger.DisableColorTemplate()
+	var buf, standaloneBuf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	standalone := NewStandalone(&standaloneBuf, "", 0)
+	defer standalone.Close()
+	writer.Printf("@(red:x)\n")
+	standalone.Printf("@(red:x)\n")
+	assert.Equal("@(red:x)\n", buf.String())
+	assert.Equal("\033[31mx\033[39m\n", standaloneBuf.String(), "the standard logger's settings don't apply")
+	standaloneBuf.Reset()
+	standalone.Step("build").Done()
+	assert.Contains(standaloneBuf.String(), "\033[32mok\033[39m", "derived Loggers are standalone too")
+	standaloneBuf.Reset()
+	standalone.DisableColor()
+	standalone.Printf("@(red:x)\n")
+	assert.Equal("x\n", standaloneBuf.String())
+
+	buf.Reset()
+	standaloneBuf.Reset()
+	writer.Oncef("standalone test", "once\n")
+	standalone.Oncef("standalone test", "once\n")
+	standalone.derive("").Oncef("standalone test", "twice\n")
+	assert.Equal("once\n", buf.String())
+	assert.Equal("once\n", standaloneBuf.String(), "Oncef keys are kept per standalone Logger")
+}
+
+func TestClone(t *testing.T) {
+	assert := assert.New(t)
+	var buf, warnings bytes.Buffer
+	template := New(&buf, "[job] ", 0)
+	defer template.Close()
+	template.SetLevel(LevelWarn)
+	template.AddOutput(&warnings, OutputOptions{})
+	template.SetRingBuffer(NewRingBuffer(10), false)
+
+	clone := template.Clone()
+	defer clone.Destroy()
+	clone.Warnf("careful")
+	clone.Infof("hidden")
+	assert.Equal("[job] careful\n", warnings.String())
+	assert.Equal(2, len(clone.ring.Entries()), "hidden lines are kept too")
+	assert.Equal(0, len(template.ring.Entries()), "the clone has a ring of its own")
+
+	clone.SetLevel(LevelInfo)
+	template.Infof("still hidden")
+	assert.Equal("[job] careful\n", warnings.String(), "settings aren't shared")
+}
+
+// TestCloneCopiesEveryField sets every one of the Logger's fields, and checks
+// that Clone copies each one that isn't on the list of those it deliberately
+// doesn't, so that a new setting can't be missed.
+func TestCloneCopiesEveryField(t *testing.T) {
+	assert := assert.New(t)
+	notCopied := map[string]bool{
+		// The state of the Logger's own output
+		"buf": true, "cursorByteIndex": true, "tempLineActive": true,
+		"centerStatus": true, "rightStatus": true, "isClosed": true,
+		"carriedCodes": true, "attached": true, "lastEntry": true,
+		"lineStartTime": true, "liveHeaderCancel": true, "lineCount": true,
+		"config": true, "closers": true, "tempLine": true,
+		// Of the same size, but its own; checked below
+		"async": true, "ring": true,
+	}
+	var buf, warnings bytes.Buffer
+	template := New(&buf, "[job] ", Lshortfile)
+	defer template.Close()
+	template.SetAsync(8, OverflowDropOldest)
+	template.SetRingBuffer(NewRingBuffer(10), true)
+	template.callDepthOffset = 1
+	template.prefixWidth = 8
+	template.maxPartialLineLength = 100
+	template.maxLineLength = 80
+	template.linePolicy = LinePolicy(1)
+	template.carryColors = &yes
+	template.level = LevelWarn
+	template.outputs = []output{{w: &warnings}}
+	template.errOut = &warnings
+	template.errLevel = LevelError
+	template.errOutShared = true
+	template.dumpOnError = true
+	template.crashReportDir = "/tmp"
+	template.discard = true
+	template.nowFunc = time.Now
+	template.translator = func(msgID string, args ...interface{}) string { return msgID }
+	template.badgeTheme = GlyphBadges
+	template.timerMediumTime = time.Second
+	template.timerLongTime = time.Minute
+	template.slowWarnAfter = time.Second
+	template.slowErrorAfter = time.Minute
+	template.partialLinesEnabled = &no
+	template.quiet = true
+	template.partialLinesBeforeQuiet = &yes
+	template.levelBeforeQuiet = LevelError
+	template.verbose = true
+	template.verboseOverQuiet = true
+	template.levelBeforeVerbose = LevelError
+	template.verboseAddedFlags = Lshortfile
+	template.colorEnabled = &no
+	template.colorTemplateEnabled = &no
+	template.unicodeEnabled = &no
+	template.autoColor = &yes
+	template.monotonicTiming = &no
+	template.ansiDebug = &yes
+	template.strictTemplates = &yes
+	template.autoAppendNewline = &yes
+	template.colorRegexp = regexp.MustCompile("x")
+	template.termWidth = 80
+	template.liveHeader = &yes
+	template.scheduler = NewManualScheduler()
+	template.tap = &tapWriter{}
+	template.junit = &junitReport{}
+	template.events = &eventWriter{}
+	template.standalone = true
+	template.seenKeys = &sync.Map{}
+	template.reprocessPrefix()
+
+	clone := template.Clone()
+	defer clone.Close()
+	v, cv := reflect.ValueOf(template).Elem(), reflect.ValueOf(clone).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Name
+		if notCopied[name] {
+			continue
+		}
+		assert.False(v.Field(i).IsZero(), "set %s in this test", name)
+		assert.Equal(fmt.Sprintf("%#v", v.Field(i)), fmt.Sprintf("%#v", cv.Field(i)), "Clone copies %s", name)
+	}
+	assert.NotSame(template.async, clone.async)
+	assert.Equal(8, cap(clone.async.messages))
+	assert.Equal(OverflowDropOldest, clone.async.policy)
+	assert.NotSame(template.ring, clone.ring)
+	assert.Equal(10, len(clone.ring.entries))
+}
+
+// countingStringer counts how many times it's formatted.
+type countingStringer struct{ count *int }
+
+func (s countingStringer) String() string {
+	*s.count++
+	return "counted"
+}
+
+func TestConfigSnapshot(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	formatted := 0
+	writer.Debugf("%s", countingStringer{&formatted})
+	assert.Equal(0, formatted, "hidden lines aren't formatted")
+	writer.SetRingBuffer(NewRingBuffer(10), false)
+	writer.Debugf("%s", countingStringer{&formatted})
+	assert.Equal(1, formatted, "unless they're recorded")
+	writer.SetRingBuffer(nil, false)
+
+	writer.Infof("one")
+	DefaultLogger.SetTranslator(func(msgID string, args ...interface{}) string { return strings.ToUpper(msgID) })
+	writer.Infof("two")
+	DefaultLogger.SetTranslator(nil)
+	writer.Infof("three")
+	assert.Equal("one\nTWO\nthree\n", buf.String(), "changes to the standard logger apply right away")
+
+	writer.SetLevel(LevelWarn)
+	writer.Printf("%s\n", countingStringer{&formatted})
+	assert.Equal(1, formatted, "Printf checks the level before formatting too")
+	ws := getWriterState(&buf)
+	ws.lock()
+	done := make(chan struct{})
+	go func() {
+		writer.Print("hidden\n")
+		writer.PrintBytes([]byte("hidden\n"))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("hidden lines waited on the writer's lock")
+	}
+	ws.unlock()
+	<-done
+	assert.Equal("one\nTWO\nthree\n", buf.String())
+
+	DefaultLogger.SetLevel(LevelWarn)
+	Printf("%s\n", countingStringer{&formatted})
+	Print(countingStringer{&formatted}, "\n")
+	Println(countingStringer{&formatted})
+	DefaultLogger.SetLevel(LevelInfo)
+	assert.Equal(1, formatted, "and so do the package-level functions")
+}
+
+func TestStepContext(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	now := time.Unix(1700000000, 0)
+	writer.SetNowFunc(func() time.Time { return now })
+	output := func() string {
+		ws := getWriterState(&buf)
+		ws.lock()
+		defer ws.unlock()
+		return buf.String()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	step := writer.StepContext(ctx, "download")
+	done := writer.TimerContext(ctx, "unpack")
+	cancel()
+	assert.Eventually(func() bool { return strings.Count(output(), "\n") == 2 }, time.Second, time.Millisecond)
+	step.Done()
+	done()
+	assert.Contains(output(), "\033[33mdownload... canceled after 0.0ms\033[39m\n")
+	assert.Contains(output(), "\033[33munpack... canceled after 0.0ms\033[39m\n")
+	assert.Equal(2, strings.Count(output(), "\n"), "ending them afterward does nothing")
+
+	buf.Reset()
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	writer.StepContext(ctx, "build").Done()
+	writer.TimerContext(ctx, "test")()
+	assert.Equal("build... \033[32mok\033[39m \033[32m0.0ms\033[39m\ntest... \033[32m0.0ms\033[39m\n", output())
+}
+
+// runScheduled calls f, advancing sched until it returns.
+func runScheduled(sched *ManualScheduler, f func()) {
+	done := make(chan struct{})
+	go func() {
+		f()
+		close(done)
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		case <-time.After(time.Millisecond):
+			sched.Advance(100 * time.Millisecond)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	writer.DisableUnicode()
+	sched := NewManualScheduler()
+	writer.SetScheduler(sched)
+	calls := 0
+	var err error
+	runScheduled(sched, func() {
+		err = writer.Retry(5, 2*time.Second, func() error {
+			calls++
+			if calls < 3 {
+				return fmt.Errorf("oops %d", calls)
+			}
+			return nil
+		})
+	})
+	assert.NoError(err)
+	assert.Equal(3, calls)
+	assert.Contains(buf.String(), "attempt 2/5 (retrying in 2s...) \033[2moops 1\033[0m")
+	assert.Contains(buf.String(), "\rattempt 2/5 (retrying in 1s...)")
+	assert.Contains(buf.String(), "attempt 3/5 (retrying in 4s...) \033[2moops 2\033[0m")
+	assert.Regexp(`\r\033\[32msucceeded on attempt 3/5\033\[39m *\n$`, buf.String())
+
+	buf.Reset()
+	calls = 0
+	runScheduled(sched, func() {
+		err = writer.Retry(2, 500*time.Millisecond, func() error {
+			calls++
+			return errors.New("down")
+		})
+	})
+	assert.EqualError(err, "down")
+	assert.Equal(2, calls)
+	assert.Contains(buf.String(), "attempt 2/2 (retrying in 0.5s...)")
+	assert.Regexp(`\r\033\[31mfailed after 2 attempts: down\033\[39m *\n$`, buf.String())
+
+	buf.Reset()
+	assert.NoError(writer.Retry(3, time.Second, func() error { return nil }))
+	assert.Equal("", buf.String(), "nothing to say about success on the first attempt")
+}
+
+func TestCountdown(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	writer.DisableUnicode()
+	sched := NewManualScheduler()
+	writer.SetScheduler(sched)
+	runScheduled(sched, func() {
+		writer.Countdown("starting in", 2*time.Second)
+	})
+	assert.Contains(buf.String(), "starting in 2s...")
+	assert.Contains(buf.String(), "\rstarting in 1s...")
+	assert.Contains(buf.String(), "\rstarting in 0.9s...")
+	assert.Regexp(`starting in 0\.1s\.\.\.\r +$`, buf.String(), "the line is cleared at zero")
+
+	buf.Reset()
+	ctx, cancel := context.WithCancel(context.Background())
+	var err error
+	done := make(chan struct{})
+	go func() {
+		err = writer.CountdownContext(ctx, "deleting in", 10*time.Second)
+		close(done)
+	}()
+	for i := 0; i < 25; i++ {
+		time.Sleep(time.Millisecond)
+		sched.Advance(100 * time.Millisecond)
+	}
+	cancel()
+	<-done
+	assert.Equal(context.Canceled, err)
+	assert.Regexp(`\r\033\[33mdeleting in [0-9]+s\.\.\. canceled\033\[39m *\n$`, buf.String())
+
+	buf.Reset()
+	assert.Equal(context.Canceled, writer.CountdownContext(ctx, "deleting in", time.Second))
+}
+
+func TestDashboard(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	writer.DisableColor()
+	d := writer.NewDashboard()
+	assert.Equal("pending 0  running 0  completed 0  failed 0", buf.String())
+	d.Add("pending", 3)
+	d.Move("pending", "running")
+	d.Move("running", "completed")
+	d.Inc("skipped")
+	d.Dec("pending")
+	assert.Equal(1, d.Count("pending"))
+	assert.Equal(1, d.Count("completed"))
+	d.Close()
+	d.Inc("failed")
+	assert.Regexp(`\rpending 1  running 0  completed 1  failed 0  skipped 1 *\n$`, buf.String())
+}
+
+func TestTargets(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	writer.DisableColor()
+	writer.DisableUnicode()
+	sched := NewManualScheduler()
+	writer.SetScheduler(sched)
+	targets := writer.NewTargets("web1", "web2")
+	assert.Equal("web1 .  web2 .", buf.String())
+	targets.Start("web1")
+	assert.Contains(buf.String(), "\rweb1 |  web2 .")
+	sched.Advance(100 * time.Millisecond)
+	assert.Contains(buf.String(), "\rweb1 /  web2 .")
+	targets.Start("db1")
+	targets.Printf("web1", "uploaded %d files", 3)
+	targets.Printf("db1", "migrating")
+	assert.NotContains(buf.String(), "uploaded", "details are held back until the target finishes")
+	targets.Done("web1")
+	assert.Regexp(`\rweb1 \+ *\n  uploaded 3 files\n`, buf.String())
+	assert.Contains(buf.String(), "web1 +  web2 .  db1 /")
+	targets.Fail("db1", errors.New("connection refused"))
+	assert.Regexp(`\rdb1 x connection refused *\n  migrating\n`, buf.String())
+	assert.Equal(TargetFailed, targets.State("db1"))
+	assert.Equal(TargetPending, targets.State("web2"))
+	targets.Close()
+	targets.Done("web2")
+	assert.Regexp(`web1 \+  web2 \.  db1 x *\n$`, buf.String())
+}
+
+func TestTargetsWrap(t *testing.T) {
+	assert := assert.New(t)
+	if os.Getenv("COLUMNS") != "" {
+		t.Skip("COLUMNS overrides the width")
+	}
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	writer.DisableColor()
+	writer.DisableUnicode()
+	writer.SetTerminalWidth(20)
+	writer.EnableMultilineMode()
+	writer.SetScheduler(NewManualScheduler())
+	targets := writer.NewTargets("web1", "web2", "db1", "db2", "cache1")
+	assert.Equal([]string{"web1 .  web2 .", "db1 .  db2 .", "cache1 ."}, WriterDebugInfo(&buf).TempLines)
+	targets.Start("db2")
+	assert.Equal([]string{"web1 .  web2 .", "db1 .  db2 |", "cache1 ."}, WriterDebugInfo(&buf).TempLines)
+	targets.Close()
+	assert.Equal([]string{""}, WriterDebugInfo(&buf).TempLines, "Close finishes every row")
+	assert.Equal("web1 .  web2 .\ndb1 .  db2 .\ncache1 .\033[1A\rdb1 .  db2 |\033[1B\r\033[1B\r\n", buf.String())
+}
+
+func TestSetFlagsWhilePrinting(t *testing.T) {
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			writer.SetFlags(Lshortfile)
+			writer.SetCallDepthOffset(i % 2)
+			writer.SetNowFunc(time.Now)
+			writer.SetFlags(0)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			writer.Printf("line %d\n", i)
+		}
+	}()
+	wg.Wait()
+	assert.Equal(t, 100, strings.Count(buf.String(), "\n"))
+}
+
+func TestFatalWithSharedWriterState(t *testing.T) {
+	assert := assert.New(t)
+	var out, errOut bytes.Buffer
+	writer := New(&out, "", 0)
+	defer writer.Close()
+	// Share one state between two writers, as for stdout and stderr on one terminal
+	mutexGlobal.Lock()
+	writers[&errOut] = writers[&out]
+	mutexGlobal.Unlock()
+	other := New(&errOut, "", 0)
+	defer other.Close()
+	exited := make(chan int, 1)
+	exitProcess = func(code int) { exited <- code }
+	defer func() { exitProcess = os.Exit }()
+	go writer.Fatal("boom\n")
+	select {
+	case code := <-exited:
+		assert.Equal(1, code)
+	case <-time.After(5 * time.Second):
+		assert.Fail("Fatal deadlocked")
+		return
+	}
+	// Fatal holds every lock until the process exits
+	for _, ws := range allWriterStates() {
+		ws.unlock()
+	}
+	assert.Equal("boom\n", out.String())
+}
+
+func TestDestroyWhileWriting(t *testing.T) {
+	var buf bytes.Buffer
+	writer := New(&buf, "", 0)
+	defer writer.Close()
+	other := New(&buf, "", 0)
+	// Hold the writer's lock, as a Logger writing to it would...
+	ws := getWriterState(&buf)
+	ws.lock()
+	detached := make(chan struct{})
+	go func() {
+		other.detachWriterState()
+		close(detached)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	// ... and look up the writer's state again, as it does along the way
+	found := make(chan struct{})
+	go func() {
+		getWriterState(&buf)
+		close(found)
+	}()
+	select {
+	case <-found:
+	case <-time.After(time.Second):
+		t.Fatal("deadlocked")
+	}
+	ws.unlock()
+	<-detached
+}
+
+func TestSchedulerCancelWaits(t *testing.T) {
+	assert := assert.New(t)
+	started := make(chan struct{})
+	var once sync.Once
+	var running int32
+	cancel := tickerScheduler{}.Every(time.Millisecond, func() {
+		atomic.StoreInt32(&running, 1)
+		once.Do(func() { close(started) })
+		time.Sleep(20 * time.Millisecond)
+		atomic.StoreInt32(&running, 0)
+	})
+	<-started
+	cancel()
+	assert.Equal(int32(0), atomic.LoadInt32(&running), "cancel should wait for f to return")
+
+	defer SetScheduler(nil)
+	DefaultLogger.SetScheduler(nil)
+	assert.Equal(tickerScheduler{}, DefaultLogger.getScheduler())
+}
+
+func TestPrinter(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	writer := New(&buf, "> ", 0)
+	defer writer.Close()
+	report := func(p Printer) {
+		p.Printf("%d of %d", 3, 4)
+		p.Print(" done")
+		p.Println()
+	}
+	report(writer)
+	assert.Equal("> 3 of 4 done\n", buf.String())
+
+	buf.Reset()
+	assert.NotPanics(func() { report(NopPrinter{}) })
+	assert.Equal("", buf.String())
+}
+
+func TestPrefix(t *testing.T) {
+	assert := assert.New(t)
+	now := func() time.Time { return time.Date(2017, 3, 4, 5, 6, 7, 8009000, time.Local) }
+	testEquivalence := func(template string, flags int, expected string) {
+		var buf1 bytes.Buffer
+		writer1 := New(&buf1, template, 0)
+		defer writer1.Close()
+		writer1.SetNowFunc(now)
+		var buf2 bytes.Buffer
+		writer2 := New(&buf2, "", flags)
+		defer writer2.Close()
+		writer2.SetNowFunc(now)
+		writer1.Printf("Hi\n")
+		writer2.Printf("Hi\n")
+		assert.Equal(expected+"Hi\n", buf1.String())
+		assert.Equal(buf1.String(), buf2.String())
+	}
+	testEquivalence("{date} {time} ", Ldate|Ltime, "2017/03/04 05:06:07 ")
+	testEquivalence("{date} {time micros} ", Ldate|Ltime|Lmicroseconds, "2017/03/04 05:06:07.008009 ")
+	testEquivalence("{isodate} ", Lisodate, "2017-03-04T05:06:07 ")
+	testEquivalence("{isodate micros} ", Lisodate|Lmicroseconds, "2017-03-04T05:06:07.008009 ")
+}
 
 // TODO test &/or implement:
 // - Set custom ANSI template regexp specifically or globally
@@ -0,0 +1,56 @@
+package alog
+
+// A LinePolicy says what to do with a line longer than the limit set with
+// SetMaxLineLength.
+type LinePolicy int
+
+const (
+	// LineTruncate cuts the line off with an ellipsis at the end.
+	LineTruncate LinePolicy = iota
+	// LineElide cuts the middle out of the line, keeping its start and end.
+	LineElide
+	// LineWrap splits the line up into several, at spaces where possible.
+	LineWrap
+)
+
+// SetMaxLineLength limits finished lines to length characters, not counting
+// the header, so that e.g. a dumped payload doesn't swamp the terminal or a
+// log file. Longer lines are dealt with according to policy. A length of zero,
+// the default, means no limit.
+func (l *Logger) SetMaxLineLength(length int, policy LinePolicy) {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	l.maxLineLength = length
+	l.linePolicy = policy
+}
+
+func SetMaxLineLength(length int, policy LinePolicy) { DefaultLogger.SetMaxLineLength(length, policy) }
+
+// limitLine applies the Logger's line length limit to line, returning the one
+// or more lines to write in its place.
+func (l *Logger) limitLine(line []byte) [][]byte {
+	length := l.maxLineLength
+	if length <= 0 || VisibleStringLen(line) <= length {
+		return [][]byte{line}
+	}
+	switch l.linePolicy {
+	case LineElide:
+		if length <= tempLineEllipsisLength {
+			return [][]byte{trimString(line, length)}
+		}
+		tailLength := (length - tempLineEllipsisLength) / 2
+		head := trimString(line, length-tempLineEllipsisLength-tailLength)
+		head = append(head, getActiveAnsiCodes(head).getResetBytes()...)
+		head = append(head, tempLineEllipsis...)
+		tail := trimStringLeft(line, VisibleStringLen(line)-tailLength)
+		return [][]byte{append(head, tail...)}
+	case LineWrap:
+		var lines [][]byte
+		for _, part := range wrapLine(string(line), length) {
+			lines = append(lines, []byte(part))
+		}
+		return lines
+	}
+	return [][]byte{trimStringEllipsis(line, length)}
+}
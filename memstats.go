@@ -0,0 +1,70 @@
+package alog
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// LogMemStats prints a one-line snapshot of the Go runtime's memory use, e.g.
+// "mem: heap 12.3MB, sys 40.1MB, 42 goroutines, 17 GCs, last pause 120µs".
+// Long-running tools can print it every so often with LogEvery.
+func (l *Logger) LogMemStats() {
+	if l.isDiscarded() {
+		return
+	}
+	l.emit(l.newEntry(2), []byte(formatMemStats()+"\n"), false)
+}
+
+// LogResourceUsage is LogMemStats, followed by the process's peak resident set
+// size and CPU time where the platform makes them available.
+func (l *Logger) LogResourceUsage() {
+	if l.isDiscarded() {
+		return
+	}
+	s := formatMemStats()
+	if maxRSS, user, sys, ok := resourceUsage(); ok {
+		s += fmt.Sprintf(", max rss %s, cpu %s user %s sys", highlight(formatBytes(maxRSS)),
+			highlight(strings.TrimSpace(FormatDuration(user))), highlight(strings.TrimSpace(FormatDuration(sys))))
+	}
+	l.emit(l.newEntry(2), []byte(s+"\n"), false)
+}
+
+func LogMemStats()      { DefaultLogger.LogMemStats() }
+func LogResourceUsage() { DefaultLogger.LogResourceUsage() }
+
+func formatMemStats() string {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	s := fmt.Sprintf("mem: heap %s, sys %s, %s goroutines, %s GCs",
+		highlight(formatBytes(int64(m.HeapAlloc))), highlight(formatBytes(int64(m.Sys))),
+		highlight(fmt.Sprint(runtime.NumGoroutine())), highlight(fmt.Sprint(m.NumGC)))
+	if m.NumGC > 0 {
+		pause := time.Duration(m.PauseNs[(m.NumGC+255)%256])
+		s += ", last pause " + highlight(pause.String())
+	}
+	return s
+}
+
+// highlight returns s colored as a value.
+func highlight(s string) string {
+	return colorize(s, ColorCyan)
+}
+
+// formatBytes returns n as a number of bytes, in the largest unit that keeps it
+// at least 1, e.g. "12.3MB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	value := float64(n)
+	for _, suffix := range []string{"KB", "MB", "GB", "TB"} {
+		value /= unit
+		if value < unit || suffix == "TB" {
+			return fmt.Sprintf("%.1f%s", value, suffix)
+		}
+	}
+	panic("unreachable")
+}
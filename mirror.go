@@ -0,0 +1,28 @@
+package alog
+
+import "io"
+
+// MirrorTo also writes everything written to the Logger's writer to w, exactly
+// as written: finished lines along with partial lines and the cursor movements
+// that draw them. This is for recording a session as the user saw it (with
+// asciinema, say) while it carries on as usual. Like SetTerminalWidth, this
+// applies to every Logger sharing the Logger's writer. Call the returned
+// function to stop.
+func (l *Logger) MirrorTo(w io.Writer) (stop func()) {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	ws.mirrors = append(ws.mirrors, w)
+	return func() {
+		ws.lock()
+		defer ws.unlock()
+		for i, mirror := range ws.mirrors {
+			if mirror == w {
+				ws.mirrors = append(ws.mirrors[:i:i], ws.mirrors[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+func MirrorTo(w io.Writer) (stop func()) { return DefaultLogger.MirrorTo(w) }
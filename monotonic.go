@@ -0,0 +1,42 @@
+package alog
+
+import "time"
+
+// SetMonotonicTimingEnabled determines whether the durations shown by the
+// {elapsed} prefix field, Lelapsed, Timer and Stopwatch are measured with the
+// monotonic clock (the default), which isn't thrown off by the wall clock being
+// adjusted (by NTP, say) while something is being timed. Disabling it measures
+// them by the wall clock instead, which counts time spent suspended. Either way,
+// durations are never shown as negative.
+func (l *Logger) SetMonotonicTimingEnabled(flag bool) {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	l.monotonicTiming = boolPointer(flag)
+}
+func (l *Logger) EnableMonotonicTiming()  { l.SetMonotonicTimingEnabled(true) }
+func (l *Logger) DisableMonotonicTiming() { l.SetMonotonicTimingEnabled(false) }
+
+func EnableMonotonicTiming()  { DefaultLogger.EnableMonotonicTiming() }
+func DisableMonotonicTiming() { DefaultLogger.DisableMonotonicTiming() }
+
+// between returns the time from start to end, measured according to
+// SetMonotonicTimingEnabled. Must be called with the writer's lock held.
+func (l *Logger) between(start, end time.Time) time.Duration {
+	if !isTrueDefaulted(l.monotonicTiming, l.defaults().monotonicTiming) {
+		// Round(0) strips the monotonic clock reading
+		start, end = start.Round(0), end.Round(0)
+	}
+	if d := end.Sub(start); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// since is between for callers that don't hold the writer's lock.
+func (l *Logger) since(start, end time.Time) time.Duration {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	return l.between(start, end)
+}
@@ -0,0 +1,237 @@
+package alog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"time"
+)
+
+// MsgpackEncoder encodes each entry as a MessagePack map, which is smaller and
+// cheaper to produce than JSON, for forwarding large volumes of lines to
+// another process, e.g. over a pipe or socket. The map has the keys "time"
+// (nanoseconds since the Unix epoch), "level", "msg", and, when known, "file"
+// and "line". MsgpackDecoder reads them back.
+type MsgpackEncoder struct{}
+
+func (MsgpackEncoder) Encode(buf []byte, e *Entry) []byte {
+	fields := 3
+	if e.File != "" {
+		fields += 2
+	}
+	buf = append(buf, 0x80|byte(fields)) // fixmap
+	buf = appendMsgpackString(buf, "time")
+	buf = appendMsgpackInt(buf, e.Time.UnixNano())
+	buf = appendMsgpackString(buf, "level")
+	buf = appendMsgpackInt(buf, int64(e.Level))
+	buf = appendMsgpackString(buf, "msg")
+	buf = appendMsgpackString(buf, e.Message)
+	if e.File != "" {
+		buf = appendMsgpackString(buf, "file")
+		buf = appendMsgpackString(buf, e.File)
+		buf = appendMsgpackString(buf, "line")
+		buf = appendMsgpackInt(buf, int64(e.Line))
+	}
+	return buf
+}
+
+func appendMsgpackString(buf []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		buf = append(buf, 0xa0|byte(n))
+	case n <= 0xff:
+		buf = append(buf, 0xd9, byte(n))
+	case n <= 0xffff:
+		buf = append(buf, 0xda, byte(n>>8), byte(n))
+	default:
+		buf = append(buf, 0xdb, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+	return append(buf, s...)
+}
+
+func appendMsgpackInt(buf []byte, v int64) []byte {
+	if v >= -32 && v < 128 {
+		return append(buf, byte(v)) // positive or negative fixint
+	}
+	buf = append(buf, 0xd3)
+	for shift := 56; shift >= 0; shift -= 8 {
+		buf = append(buf, byte(v>>uint(shift)))
+	}
+	return buf
+}
+
+// A MsgpackDecoder reads entries written with MsgpackEncoder.
+type MsgpackDecoder struct {
+	r *bufio.Reader
+}
+
+func NewMsgpackDecoder(r io.Reader) *MsgpackDecoder {
+	return &MsgpackDecoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads the next entry into e. It returns io.EOF when there are no
+// more, and skips keys it doesn't know.
+func (d *MsgpackDecoder) Decode(e *Entry) error {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if b&0xf0 != 0x80 {
+		return fmt.Errorf("msgpack: expected a map, got 0x%02x", b)
+	}
+	*e = Entry{}
+	for i := 0; i < int(b&0x0f); i++ {
+		key, err := d.readString()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		var n int64
+		switch key {
+		case "msg":
+			e.Message, err = d.readString()
+		case "file":
+			e.File, err = d.readString()
+		case "time", "level", "line":
+			n, err = d.readInt()
+		default:
+			err = d.skip(1)
+		}
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		switch key {
+		case "time":
+			e.Time = time.Unix(0, n)
+		case "level":
+			e.Level = Level(n)
+		case "line":
+			e.Line = int(n)
+		}
+	}
+	return nil
+}
+
+// skip reads past the next count values, whatever their types.
+func (d *MsgpackDecoder) skip(count uint64) error {
+	for ; count > 0; count-- {
+		b, err := d.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		var size uint64 // bytes of payload to discard
+		switch {
+		case b < 0x80 || b >= 0xe0 || b == 0xc0 || b == 0xc2 || b == 0xc3:
+			// fixint, nil or bool
+		case b <= 0x8f: // fixmap
+			count += 2 * uint64(b&0x0f)
+		case b <= 0x9f: // fixarray
+			count += uint64(b & 0x0f)
+		case b <= 0xbf: // fixstr
+			size = uint64(b & 0x1f)
+		case b >= 0xc4 && b <= 0xc6, b >= 0xd9 && b <= 0xdb: // bin or str 8 to 32
+			first := byte(0xc4)
+			if b >= 0xd9 {
+				first = 0xd9
+			}
+			if size, err = d.readBigEndian(1 << (b - first)); err != nil {
+				return err
+			}
+		case b >= 0xc7 && b <= 0xc9: // ext 8 to 32
+			if size, err = d.readBigEndian(1 << (b - 0xc7)); err != nil {
+				return err
+			}
+			size++ // the type
+		case b == 0xca:
+			size = 4
+		case b == 0xcb:
+			size = 8
+		case b >= 0xcc && b <= 0xd3: // uint or int 8 to 64
+			size = 1 << ((b - 0xcc) % 4)
+		case b >= 0xd4 && b <= 0xd8: // fixext 1 to 16
+			size = 1 + 1<<(b-0xd4)
+		case b == 0xdc || b == 0xdd: // array 16 or 32
+			n, err := d.readBigEndian(2 << (b - 0xdc))
+			if err != nil {
+				return err
+			}
+			count += n
+		case b == 0xde || b == 0xdf: // map 16 or 32
+			n, err := d.readBigEndian(2 << (b - 0xde))
+			if err != nil {
+				return err
+			}
+			count += 2 * n
+		default:
+			return fmt.Errorf("msgpack: unexpected 0x%02x", b)
+		}
+		if _, err := d.r.Discard(int(size)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *MsgpackDecoder) readString() (string, error) {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	var n int
+	switch {
+	case b&0xe0 == 0xa0:
+		n = int(b & 0x1f)
+	case b == 0xd9 || b == 0xda || b == 0xdb:
+		size := 1 << (b - 0xd9) // 1, 2 or 4 bytes of length
+		length, err := d.readBigEndian(size)
+		if err != nil {
+			return "", err
+		}
+		n = int(length)
+	default:
+		return "", fmt.Errorf("msgpack: expected a string, got 0x%02x", b)
+	}
+	s := make([]byte, n)
+	if _, err := io.ReadFull(d.r, s); err != nil {
+		return "", err
+	}
+	return string(s), nil
+}
+
+func (d *MsgpackDecoder) readInt() (int64, error) {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case b < 0x80 || b >= 0xe0:
+		return int64(int8(b)), nil
+	case b >= 0xcc && b <= 0xcf: // uint 8 to 64
+		n, err := d.readBigEndian(1 << (b - 0xcc))
+		return int64(n), err
+	case b >= 0xd0 && b <= 0xd3: // int 8 to 64
+		size := 1 << (b - 0xd0)
+		n, err := d.readBigEndian(size)
+		shift := uint(64 - 8*size)
+		return int64(n<<shift) >> shift, err
+	}
+	return 0, fmt.Errorf("msgpack: expected an integer, got 0x%02x", b)
+}
+
+func (d *MsgpackDecoder) readBigEndian(size int) (uint64, error) {
+	var n uint64
+	for i := 0; i < size; i++ {
+		b, err := d.r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		n = n<<8 | uint64(b)
+	}
+	return n, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,15 @@
+//go:build go1.18
+// +build go1.18
+
+package alog
+
+// Must returns v if err is nil. Otherwise, it prints err in red along with
+// where Must was called from, and exits like Fatal, e.g.:
+//
+//	cfg := alog.Must(loadConfig(path))
+func Must[T any](v T, err error) T {
+	if err != nil {
+		DefaultLogger.exitErr(2, err)
+	}
+	return v
+}
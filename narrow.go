@@ -0,0 +1,87 @@
+package alog
+
+import "unicode/utf8"
+
+// A NarrowMode says how partial lines are fitted to a terminal that's too
+// narrow to leave room for much text after the header.
+type NarrowMode int
+
+const (
+	// NarrowTruncate cuts the line off at the right with an ellipsis, as for
+	// any other line that's too long. This is the default.
+	NarrowTruncate NarrowMode = iota
+	// NarrowDropHeader leaves out the header, so the terminal is given over to
+	// the text.
+	NarrowDropHeader
+	// NarrowTruncateLeft cuts the line off at the left instead, so that the
+	// latest text stays in view.
+	NarrowTruncateLeft
+	// NarrowClamp fits the line to at least enough columns for the header and
+	// some text, and leaves it to the terminal to wrap the rest.
+	NarrowClamp
+)
+
+// SetNarrowMode sets how partial lines are fitted to the terminal when it's
+// too narrow for the header and at least a few columns of text. Like
+// SetTerminalWidth, this applies to every Logger sharing the Logger's writer.
+func (l *Logger) SetNarrowMode(mode NarrowMode) {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	ws.narrowMode = mode
+	ws.lastStatusWidth = -1 // compose the status line again next time
+}
+
+func SetNarrowMode(mode NarrowMode) { DefaultLogger.SetNarrowMode(mode) }
+
+// fitNarrow applies the writer's NarrowMode to line, a partial line formatted
+// with a header headerWidth columns wide, when the header leaves too little of
+// the maxWidth columns for text. It returns the line along with the width it
+// should be fitted to from here on. Must be called with the writer locked.
+func (ws *WriterState) fitNarrow(line []byte, headerWidth int, maxWidth int) ([]byte, int) {
+	if maxWidth-headerWidth >= minLineWidth {
+		return line, maxWidth
+	}
+	switch ws.narrowMode {
+	case NarrowDropHeader:
+		return trimStringLeft(line, headerWidth), maxWidth
+	case NarrowTruncateLeft:
+		excess := VisibleStringLen(line) - maxWidth
+		if excess <= 0 {
+			return line, maxWidth
+		}
+		if maxWidth <= tempLineEllipsisLength {
+			return trimStringLeft(line, excess), maxWidth
+		}
+		return append(append([]byte{}, tempLineEllipsis...), trimStringLeft(line, excess+tempLineEllipsisLength)...), maxWidth
+	case NarrowClamp:
+		return line, headerWidth + minLineWidth
+	}
+	return line, maxWidth
+}
+
+// headerWidth returns how many columns the header for e takes up.
+func (l *Logger) headerWidth(e *entry) int {
+	header := getLineBuffer()
+	l.formatHeader(header, e)
+	width := VisibleStringLen(*header)
+	putLineBuffer(header)
+	return width
+}
+
+// trimStringLeft removes the first length visible characters from buf, keeping
+// whatever colors they were in for the rest of it.
+func trimStringLeft(buf []byte, length int) []byte {
+	i := 0
+	for i < len(buf) && length > 0 {
+		if n := scanSGR(buf[i:]); n > 0 {
+			i += n
+			continue
+		}
+		_, n := utf8.DecodeRune(buf[i:])
+		i += n
+		length--
+	}
+	tmp := getActiveAnsiCodes(buf[:i]).appendSetBytes(nil)
+	return append(tmp, buf[i:]...)
+}
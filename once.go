@@ -0,0 +1,73 @@
+package alog
+
+import (
+	"fmt"
+	"sync"
+)
+
+// onceKeys holds the keys already used with Oncef, by any Logger other than
+// standalone ones, which have keys of their own.
+var onceKeys sync.Map
+
+// firstTime reports whether this is the first time key has been seen.
+func firstTime(key string) bool {
+	_, seen := onceKeys.LoadOrStore(key, struct{}{})
+	return !seen
+}
+
+// firstTime reports whether this is the first time key has been seen by the
+// Logger, or, unless it's standalone, any other.
+func (l *Logger) firstTime(key string) bool {
+	if l.seenKeys == nil {
+		return firstTime(key)
+	}
+	_, seen := l.seenKeys.LoadOrStore(key, struct{}{})
+	return !seen
+}
+
+// Oncef prints a line like Warnf does, but only the first time it's called
+// with key, by any Logger (or, for one made with NewStandalone, by it or those
+// derived from it), for warnings that would otherwise repeat every time
+// through a loop.
+func (l *Logger) Oncef(key string, format string, v ...interface{}) {
+	l.oncef(2, key, format, v)
+}
+
+func Oncef(key string, format string, v ...interface{}) {
+	DefaultLogger.oncef(2, key, format, v)
+}
+
+func (l *Logger) oncef(calldepth int, key string, format string, v []interface{}) {
+	if l.isDiscarded() || !l.firstTime("once:"+key) {
+		return
+	}
+	l.logf(calldepth+1, LevelWarn, format, v)
+}
+
+// Deprecated warns that what is deprecated, along with advice on what to do
+// instead, e.g.:
+//
+//	log.Deprecated("flag --foo", "use --bar instead")
+//
+// prints "deprecated: flag --foo (use --bar instead)". Each what is warned
+// about at most once per process, or per standalone Logger, as for Oncef.
+func (l *Logger) Deprecated(what string, advice string) {
+	l.deprecated(2, what, advice)
+}
+
+func Deprecated(what string, advice string) {
+	DefaultLogger.deprecated(2, what, advice)
+}
+
+func (l *Logger) deprecated(calldepth int, what string, advice string) {
+	if l.isDiscarded() || !l.firstTime("deprecated:"+what) {
+		return
+	}
+	e := l.newEntry(calldepth + 1)
+	e.level = LevelWarn
+	s := colorize("deprecated:", ColorYellow) + " " + what
+	if advice != "" {
+		s += fmt.Sprintf(" (%s)", advice)
+	}
+	l.emit(e, []byte(s+"\n"), false)
+}
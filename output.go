@@ -0,0 +1,265 @@
+package alog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Level is the severity of a line. Print and friends log at LevelInfo.
+type Level int
+
+const (
+	LevelDebug Level = iota - 1
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (lvl Level) String() string {
+	switch lvl {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	}
+	return fmt.Sprintf("level(%d)", int(lvl))
+}
+
+// An Entry is a finished line as handed to an Encoder.
+type Entry struct {
+	Time    time.Time
+	Level   Level
+	File    string // only set when the Logger's flags ask for the caller
+	Line    int
+	Message string        // the text of the line, without the header or any colors
+	Access  *AccessRecord // only set for lines logged by AccessLog
+}
+
+// An Encoder renders entries for an additional output, in place of the usual
+// header and text. Encode appends the encoded entry, including any line
+// terminator, to buf and returns the extended buffer.
+type Encoder interface {
+	Encode(buf []byte, e *Entry) []byte
+}
+
+// JSONEncoder encodes each entry as a JSON object on its own line.
+type JSONEncoder struct{}
+
+type jsonEntry struct {
+	Time    string `json:"time"`
+	Level   string `json:"level"`
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"msg"`
+}
+
+func (JSONEncoder) Encode(buf []byte, e *Entry) []byte {
+	data, err := json.Marshal(jsonEntry{
+		Time:    e.Time.Format(time.RFC3339Nano),
+		Level:   e.Level.String(),
+		File:    e.File,
+		Line:    e.Line,
+		Message: e.Message,
+	})
+	if err != nil {
+		return buf
+	}
+	buf = append(buf, data...)
+	return append(buf, byteNewline)
+}
+
+// OutputOptions control what an additional output added with AddOutput
+// receives.
+type OutputOptions struct {
+	StripColor bool    // remove ANSI escapes from each line
+	MinLevel   Level   // skip lines below this level
+	Encoder    Encoder // if set, encode each line with this instead
+}
+
+type output struct {
+	w    io.Writer
+	opts OutputOptions
+}
+
+// AddOutput makes the Logger also write each finished line to w. Partial
+// lines and the status line only ever go to the Logger's own writer, and w is
+// written to directly, so it must be safe for concurrent use if it's shared
+// with other Loggers.
+func (l *Logger) AddOutput(w io.Writer, opts OutputOptions) {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	l.outputs = append(l.outputs, output{w: w, opts: opts})
+}
+
+// RemoveOutput stops writing to w, previously added with AddOutput.
+func (l *Logger) RemoveOutput(w io.Writer) {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	outputs := l.outputs[:0]
+	for _, o := range l.outputs {
+		if o.w != w {
+			outputs = append(outputs, o)
+		}
+	}
+	l.outputs = outputs
+}
+
+// SetLevel sets the lowest level the Logger prints; lines below it are
+// dropped everywhere. The default is LevelInfo. In quiet or verbose mode, it's
+// also the level that turning the mode off goes back to.
+func (l *Logger) SetLevel(level Level) {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	l.setLevelInt(level)
+}
+
+// setLevelInt is SetLevel, but must be called with the writer locked.
+func (l *Logger) setLevelInt(level Level) {
+	l.level = level
+	if l.quiet {
+		l.levelBeforeQuiet = level
+	}
+	if l.verbose {
+		l.levelBeforeVerbose = level
+	}
+	configChanged()
+}
+
+// SetErrorOutput sends finished lines at or above level to w instead of the
+// Logger's own writer, e.g. warnings and errors to os.Stderr while everything
+// else goes to os.Stdout. If both are the same terminal, lines keep going
+// through the Logger's own writer so that they stay in order with the status
+// line. A nil w turns this off. w must not be a writer whose Loggers in turn
+// send their errors to this Logger's writer.
+func (l *Logger) SetErrorOutput(w io.Writer, level Level) {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	l.errOut = w
+	l.errLevel = level
+	l.updateErrOutShared()
+}
+
+func (l *Logger) updateErrOutShared() {
+	l.errOutShared = l.errOut != nil && isSameTerminal(l.errOut, l.out)
+}
+
+// isSameTerminal reports whether a and b write to the same place as far as the
+// user can see.
+func isSameTerminal(a, b io.Writer) bool {
+	if a == b {
+		return true
+	}
+	aTerminal, aOk := terminalID(a)
+	bTerminal, bOk := terminalID(b)
+	return aOk && bOk && aTerminal == bTerminal
+}
+
+// NewSplitLogger creates a Logger that writes to os.Stdout, except for
+// warnings and errors, which go to os.Stderr.
+func NewSplitLogger(prefix string, flag int) *Logger {
+	l := New(os.Stdout, prefix, flag)
+	l.SetErrorOutput(os.Stderr, LevelWarn)
+	return l
+}
+
+// isErrOutLine reports whether a line logged at level goes to the error
+// output rather than the Logger's own writer.
+func (l *Logger) isErrOutLine(level Level) bool {
+	return l.errOut != nil && !l.errOutShared && level >= l.errLevel
+}
+
+// writeErrOut writes a finished line to the error output by way of its own
+// WriterState, so that it goes around any partial lines drawn there, and is
+// held and mirrored along with everything else written there. Must be called
+// with the Logger's writer locked; the error output's is always locked after
+// it, never before.
+func (l *Logger) writeErrOut(formatted []byte) {
+	ws := getWriterState(l.errOut)
+	ws.lock()
+	defer ws.unlock()
+	if len(ws.tempLoggers) != 0 || ws.multiline || !ws.cursorIsAtBegin || len(ws.lastTemp[0]) != 0 {
+		writeLine(l.errOut, formatted)
+		updateTempOutput(l.errOut)
+	} else {
+		ws.pending = append(ws.pending, formatted...)
+		ws.pending = append(ws.pending, getActiveAnsiCodes(formatted).getResetBytes()...)
+		ws.pending = append(ws.pending, byteNewline)
+	}
+	ws.writePending(l.errOut)
+}
+
+// writeOutputs writes a just-finished line to each additional output.
+// formatted is the line as rendered for the Logger's own writer.
+func (l *Logger) writeOutputs(formatted []byte, line []byte, e *entry) {
+	for _, o := range l.outputs {
+		if e.level < o.opts.MinLevel {
+			continue
+		}
+		buf := getLineBuffer()
+		if o.opts.Encoder != nil {
+			*buf = o.opts.Encoder.Encode(*buf, &Entry{
+				Time:    e.now,
+				Level:   e.level,
+				File:    e.callerFile,
+				Line:    e.callerLine,
+				Message: string(Uncolorize(line)),
+				Access:  e.access,
+			})
+		} else {
+			if o.opts.StripColor {
+				*buf = append(*buf, Uncolorize(formatted)...)
+			} else {
+				*buf = append(*buf, formatted...)
+				*buf = append(*buf, getActiveAnsiCodes(formatted).getResetBytes()...)
+			}
+			*buf = append(*buf, byteNewline)
+		}
+		if len(*buf) != 0 {
+			o.w.Write(*buf)
+		}
+		putLineBuffer(buf)
+	}
+}
+
+// logf prints a line at the given level, adding a newline if there isn't one.
+func (l *Logger) logf(calldepth int, level Level, format string, v []interface{}) {
+	if !l.isPrinting(level) {
+		return
+	}
+	if !strings.HasSuffix(format, "\n") {
+		format += "\n"
+	}
+	e := l.newEntry(calldepth + 1)
+	e.level = level
+	msg := getMessageBuffer()
+	l.fprintf(msg, format, v)
+	l.emit(e, msg.Bytes(), false)
+	putMessageBuffer(msg)
+}
+
+// Debugf prints a line at LevelDebug, which is hidden unless the Logger's level
+// is lowered with SetLevel.
+func (l *Logger) Debugf(format string, v ...interface{}) { l.logf(2, LevelDebug, format, v) }
+
+// Infof prints a line at LevelInfo.
+func (l *Logger) Infof(format string, v ...interface{}) { l.logf(2, LevelInfo, format, v) }
+
+// Warnf prints a line at LevelWarn.
+func (l *Logger) Warnf(format string, v ...interface{}) { l.logf(2, LevelWarn, format, v) }
+
+func Debugf(format string, v ...interface{}) { DefaultLogger.logf(2, LevelDebug, format, v) }
+func Infof(format string, v ...interface{})  { DefaultLogger.logf(2, LevelInfo, format, v) }
+func Warnf(format string, v ...interface{})  { DefaultLogger.logf(2, LevelWarn, format, v) }
+func SetLevel(level Level)                   { DefaultLogger.SetLevel(level) }
@@ -0,0 +1,52 @@
+package alog
+
+import (
+	"io"
+	"strings"
+)
+
+// PrefixPipe copies everything read from r to the Logger until EOF, with each
+// line set off by a gutter of label and a bar, in the named style, e.g.:
+//
+//	go log.PrefixPipe(webStdout, "web", "cyan")
+//	go log.PrefixPipe(dbStdout, "db ", "magenta")
+//
+// prints lines like "web | listening on :8080". style is a comma-separated list
+// of color names, as in color templates. Unfinished lines are shown as partial
+// lines of their own, so several pipes can run at once without cutting into
+// each other's lines. PrefixPipe returns the error from r, if it's not EOF.
+func (l *Logger) PrefixPipe(r io.Reader, label string, style string) error {
+	pipe := l.derive(colorize(label+" |", parseStyle(style)...) + " ")
+	defer pipe.Destroy()
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 && !pipe.isDiscarded() {
+			pipe.emit(pipe.newEntry(2), buf[:n], false)
+		}
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+func PrefixPipe(r io.Reader, label string, style string) error {
+	return DefaultLogger.PrefixPipe(r, label, style)
+}
+
+// parseStyle returns the colors named in style, a comma-separated list of color
+// names, ignoring any it doesn't know.
+func parseStyle(style string) []ColorCode {
+	var colors []ColorCode
+	ansiColorCodesMutex.RLock()
+	defer ansiColorCodesMutex.RUnlock()
+	for _, name := range strings.Split(style, ",") {
+		if color, ok := ansiColorCodes[strings.TrimSpace(name)]; ok {
+			colors = append(colors, color)
+		}
+	}
+	return colors
+}
@@ -0,0 +1,70 @@
+package alog
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PrintTemplate prints format with each {name} in it replaced by values[name],
+// e.g.:
+//
+//	log.PrintTemplate("deploy {service} to {env}\n", map[string]interface{}{"service": "api", "env": "prod"})
+//
+// Values are styled by type: errors in red, numbers and bools in yellow, and
+// everything else in cyan. Placeholders missing from values are left in place
+// and flagged in red, as in "{env?}". Use "{{" for a literal "{". Color
+// templates in format are expanded, but not ones in values.
+func (l *Logger) PrintTemplate(format string, values map[string]interface{}) {
+	if l.isDiscarded() {
+		return
+	}
+	s := fillPlaceholders(l.expandFormat(format, nil), values)
+	l.emit(l.newEntry(2), []byte(s), false)
+}
+
+func PrintTemplate(format string, values map[string]interface{}) {
+	DefaultLogger.PrintTemplate(format, values)
+}
+
+func fillPlaceholders(format string, values map[string]interface{}) string {
+	var out strings.Builder
+	for {
+		i := strings.IndexByte(format, '{')
+		if i == -1 {
+			break
+		}
+		out.WriteString(format[:i])
+		format = format[i+1:]
+		if strings.HasPrefix(format, "{") {
+			out.WriteByte('{')
+			format = format[1:]
+			continue
+		}
+		end := strings.IndexByte(format, '}')
+		if end == -1 {
+			out.WriteByte('{')
+			continue
+		}
+		name := format[:end]
+		format = format[end+1:]
+		value, ok := values[name]
+		if !ok {
+			out.WriteString(colorize("{"+name+"?}", ColorRed))
+			continue
+		}
+		out.WriteString(colorize(fmt.Sprint(value), valueColor(value)))
+	}
+	out.WriteString(format)
+	return out.String()
+}
+
+// valueColor returns the color PrintTemplate gives value.
+func valueColor(value interface{}) ColorCode {
+	switch value.(type) {
+	case error:
+		return ColorRed
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, uintptr, float32, float64:
+		return ColorYellow
+	}
+	return ColorCyan
+}
@@ -5,3 +5,25 @@ type PrintLogger interface {
 	Println(a ...interface{})
 	Write(buf []byte) (int, error)
 }
+
+// Printer is the minimal interface for printing lines. Libraries can accept a
+// Printer rather than depend on *Logger, which satisfies it, and default to a
+// NopPrinter.
+type Printer interface {
+	Printf(format string, a ...interface{})
+	Print(a ...interface{})
+	Println(a ...interface{})
+}
+
+// NopPrinter is a Printer that prints nothing.
+type NopPrinter struct{}
+
+func (NopPrinter) Printf(format string, a ...interface{}) {}
+func (NopPrinter) Print(a ...interface{})                 {}
+func (NopPrinter) Println(a ...interface{})               {}
+
+var (
+	_ Printer     = (*Logger)(nil)
+	_ Printer     = NopPrinter{}
+	_ PrintLogger = (*Logger)(nil)
+)
@@ -0,0 +1,121 @@
+package alog
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// ProgressFDEnv is the environment variable through which Run tells a command
+// the file descriptor on which to report its progress; see
+// ReportProgressToParent.
+const ProgressFDEnv = "ALOG_PROGRESS_FD"
+
+// ReportProgressToParent connects the Logger to the program that ran this one
+// with Run, if any, so that the parent shows the Logger's partial lines and
+// Steps as they happen, on the command's own partial line. It works like
+// SetEventOutput, on the file descriptor named by ProgressFDEnv. It returns
+// whether there was a parent to report to.
+func (l *Logger) ReportProgressToParent() bool {
+	fd, err := strconv.Atoi(os.Getenv(ProgressFDEnv))
+	if err != nil || fd < 3 {
+		return false
+	}
+	// Commands this one runs get channels of their own
+	os.Unsetenv(ProgressFDEnv)
+	l.SetEventOutput(os.NewFile(uintptr(fd), "progress"))
+	return true
+}
+
+func ReportProgressToParent() bool { return DefaultLogger.ReportProgressToParent() }
+
+// progressChannel shows the progress a command reports on its partial line.
+type progressChannel struct {
+	l    *Logger
+	r    *os.File
+	w    *os.File // the command's end, closed once it's started
+	done chan struct{}
+}
+
+// attachProgressChannel passes cmd the file descriptor and environment
+// variable for ReportProgressToParent, before it's started. It returns a nil
+// channel, which does nothing, where commands can't inherit the descriptor.
+func (l *Logger) attachProgressChannel(cmd *exec.Cmd) (*progressChannel, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	fd, ok := passExtraFile(cmd, w)
+	if !ok {
+		r.Close()
+		w.Close()
+		return nil, nil
+	}
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Env = append(env[:len(env):len(env)], ProgressFDEnv+"="+strconv.Itoa(fd))
+	return &progressChannel{l: l.derive(""), r: r, w: w, done: make(chan struct{})}, nil
+}
+
+// start begins reading events, once the command has been started (or failed
+// to).
+func (p *progressChannel) start() {
+	if p == nil {
+		return
+	}
+	p.w.Close()
+	go p.run()
+}
+
+func (p *progressChannel) run() {
+	defer close(p.done)
+	defer p.r.Close()
+	scanner := bufio.NewScanner(p.r)
+	scanner.Buffer(nil, DefaultMaxPartialLineLength)
+	for scanner.Scan() {
+		var ev jsonEvent
+		if json.Unmarshal(scanner.Bytes(), &ev) != nil {
+			continue
+		}
+		switch ev.Event {
+		case "partial":
+			if ev.Msg != nil {
+				p.show(*ev.Msg)
+			}
+		case "step_start":
+			p.show(ev.Name + "... ")
+		case "line", "step_end":
+			p.show("") // the command prints its own lines
+		}
+	}
+}
+
+// show replaces the partial line with s.
+func (p *progressChannel) show(s string) {
+	if p.l.isDiscarded() {
+		return
+	}
+	if s != "" {
+		p.l.emit(p.l.newEntry(1), []byte(s), true)
+		return
+	}
+	ws := getWriterState(p.l.out)
+	ws.lock()
+	p.l.clearPartialLine(ws)
+	ws.unlock()
+}
+
+// wait waits for the command's end of the channel to close, and clears its
+// partial line.
+func (p *progressChannel) wait() {
+	if p == nil {
+		return
+	}
+	<-p.done
+	p.show("")
+	p.l.Destroy()
+}
@@ -0,0 +1,16 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package alog
+
+import (
+	"os"
+	"os/exec"
+)
+
+// passExtraFile would arrange for cmd to inherit f, but os.StartProcess doesn't
+// support ExtraFiles here (on Windows, it fails with EWINDOWS), so commands run
+// with Run don't get a progress channel.
+func passExtraFile(cmd *exec.Cmd, f *os.File) (fd int, ok bool) {
+	return 0, false
+}
@@ -0,0 +1,16 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package alog
+
+import (
+	"os"
+	"os/exec"
+)
+
+// passExtraFile arranges for cmd to inherit f, and returns the file descriptor
+// it'll have in cmd.
+func passExtraFile(cmd *exec.Cmd, f *os.File) (fd int, ok bool) {
+	cmd.ExtraFiles = append(cmd.ExtraFiles, f)
+	return 2 + len(cmd.ExtraFiles), true
+}
@@ -0,0 +1,8 @@
+//go:build race
+// +build race
+
+package alog
+
+// The race detector randomly drops sync.Pool items, so allocation counts
+// aren't meaningful under it.
+const raceEnabled = true
@@ -0,0 +1,83 @@
+package alog
+
+import (
+	"bytes"
+	"fmt"
+	"runtime/debug"
+	"strings"
+)
+
+// A RecoverAction says what RecoverAndLog does after logging a panic.
+type RecoverAction int
+
+const (
+	RecoverContinue RecoverAction = iota // return normally
+	RecoverRepanic                       // panic again with the same value
+	RecoverExit                          // exit, like Fatal
+)
+
+// RecoverAndLog, when deferred, recovers from a panic and logs the value and a
+// colorized stack trace to l (the DefaultLogger if nil) at LevelError. It
+// flushes the Logger first, so a partial line is finished off where it stood
+// rather than split by the trace. Then it returns normally, or does whatever
+// then says, e.g.:
+//
+//	go func() {
+//		defer alog.RecoverAndLog(log, alog.RecoverExit)
+//		work()
+//	}()
+func RecoverAndLog(l *Logger, then ...RecoverAction) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if l == nil {
+		l = DefaultLogger
+	}
+	e := l.newEntry(2)
+	e.level = LevelError
+	s := colorize(fmt.Sprintf("panic: %v", r), ColorRed) + "\n" + colorizeStack(panicStack(debug.Stack()))
+	// Finish off whatever the Logger was in the middle of first
+	l.Flush()
+	action := RecoverContinue
+	if len(then) > 0 {
+		action = then[0]
+	}
+	switch action {
+	case RecoverExit:
+		l.exitFatal(e, s)
+	case RecoverRepanic:
+		l.emit(e, []byte(s), false)
+		l.Flush()
+		panic(r)
+	default:
+		l.emit(e, []byte(s), false)
+		l.Flush()
+	}
+}
+
+// panicStack cuts the frames of the panic machinery and the recovery itself
+// out of stack, leaving the goroutine header and the frames that panicked.
+func panicStack(stack []byte) []byte {
+	lines := bytes.SplitAfter(stack, []byte("\n"))
+	for i := 1; i+1 < len(lines); i++ {
+		if bytes.HasPrefix(lines[i], []byte("panic(")) {
+			return append(lines[0], bytes.Join(lines[i+2:], nil)...)
+		}
+	}
+	return stack
+}
+
+// colorizeStack dims the file and line of each frame in stack, so that the
+// function names stand out.
+func colorizeStack(stack []byte) string {
+	var out strings.Builder
+	for _, line := range strings.SplitAfter(strings.TrimRight(string(stack), "\n"), "\n") {
+		line = strings.TrimSuffix(line, "\n")
+		if strings.HasPrefix(line, "\t") {
+			line = "\t" + colorize(line[1:], ColorDim)
+		}
+		out.WriteString(line + "\n")
+	}
+	return out.String()
+}
@@ -0,0 +1,58 @@
+package alog
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Retry calls f until it succeeds, up to attempts times in all, waiting
+// backoff after the first failure and twice as long after each one after
+// that. While it waits, a partial line counts down to the next attempt, e.g.
+// "attempt 2/5 (retrying in 4s…) connection refused". If f only succeeds after
+// failing, that's logged ("succeeded on attempt 3/5"), and if it never does,
+// so is its last error, as an error line. Retry returns f's last error.
+func (l *Logger) Retry(attempts int, backoff time.Duration, f func() error) error {
+	if attempts < 1 {
+		attempts = 1
+	}
+	rl := l.derive("")
+	defer rl.Destroy()
+	ellipsis := rl.glyphs().ellipsis
+	var err error
+	delay := backoff
+	for attempt := 1; ; attempt++ {
+		if err = f(); err == nil {
+			if attempt > 1 && !rl.isDiscarded() {
+				line := colorize(fmt.Sprintf("succeeded on attempt %d/%d", attempt, attempts), ColorGreen)
+				rl.emit(rl.newEntry(2), []byte(line+"\n"), true)
+			}
+			return nil
+		}
+		if attempt == attempts {
+			break
+		}
+		message := colorize(err.Error(), ColorDim)
+		rl.wait(context.Background(), delay, func(remaining time.Duration) {
+			if !rl.isDiscarded() {
+				line := fmt.Sprintf("attempt %d/%d (retrying in %s%s) %s", attempt+1, attempts, formatCountdown(remaining), ellipsis, message)
+				rl.emit(rl.newEntry(1), []byte(line), true)
+			}
+		})
+		if delay <= math.MaxInt64/2 {
+			delay *= 2
+		}
+	}
+	if !rl.isDiscarded() {
+		e := rl.newEntry(2)
+		e.level = LevelError
+		line := colorize(fmt.Sprintf("failed after %d attempts: %v", attempts, err), ColorRed)
+		rl.emit(e, []byte(line+"\n"), true)
+	}
+	return err
+}
+
+func Retry(attempts int, backoff time.Duration, f func() error) error {
+	return DefaultLogger.Retry(attempts, backoff, f)
+}
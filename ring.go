@@ -0,0 +1,170 @@
+package alog
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// A RingBuffer remembers the last lines logged to it, at every level, including
+// those hidden by the Logger's level. It can be shared between Loggers.
+type RingBuffer struct {
+	mutex   sync.Mutex
+	entries []Entry
+	next    int
+	full    bool
+	added   int // lines added since the last Reset
+	dumped  int // the value of added when last dumped for an error
+}
+
+// NewRingBuffer creates a RingBuffer holding up to size lines.
+func NewRingBuffer(size int) *RingBuffer {
+	if size < 1 {
+		size = 1
+	}
+	return &RingBuffer{entries: make([]Entry, size)}
+}
+
+func (r *RingBuffer) add(e Entry) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.entries[r.next] = e
+	r.added++
+	r.next++
+	if r.next == len(r.entries) {
+		r.next = 0
+		r.full = true
+	}
+}
+
+// addHidden records the lines of a message that was dropped because of its
+// level, and so never made it as far as finishLine.
+func (r *RingBuffer) addHidden(s []byte, e *entry) {
+	for _, line := range bytes.Split(bytes.TrimSuffix(s, bytesNewline), bytesNewline) {
+		r.add(newEntryRecord(line, e))
+	}
+}
+
+// Entries returns the lines held, oldest first.
+func (r *RingBuffer) Entries() []Entry {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.entriesInt()
+}
+
+func (r *RingBuffer) entriesInt() []Entry {
+	var entries []Entry
+	if r.full {
+		entries = append(entries, r.entries[r.next:]...)
+	}
+	return append(entries, r.entries[:r.next]...)
+}
+
+// Reset forgets all the lines held.
+func (r *RingBuffer) Reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for i := range r.entries {
+		r.entries[i] = Entry{}
+	}
+	r.next = 0
+	r.full = false
+	r.added = 0
+	r.dumped = 0
+}
+
+// takeUndumped returns the lines added since it was last called, oldest first,
+// so that dumpRing shows each line at most once while leaving them all in
+// place for DumpRecent and Grep.
+func (r *RingBuffer) takeUndumped() []Entry {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	entries := r.entriesInt()
+	if n := r.added - r.dumped; n < len(entries) {
+		entries = entries[len(entries)-n:]
+	}
+	r.dumped = r.added
+	return entries
+}
+
+// Dump writes the lines held to w, one per line, oldest first.
+func (r *RingBuffer) Dump(w io.Writer) error {
+	var buf []byte
+	for _, e := range r.Entries() {
+		buf = appendEntryRecord(buf, &e)
+		buf = append(buf, byteNewline)
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+func newEntryRecord(line []byte, e *entry) Entry {
+	return Entry{
+		Time:    e.now,
+		Level:   e.level,
+		File:    e.callerFile,
+		Line:    e.callerLine,
+		Message: string(Uncolorize(line)),
+	}
+}
+
+// appendEntryRecord renders e the way lines are shown when dumped.
+func appendEntryRecord(buf []byte, e *Entry) []byte {
+	buf = e.Time.AppendFormat(buf, "15:04:05.000 ")
+	buf = append(buf, e.Level.String()...)
+	buf = append(buf, ' ')
+	if e.File != "" {
+		buf = append(buf, e.File...)
+		buf = append(buf, ':')
+		itoa(&buf, e.Line, -1)
+		buf = append(buf, ": "...)
+	}
+	return append(buf, e.Message...)
+}
+
+// SetRingBuffer makes the Logger record every line it's given in r, whether or
+// not it's shown. If dumpOnError is set, the lines recorded but hidden by the
+// Logger's level are printed just before the next error (or Fatal) line, to
+// give some context for it; they stay in r, but aren't printed before a later
+// error again. A nil r turns this off.
+func (l *Logger) SetRingBuffer(r *RingBuffer, dumpOnError bool) {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	l.ring = r
+	l.dumpOnError = dumpOnError
+	configChanged()
+}
+
+// DumpRecent writes the lines recorded in the Logger's RingBuffer to w.
+func (l *Logger) DumpRecent(w io.Writer) error {
+	ws := getWriterState(l.out)
+	ws.lock()
+	r := l.ring
+	ws.unlock()
+	if r == nil {
+		return nil
+	}
+	return r.Dump(w)
+}
+
+// DumpRecent writes the lines recorded in the standard logger's RingBuffer to w.
+func DumpRecent(w io.Writer) error {
+	return DefaultLogger.DumpRecent(w)
+}
+
+// dumpRing prints the hidden lines in the ring buffer, ahead of a line at
+// level. Lines already printed before an earlier error aren't printed again.
+func (l *Logger) dumpRing(ws *WriterState, level Level) {
+	entries := l.ring.takeUndumped()
+	buf := getLineBuffer()
+	defer putLineBuffer(buf)
+	for _, e := range entries {
+		if e.Level >= l.level {
+			continue
+		}
+		*buf = append((*buf)[:0], "  "...)
+		*buf = appendEntryRecord(*buf, &e)
+		l.writeFormattedLine(ws, *buf, level)
+	}
+}
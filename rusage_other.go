@@ -0,0 +1,10 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package alog
+
+import "time"
+
+func resourceUsage() (maxRSS int64, user, sys time.Duration, ok bool) {
+	return 0, 0, 0, false
+}
@@ -0,0 +1,25 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package alog
+
+import (
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// resourceUsage returns the process's peak resident set size in bytes, and the
+// CPU time it has used.
+func resourceUsage() (maxRSS int64, user, sys time.Duration, ok bool) {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0, 0, 0, false
+	}
+	maxRSS = int64(usage.Maxrss)
+	if runtime.GOOS != "darwin" {
+		// Everywhere else reports kilobytes
+		maxRSS *= 1024
+	}
+	return maxRSS, time.Duration(usage.Utime.Nano()), time.Duration(usage.Stime.Nano()), true
+}
@@ -0,0 +1,156 @@
+package alog
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// A Scheduler calls functions periodically, for everything in this package
+// that runs on a timer: LogEvery, live headers, the watchdog and WatchConfig.
+// The default starts a time.Ticker and a goroutine for each; a program can
+// supply its own to run them all from a timer loop it already has, and tests
+// can use a ManualScheduler to step time by hand.
+type Scheduler interface {
+	// Every calls f every interval until cancel is called. Once cancel
+	// returns, f isn't running and isn't called again, so cancel mustn't be
+	// called from f, or with a lock f takes held.
+	Every(interval time.Duration, f func()) (cancel func())
+}
+
+// SetScheduler sets the Scheduler the Logger's timers run on. A nil s goes
+// back to that of DefaultLogger, or on DefaultLogger itself, to the default.
+func (l *Logger) SetScheduler(s Scheduler) {
+	if s == nil && l.defaults() == l {
+		s = tickerScheduler{}
+	}
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	l.scheduler = s
+	configChanged()
+}
+
+func SetScheduler(s Scheduler) { DefaultLogger.SetScheduler(s) }
+
+// getScheduler returns the Logger's Scheduler. It takes the writer's lock if
+// the Logger's snapshot is out of date, so it mustn't be called with it held.
+func (l *Logger) getScheduler() Scheduler {
+	return l.snapshot().scheduler
+}
+
+// tickerScheduler is the default Scheduler.
+type tickerScheduler struct{}
+
+func (tickerScheduler) Every(interval time.Duration, f func()) (cancel func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		for {
+			select {
+			case <-ticker.C:
+				select {
+				case <-done:
+					return
+				default:
+					f()
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ticker.Stop()
+			close(done)
+		})
+		<-finished
+	}
+}
+
+// A ManualScheduler is a Scheduler whose time only moves when Advance is
+// called, for tests.
+type ManualScheduler struct {
+	mutex  sync.Mutex
+	now    time.Duration
+	nextID int
+	tasks  map[int]*manualTask
+}
+
+type manualTask struct {
+	id       int
+	interval time.Duration
+	next     time.Duration
+	f        func()
+	running  sync.Mutex // held while f is being called
+}
+
+func NewManualScheduler() *ManualScheduler {
+	return &ManualScheduler{tasks: map[int]*manualTask{}}
+}
+
+func (s *ManualScheduler) Every(interval time.Duration, f func()) (cancel func()) {
+	if interval <= 0 {
+		panic("non-positive interval for ManualScheduler.Every")
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.nextID++
+	id := s.nextID
+	task := &manualTask{id: id, interval: interval, next: s.now + interval, f: f}
+	s.tasks[id] = task
+	return func() {
+		s.mutex.Lock()
+		delete(s.tasks, id)
+		s.mutex.Unlock()
+		task.running.Lock()
+		task.running.Unlock()
+	}
+}
+
+// Advance moves the ManualScheduler's time on by d, calling each function
+// that comes due along the way, in order, as many times as it comes due.
+func (s *ManualScheduler) Advance(d time.Duration) {
+	s.mutex.Lock()
+	end := s.now + d
+	for {
+		task := s.nextDue(end)
+		if task == nil {
+			break
+		}
+		s.now = task.next
+		task.next += task.interval
+		task.running.Lock()
+		s.mutex.Unlock()
+		task.f()
+		task.running.Unlock()
+		s.mutex.Lock()
+	}
+	s.now = end
+	s.mutex.Unlock()
+}
+
+// nextDue returns the task due soonest, no later than end, if any. Must be
+// called with s.mutex held.
+func (s *ManualScheduler) nextDue(end time.Duration) *manualTask {
+	var due []*manualTask
+	for _, task := range s.tasks {
+		if task.next <= end {
+			due = append(due, task)
+		}
+	}
+	if len(due) == 0 {
+		return nil
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if due[i].next != due[j].next {
+			return due[i].next < due[j].next
+		}
+		return due[i].id < due[j].id
+	})
+	return due[0]
+}
@@ -0,0 +1,111 @@
+package alog
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// SetRightStatus sets text to show against the right edge of the terminal on
+// the Logger's partial line, e.g. the elapsed time or overall percentage of a
+// download. When space runs short, the partial line itself is shortened to
+// make room. The text stays until changed, and is shown whenever the Logger
+// has a partial line; an empty format removes it.
+//
+// The Logger's partial line only has the terminal to itself in multiline mode,
+// or while it's the only one; otherwise the text is shown after it.
+func (l *Logger) SetRightStatus(format string, v ...interface{}) {
+	l.setStatusSegment(&l.rightStatus, format, v)
+}
+
+// SetCenterStatus is like SetRightStatus, but centers the text in the terminal
+// (or as near as it'll go without covering the partial line).
+func (l *Logger) SetCenterStatus(format string, v ...interface{}) {
+	l.setStatusSegment(&l.centerStatus, format, v)
+}
+
+func SetRightStatus(format string, v ...interface{}) {
+	DefaultLogger.setStatusSegment(&DefaultLogger.rightStatus, format, v)
+}
+func SetCenterStatus(format string, v ...interface{}) {
+	DefaultLogger.setStatusSegment(&DefaultLogger.centerStatus, format, v)
+}
+
+func (l *Logger) setStatusSegment(segment *[]byte, format string, v []interface{}) {
+	var text []byte
+	if format != "" {
+		text = []byte(fmt.Sprintf(l.expandFormat(format, v), v...))
+		text = bytes.Replace(text, bytesNewline, []byte(" "), -1)
+	}
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	*segment = text
+	if l.tempLineActive {
+		updateTempOutput(l.out)
+		ws.writePending(l.out)
+	}
+}
+
+// appendStatusSegments lays out the Logger's centered and right-aligned status
+// text on line, which has the terminal width columns to itself if alone is
+// set. Must be called with the writer locked.
+func (l *Logger) appendStatusSegments(line []byte, width int, alone bool) []byte {
+	if len(l.centerStatus) == 0 && len(l.rightStatus) == 0 {
+		return line
+	}
+	center, right := l.centerStatus, l.rightStatus
+	if !l.isColorEnabled() {
+		center, right = Uncolorize(center), Uncolorize(right)
+	}
+	if !alone {
+		for _, segment := range [][]byte{center, right} {
+			if len(segment) != 0 {
+				line = append(line, ' ')
+				line = append(line, segment...)
+			}
+		}
+		return line
+	}
+
+	centerWidth := VisibleStringLen(center)
+	rightWidth := VisibleStringLen(right)
+	// Leave a space between each segment, shortening the line if it's too long
+	room := width - rightWidth
+	if rightWidth != 0 {
+		room--
+	}
+	if centerWidth != 0 {
+		room -= centerWidth + 1
+	}
+	if room < 0 {
+		room = 0
+	}
+	line = trimStringEllipsis(line, room)
+	line = append(line, getActiveAnsiCodes(line).getResetBytes()...)
+	column := VisibleStringLen(line)
+
+	pad := func(to int) {
+		for ; column < to; column++ {
+			line = append(line, ' ')
+		}
+	}
+	if centerWidth != 0 {
+		start := (width - centerWidth) / 2
+		if start <= column && column > 0 {
+			start = column + 1
+		}
+		pad(start)
+		line = append(line, center...)
+		line = append(line, getActiveAnsiCodes(center).getResetBytes()...)
+		column += centerWidth
+	}
+	if rightWidth != 0 {
+		start := width - rightWidth
+		if start <= column && column > 0 {
+			start = column + 1
+		}
+		pad(start)
+		line = append(line, right...)
+	}
+	return line
+}
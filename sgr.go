@@ -0,0 +1,85 @@
+package alog
+
+import "unicode/utf8"
+
+// This is a byte-level scanner for SGR ("Select Graphic Rendition") escape
+// sequences, i.e. ESC [ <params> m, where params is a semicolon-separated list
+// of decimal numbers. It replaces the regexps formerly used on every line and
+// every redraw of the partial lines.
+
+// scanSGR returns the length of the SGR sequence at the start of buf, or 0 if
+// buf doesn't start with a complete one.
+func scanSGR(buf []byte) int {
+	if len(buf) < 3 || buf[0] != '\033' || buf[1] != '[' {
+		return 0
+	}
+	for i := 2; i < len(buf); i++ {
+		c := buf[i]
+		if c == 'm' {
+			return i + 1
+		}
+		if (c < '0' || c > '9') && c != ';' {
+			return 0
+		}
+	}
+	return 0
+}
+
+// addSGRParams applies each parameter of the SGR sequence seq to codes. An
+// empty parameter means 0 (reset), as with ESC[m.
+func (codes *ActiveAnsiCodes) addSGRParams(seq []byte) {
+	code := 0
+	for _, c := range seq[2 : len(seq)-1] {
+		if c == ';' {
+			codes.add(code)
+			code = 0
+			continue
+		}
+		code = code*10 + int(c-'0')
+		if code > 1<<16 {
+			// Nonsense; don't let it overflow
+			code = 1 << 16
+		}
+	}
+	codes.add(code)
+}
+
+// nextSGR returns the index of the next SGR sequence in buf at or after start,
+// along with its length, or -1 and 0 if there isn't one.
+func nextSGR(buf []byte, start int) (int, int) {
+	for i := start; i < len(buf); i++ {
+		if buf[i] == '\033' {
+			if n := scanSGR(buf[i:]); n > 0 {
+				return i, n
+			}
+		}
+	}
+	return -1, 0
+}
+
+func uncolorizeSGR(buf []byte) []byte {
+	index, length := nextSGR(buf, 0)
+	if index == -1 {
+		return buf
+	}
+	tmp := make([]byte, 0, len(buf))
+	last := 0
+	for index != -1 {
+		tmp = append(tmp, buf[last:index]...)
+		last = index + length
+		index, length = nextSGR(buf, last)
+	}
+	return append(tmp, buf[last:]...)
+}
+
+func visibleLenSGR(buf []byte) int {
+	count := 0
+	last := 0
+	index, length := nextSGR(buf, 0)
+	for index != -1 {
+		count += utf8.RuneCount(buf[last:index])
+		last = index + length
+		index, length = nextSGR(buf, last)
+	}
+	return count + utf8.RuneCount(buf[last:])
+}
@@ -0,0 +1,148 @@
+package alog
+
+import (
+	"io"
+	"sync"
+)
+
+// maxBackgroundBuffer is how much output can be waiting on a slow writer
+// before Loggers writing to it have to wait as well.
+const maxBackgroundBuffer = 1 << 20
+
+// SetBackgroundWritesEnabled sets whether the Logger's writer is written to in
+// the background, so that a slow one (a terminal over a poor SSH link, say)
+// doesn't hold up everything that logs to it. While a write is under way,
+// partial line updates are put off and merged, so that only the latest is
+// drawn once it's done, and finished lines are kept to be written next. Only
+// if more than a megabyte is waiting do Loggers wait for the writer. Flush and
+// Close wait for everything to be written. Like SetTerminalWidth, this applies
+// to every Logger sharing the Logger's writer.
+//
+// This is the writer's side of what SetAsync does for a single Logger: SetAsync
+// moves formatting and writing off the calling goroutine, but an async Logger
+// still writes each update in full, in order, and makes its queue wait (or drop
+// messages) when the writer is slow. With background writes on as well, the
+// async Logger hands its output over here like any other, so that updates are
+// merged instead.
+func (l *Logger) SetBackgroundWritesEnabled(flag bool) {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	if flag && ws.background == nil {
+		ws.background = newBackgroundWriter()
+	} else if !flag && ws.background != nil {
+		ws.waitBackground(l.out)
+		ws.background = nil
+	}
+}
+func (l *Logger) EnableBackgroundWrites()  { l.SetBackgroundWritesEnabled(true) }
+func (l *Logger) DisableBackgroundWrites() { l.SetBackgroundWritesEnabled(false) }
+
+func SetBackgroundWritesEnabled(flag bool) { DefaultLogger.SetBackgroundWritesEnabled(flag) }
+func EnableBackgroundWrites()              { DefaultLogger.SetBackgroundWritesEnabled(true) }
+func DisableBackgroundWrites()             { DefaultLogger.SetBackgroundWritesEnabled(false) }
+
+// backgroundWriter writes output queued up by writePending from a goroutine
+// of its own. Its mutex is only ever taken after the writer's lock, or
+// without it.
+type backgroundWriter struct {
+	mutex   sync.Mutex
+	cond    *sync.Cond
+	buf     []byte // waiting to be written
+	writing bool   // whether the goroutine is running
+}
+
+func newBackgroundWriter() *backgroundWriter {
+	b := &backgroundWriter{}
+	b.cond = sync.NewCond(&b.mutex)
+	return b
+}
+
+// queue adds p to what's waiting to be written to out. Must be called with
+// the writer locked.
+func (b *backgroundWriter) queue(ws *WriterState, out io.Writer, p []byte) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for len(b.buf) > maxBackgroundBuffer {
+		b.cond.Wait()
+	}
+	b.buf = append(b.buf, p...)
+	if !b.writing {
+		b.writing = true
+		go b.run(ws, out)
+	}
+}
+
+func (b *backgroundWriter) run(ws *WriterState, out io.Writer) {
+	var spare []byte
+	b.mutex.Lock()
+	for len(b.buf) != 0 {
+		data := b.buf
+		b.buf = spare[:0]
+		b.mutex.Unlock()
+		out.Write(data)
+		b.mutex.Lock()
+		spare = data
+		b.cond.Broadcast()
+	}
+	b.writing = false
+	b.cond.Broadcast()
+	b.mutex.Unlock()
+
+	// Now draw whatever partial line updates were put off in the meantime,
+	// unless waitBackground got there first
+	ws.lock()
+	defer ws.unlock()
+	ws.drawDeferred(out)
+}
+
+// drawDeferred draws the partial line updates that were put off while the
+// writer was busy, if any. Must be called with the writer locked.
+func (w *WriterState) drawDeferred(out io.Writer) {
+	if w.tempDeferred {
+		w.tempDeferred = false
+		updateTempOutput(out)
+		w.writePending(out)
+	}
+}
+
+// busy reports whether a write is under way.
+func (b *backgroundWriter) busy() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.writing
+}
+
+// wait waits for everything queued to be written.
+func (b *backgroundWriter) wait() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for b.writing {
+		b.cond.Wait()
+	}
+}
+
+// deferTempOutput reports whether to put off updating partial lines, because
+// the writer is still busy with earlier output. Must be called with the
+// writer locked.
+func (w *WriterState) deferTempOutput() bool {
+	if w.background == nil || !w.background.busy() {
+		return false
+	}
+	w.tempDeferred = true
+	return true
+}
+
+// waitBackground waits for everything queued to be written, including any
+// partial line updates put off in the meantime, so that nothing more is drawn
+// once Flush or Close return. Must be called with the writer locked.
+func (w *WriterState) waitBackground(out io.Writer) {
+	if w.background == nil {
+		return
+	}
+	w.background.wait()
+	for w.tempDeferred {
+		w.drawDeferred(out)
+		w.background.wait()
+	}
+}
@@ -0,0 +1,91 @@
+package alog
+
+import (
+	"regexp"
+	"sync/atomic"
+	"time"
+)
+
+// A configSnapshot holds the settings needed to format and route a message, so
+// that Printf and friends can do that without taking the writer's lock. Snapshots
+// are never modified; a Logger makes a new one when it finds its last one out
+// of date.
+type configSnapshot struct {
+	generation uint64
+	level      Level
+	keepHidden bool // lines below level are still recorded in a RingBuffer
+	async      *asyncQueue
+	flag       int
+	callDepth  int              // the Logger's SetCallDepthOffset
+	nowFunc    func() time.Time // nil for time.Now
+	translator Translator
+	templates  *regexp.Regexp // nil if color templates are off
+	strict     bool
+	scheduler  Scheduler
+}
+
+// configGeneration counts changes to the settings kept in snapshots. Since a
+// Logger's settings may fall back to those of another Logger, any change
+// makes every snapshot out of date.
+var configGeneration uint64
+
+// configChanged marks every snapshot as out of date. Setters for the settings
+// in configSnapshot must call it after making their change.
+func configChanged() {
+	atomic.AddUint64(&configGeneration, 1)
+}
+
+// snapshot returns the Logger's current configSnapshot, only taking the
+// writer's lock if it has to make a new one.
+func (l *Logger) snapshot() *configSnapshot {
+	generation := atomic.LoadUint64(&configGeneration)
+	if c, ok := l.config.Load().(*configSnapshot); ok && c.generation == generation {
+		return c
+	}
+	ws := getWriterState(l.out)
+	ws.lock()
+	c := &configSnapshot{
+		generation: generation,
+		level:      l.level,
+		keepHidden: l.ring != nil,
+		async:      l.async,
+		flag:       l.flag,
+		callDepth:  l.callDepthOffset,
+		nowFunc:    l.nowFunc,
+		translator: l.translator,
+		templates:  l.getColorTemplateRegexp(),
+		strict:     isTrueDefaulted(l.strictTemplates, l.defaults().strictTemplates),
+	}
+	if c.translator == nil {
+		c.translator = l.defaults().translator
+	}
+	if c.nowFunc == nil {
+		c.nowFunc = l.defaults().nowFunc
+	}
+	if c.scheduler = l.scheduler; c.scheduler == nil {
+		c.scheduler = l.defaults().scheduler
+	}
+	// Stored under the lock, so that derive can copy the Logger safely
+	l.config.Store(c)
+	ws.unlock()
+	return c
+}
+
+// isHidden returns whether a line at level would be dropped without a trace,
+// so there's no need to format it.
+func (c *configSnapshot) isHidden(level Level) bool {
+	return level < c.level && !c.keepHidden
+}
+
+// colorify is Colorify, using the snapshot's settings.
+func (l *Logger) colorify(c *configSnapshot, s string) string {
+	if c.templates == nil {
+		return s
+	}
+	if c.strict {
+		if unknown := unknownTemplateCodes(c.templates, []byte(s)); unknown != nil {
+			l.warnUnknownCodes(unknown, s)
+		}
+	}
+	return string(processColorTemplates(c.templates, []byte(s)))
+}
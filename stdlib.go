@@ -0,0 +1,64 @@
+package alog
+
+import (
+	"log"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+// HijackStdlib routes the standard library's global logger (log.Printf and
+// friends, as used by many third-party packages) through l, so that its lines
+// are printed like l's own and don't break into partial lines. The date, time
+// and caller that the global logger would add are taken off its lines, with
+// l's own header used instead. The lines are printed separately from l's own
+// partial line, so they never run into it. Call the returned func to put things back the
+// way they were.
+func (l *Logger) HijackStdlib() (restore func()) {
+	out, flags, prefix := log.Writer(), log.Flags(), log.Prefix()
+	stdFlags := 0
+	if l.Flags()&(Lshortfile|Llongfile) != 0 {
+		// Have the global logger find the caller, which is hard to do from here
+		stdFlags = log.Llongfile
+	}
+	log.SetFlags(stdFlags)
+	log.SetPrefix("")
+	std := l.derive("")
+	log.SetOutput(stdlibWriter{std})
+	return func() {
+		log.SetOutput(out)
+		std.Destroy()
+		log.SetFlags(flags)
+		log.SetPrefix(prefix)
+	}
+}
+
+func HijackStdlib() (restore func()) { return DefaultLogger.HijackStdlib() }
+
+// stdlibHeaderRegexp matches the header the standard library's log package can
+// put at the start of a line: date, time, and file and line.
+var stdlibHeaderRegexp = regexp.MustCompile(`^(\d{4}/\d{2}/\d{2} )?(\d{2}:\d{2}:\d{2}(\.\d+)? )?(([^ ]+):(\d+): )?`)
+
+type stdlibWriter struct {
+	l *Logger
+}
+
+// Write is called by the log package with one line at a time.
+func (w stdlibWriter) Write(p []byte) (int, error) {
+	l, n := w.l, len(p)
+	if l.isDiscarded() {
+		return n, nil
+	}
+	e := l.newEntry(0)
+	if m := stdlibHeaderRegexp.FindSubmatchIndex(p); m != nil {
+		if m[10] != -1 {
+			e.callerFile = string(p[m[10]:m[11]])
+			e.callerLine, _ = strconv.Atoi(string(p[m[12]:m[13]]))
+			if l.Flags()&Lshortfile != 0 {
+				e.callerFile = filepath.Base(e.callerFile)
+			}
+		}
+		p = p[m[1]:]
+	}
+	return n, l.emit(e, p, false)
+}
@@ -0,0 +1,160 @@
+package alog
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+)
+
+// A Step is one unit of work in a run of them, e.g. one stage of a build. It's
+// shown as a partial line of its own while it runs, and then finished with its
+// outcome and how long it took.
+type Step struct {
+	parent *Logger
+	t      *task
+	name   string
+	mutex  sync.Mutex
+	output *Logger      // see Logger
+	buf    bytes.Buffer // what's been written to output
+}
+
+// A StepResult is the outcome of a finished Step.
+type StepResult struct {
+	Name     string
+	Start    time.Time
+	Duration time.Duration
+	Err      error  // why the step failed, if it did
+	Canceled bool   // whether that was because its context was done
+	Skipped  string // why the step was skipped, if it was
+	Output   string // the lines written to the step's Logger, without colors
+}
+
+// Failed returns whether the step failed.
+func (r *StepResult) Failed() bool { return r.Err != nil }
+
+// Step starts the step name, e.g.:
+//
+//	step := log.StartStep("compile")
+//	err := compile()
+//	step.End(err)
+//
+// prints "compile... " and then "compile... ok 1.25s", or
+// "compile... FAILED 1.25s: <err>". The duration is colored, and slow steps
+// highlighted, as for a Timer. Steps may run concurrently.
+func (l *Logger) Step(name string) *Step {
+	return l.step(3, name)
+}
+
+// StepContext is Step for a step that should end with ctx: if ctx is done
+// first, the step is finished as canceled (in yellow, with the time it ran),
+// so that an interrupted run doesn't leave it hanging. Ending it afterward
+// does nothing.
+func (l *Logger) StepContext(ctx context.Context, name string) *Step {
+	s := l.step(3, name)
+	s.t.watch(ctx, func() { s.finish(StepResult{Err: ctx.Err(), Canceled: true}) })
+	return s
+}
+
+func (l *Logger) step(calldepth int, name string) *Step {
+	start := l.now()
+	l.writeStepEvent(name, nil, start)
+	tap := l.getTAP()
+	return &Step{parent: l, name: name, t: l.startTask(calldepth, name, start, tap == nil || !tap.exclusive, true)}
+}
+
+// StartStep is Step for the standard logger. (Step is taken by the type.)
+func StartStep(name string) *Step { return DefaultLogger.step(3, name) }
+
+func StartStepContext(ctx context.Context, name string) *Step {
+	return DefaultLogger.StepContext(ctx, name)
+}
+
+// Logger returns a Logger for the step's own output, whose lines are indented
+// under it and kept with its StepResult.
+func (s *Step) Logger() *Logger {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.output == nil {
+		s.output = s.parent.derive("  ")
+		s.output.AddOutput(&s.buf, OutputOptions{StripColor: true, MinLevel: LevelDebug})
+	}
+	return s.output
+}
+
+// Done finishes the step successfully.
+func (s *Step) Done() { s.finish(StepResult{}) }
+
+// Fail finishes the step as failed because of err.
+func (s *Step) Fail(err error) { s.finish(StepResult{Err: err}) }
+
+// End finishes the step, as failed if err is not nil, and returns err.
+func (s *Step) End(err error) error {
+	s.finish(StepResult{Err: err})
+	return err
+}
+
+// Skip finishes the step as skipped, for reason.
+func (s *Step) Skip(reason string) { s.finish(StepResult{Skipped: reason}) }
+
+// finish records the step's outcome and shows it. Only the first call counts.
+func (s *Step) finish(result StepResult) {
+	if !s.t.end() {
+		return
+	}
+	s.mutex.Lock()
+	if s.output != nil {
+		s.output.Destroy()
+		result.Output = s.buf.String()
+	}
+	s.mutex.Unlock()
+
+	now := s.parent.now()
+	result.Name = s.name
+	result.Start = s.t.start
+	result.Duration = s.parent.since(s.t.start, now)
+	s.parent.reportStep(&result)
+	defer s.parent.writeStepEvent(s.name, &result, now)
+	switch {
+	case result.Canceled:
+		s.t.canceled(3, result.Duration)
+	case result.Err != nil:
+		s.t.finish(3, LevelError, s.t.label+colorize("FAILED", ColorRed)+" "+FormatDuration(result.Duration)+": "+result.Err.Error())
+	case result.Skipped != "":
+		s.t.finish(3, LevelInfo, s.t.label+colorize("skipped", ColorYellow)+": "+result.Skipped)
+	default:
+		s.t.done(3, colorize("ok", ColorGreen)+" ", result.Duration)
+	}
+}
+
+// A stepReporter collects the outcomes of Steps, as SetTAPOutput and
+// SetJUnitReport do.
+type stepReporter interface {
+	report(result *StepResult)
+	// finish writes whatever comes once the steps are done.
+	finish() error
+}
+
+// stepReporters returns whatever is collecting the Logger's step results; if
+// owned is set, only what was set up on the Logger itself, rather than on the
+// one it was derived from.
+func (l *Logger) stepReporters(owned bool) []stepReporter {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	var reporters []stepReporter
+	if l.tap != nil && (!owned || l.tap.owner == l) {
+		reporters = append(reporters, l.tap)
+	}
+	if l.junit != nil && (!owned || l.junit.owner == l) {
+		reporters = append(reporters, l.junit)
+	}
+	return reporters
+}
+
+// reportStep passes result on to whatever is collecting step results.
+func (l *Logger) reportStep(result *StepResult) {
+	for _, r := range l.stepReporters(false) {
+		r.report(result)
+	}
+}
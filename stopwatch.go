@@ -0,0 +1,89 @@
+package alog
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// A Stopwatch times the phases of a multi-phase operation, and prints a
+// breakdown of them when stopped.
+type Stopwatch struct {
+	l     *Logger
+	name  string
+	mutex sync.Mutex
+	start time.Time
+	last  time.Time
+	laps  []stopwatchLap
+}
+
+type stopwatchLap struct {
+	name     string
+	duration time.Duration
+}
+
+// Stopwatch starts timing the operation name. Call Lap at the end of each
+// phase, and Stop at the end of the whole thing, e.g.:
+//
+//	sw := log.Stopwatch("migration")
+//	migrateSchema()
+//	sw.Lap("schema")
+//	migrateData()
+//	sw.Lap("data")
+//	sw.Stop()
+func (l *Logger) Stopwatch(name string) *Stopwatch {
+	now := l.now()
+	return &Stopwatch{l: l, name: name, start: now, last: now}
+}
+
+// StartStopwatch is Stopwatch for the standard logger.
+func StartStopwatch(name string) *Stopwatch { return DefaultLogger.Stopwatch(name) }
+
+// Lap ends the current phase, naming it, and returns how long it took.
+func (sw *Stopwatch) Lap(name string) time.Duration {
+	now := sw.l.now()
+	sw.mutex.Lock()
+	defer sw.mutex.Unlock()
+	duration := sw.l.since(sw.last, now)
+	sw.laps = append(sw.laps, stopwatchLap{name: name, duration: duration})
+	sw.last = now
+	return duration
+}
+
+// Stop prints the total time taken along with the time taken by each phase,
+// and returns the total. Time since the last Lap is counted as a phase of its
+// own.
+func (sw *Stopwatch) Stop() time.Duration {
+	now := sw.l.now()
+	sw.mutex.Lock()
+	laps := sw.laps
+	if rest := sw.l.since(sw.last, now); len(laps) > 0 && rest > 0 {
+		laps = append(laps, stopwatchLap{name: "(rest)", duration: rest})
+	}
+	total := sw.l.since(sw.start, now)
+	sw.mutex.Unlock()
+	if sw.l.isDiscarded() {
+		return total
+	}
+
+	nameWidth := 0
+	for _, lap := range laps {
+		if n := utf8.RuneCountInString(lap.name); n > nameWidth {
+			nameWidth = n
+		}
+	}
+	var out strings.Builder
+	fmt.Fprintf(&out, "%s took %s\n", sw.l.Colorify(sw.name), FormatDuration(total))
+	for _, lap := range laps {
+		percent := 0.0
+		if total > 0 {
+			percent = 100 * float64(lap.duration) / float64(total)
+		}
+		padding := strings.Repeat(" ", nameWidth-utf8.RuneCountInString(lap.name))
+		fmt.Fprintf(&out, "  %s%s  %5s  %3.0f%%\n", lap.name, padding, FormatDuration(lap.duration), percent)
+	}
+	sw.l.emit(sw.l.newEntry(2), []byte(out.String()), false)
+	return total
+}
@@ -0,0 +1,52 @@
+package alog
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+)
+
+// SetStrictTemplatesEnabled determines whether color templates naming codes
+// that don't exist, like "@(gren:ok)", get a warning. Without it, they're
+// silently printed as they are. Each unknown code is warned about once.
+func (l *Logger) SetStrictTemplatesEnabled(flag bool) {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	l.strictTemplates = boolPointer(flag)
+	configChanged()
+}
+func (l *Logger) EnableStrictTemplates()  { l.SetStrictTemplatesEnabled(true) }
+func (l *Logger) DisableStrictTemplates() { l.SetStrictTemplatesEnabled(false) }
+
+func EnableStrictTemplates()  { DefaultLogger.EnableStrictTemplates() }
+func DisableStrictTemplates() { DefaultLogger.DisableStrictTemplates() }
+
+// unknownTemplateCodes returns the codes in buf's color templates that aren't
+// in the table of known codes.
+func unknownTemplateCodes(colorTemplateRegexp *regexp.Regexp, buf []byte) []string {
+	var unknown []string
+	ansiColorCodesMutex.RLock()
+	defer ansiColorCodesMutex.RUnlock()
+	for _, groups := range colorTemplateRegexp.FindAllSubmatch(buf, -1) {
+		for _, codeBytes := range bytes.Split(groups[1], bytesComma) {
+			if _, ok := ansiColorCodes[string(codeBytes)]; !ok {
+				unknown = append(unknown, string(codeBytes))
+			}
+		}
+	}
+	return unknown
+}
+
+// warnUnknownCodes warns about each of the unknown codes found in s, the first
+// time it's found. Must be called without the writer's lock held.
+func (l *Logger) warnUnknownCodes(unknown []string, s string) {
+	for _, code := range unknown {
+		if !l.firstTime("template code:" + code) {
+			continue
+		}
+		e := l.newEntry(3)
+		e.level = LevelWarn
+		l.emit(e, []byte(fmt.Sprintf("unknown color template code %q in %q\n", code, s)), false)
+	}
+}
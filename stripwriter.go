@@ -0,0 +1,109 @@
+package alog
+
+import (
+	"bytes"
+	"io"
+)
+
+// maxEscapeLength bounds how much of an unfinished escape sequence the
+// stripWriter holds on to between writes. Anything longer isn't one we'd
+// produce; it's dropped.
+const maxEscapeLength = 256
+
+type stripWriter struct {
+	w       io.Writer
+	partial []byte // an escape sequence cut off at the end of the last Write
+	buf     []byte
+}
+
+// NewStripWriter returns a Writer that removes all ANSI escape sequences
+// (colors, cursor movement, and the like) from what's written to it before
+// passing it on to w. Sequences may be split across calls to Write.
+func NewStripWriter(w io.Writer) io.Writer {
+	return &stripWriter{w: w}
+}
+
+func (s *stripWriter) Write(p []byte) (int, error) {
+	data := p
+	if len(s.partial) != 0 {
+		data = append(s.partial, p...)
+	}
+	s.buf = s.buf[:0]
+	i := 0
+	for i < len(data) {
+		index := bytes.IndexByte(data[i:], '\033')
+		if index == -1 {
+			s.buf = append(s.buf, data[i:]...)
+			i = len(data)
+			break
+		}
+		s.buf = append(s.buf, data[i:i+index]...)
+		i += index
+		n := escapeLength(data[i:])
+		if n == 0 {
+			break
+		}
+		i += n
+	}
+	s.partial = append(s.partial[:0], data[i:]...)
+	if len(s.partial) > maxEscapeLength {
+		s.partial = s.partial[:0]
+	}
+	if len(s.buf) != 0 {
+		if _, err := s.w.Write(s.buf); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// escapeLength returns the length of the escape sequence at the start of buf,
+// or 0 if buf ends before the sequence does. An ESC that doesn't start a
+// sequence counts as a sequence of its own.
+func escapeLength(buf []byte) int {
+	if len(buf) < 2 {
+		return 0
+	}
+	switch buf[1] {
+	case '[':
+		// CSI: parameter bytes, then intermediate bytes, then a final byte
+		for i := 2; i < len(buf); i++ {
+			c := buf[i]
+			if c >= 0x40 && c <= 0x7e {
+				return i + 1
+			}
+			if c < 0x20 || c > 0x3f {
+				return i
+			}
+		}
+		return 0
+	case ']':
+		// OSC: terminated by BEL or ST (ESC \)
+		for i := 2; i < len(buf); i++ {
+			if buf[i] == '\a' {
+				return i + 1
+			}
+			if buf[i] == '\033' {
+				if i+1 == len(buf) {
+					return 0
+				}
+				if buf[i+1] == '\\' {
+					return i + 2
+				}
+				return i
+			}
+		}
+		return 0
+	}
+	// Other escapes: intermediate bytes, then a final byte
+	for i := 1; i < len(buf); i++ {
+		c := buf[i]
+		if c >= 0x30 && c <= 0x7e {
+			return i + 1
+		}
+		if c < 0x20 || c > 0x2f {
+			return i
+		}
+	}
+	return 0
+}
@@ -0,0 +1,47 @@
+package alog
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// PrintStyled prints its arguments, as Print does, in the style given by a
+// comma-separated list of color names, e.g.:
+//
+//	log.PrintStyled("red,bright", err, "\n")
+//
+// Unlike with a color template, the text is never interpreted, and escape
+// sequences and other control characters in it, other than newlines and tabs,
+// are dropped, so this is safe for messages from errors or user data.
+func (l *Logger) PrintStyled(style string, v ...interface{}) {
+	l.emitLazy(2, func() string { return styleLines(fmt.Sprint(v...), style) })
+}
+
+func PrintStyled(style string, v ...interface{}) {
+	DefaultLogger.emitLazy(2, func() string { return styleLines(fmt.Sprint(v...), style) })
+}
+
+// styleLines applies style to each line of s separately, so that it carries
+// across line breaks, having dropped any control characters from s.
+func styleLines(s string, style string) string {
+	colors := parseStyle(style)
+	lines := strings.Split(stripControls(s), "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = colorize(line, colors...)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// stripControls drops the control characters from s, ESC and carriage returns
+// included, other than newlines and tabs.
+func stripControls(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r != '\n' && r != '\t' && unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,83 @@
+package alog
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// levelCounts counts the messages logged at each level, indexed from
+// LevelDebug.
+var levelCounts [LevelError - LevelDebug + 1]int64
+
+func countLevel(level Level) {
+	if level >= LevelDebug && level <= LevelError {
+		atomic.AddInt64(&levelCounts[level-LevelDebug], 1)
+	}
+}
+
+// Count returns how many messages have been logged at level, by all Loggers,
+// since the program started or ResetCounts was last called. Messages dropped for
+// being below a Logger's level aren't counted. main can use this to choose its
+// exit code, e.g.:
+//
+//	if alog.Count(alog.LevelError) > 0 {
+//		os.Exit(1)
+//	}
+func Count(level Level) int {
+	if level < LevelDebug || level > LevelError {
+		return 0
+	}
+	return int(atomic.LoadInt64(&levelCounts[level-LevelDebug]))
+}
+
+// ResetCounts sets all the counts returned by Count and CommandCounts back to
+// zero.
+func ResetCounts() {
+	for i := range levelCounts {
+		atomic.StoreInt64(&levelCounts[i], 0)
+	}
+	atomic.StoreInt64(&commandCounts.run, 0)
+	atomic.StoreInt64(&commandCounts.failed, 0)
+}
+
+// Summary prints how many warnings and errors have been logged, along with how
+// many commands run with Run failed, e.g. "completed with 3 warnings, 1
+// error", colored by the worst of them.
+func (l *Logger) Summary() {
+	if l.isDiscarded() {
+		return
+	}
+	warnings, errors := Count(LevelWarn), Count(LevelError)
+	_, failed := CommandCounts()
+	var counts []string
+	if warnings > 0 {
+		counts = append(counts, plural(warnings, "warning"))
+	}
+	if errors > 0 {
+		counts = append(counts, plural(errors, "error"))
+	}
+	if failed > 0 {
+		counts = append(counts, plural(failed, "failed command"))
+	}
+	var s string
+	switch {
+	case errors > 0 || failed > 0:
+		s = colorize("completed with "+strings.Join(counts, ", "), ColorRed)
+	case warnings > 0:
+		s = colorize("completed with "+strings.Join(counts, ", "), ColorYellow)
+	default:
+		s = colorize("completed with no warnings or errors", ColorGreen)
+	}
+	l.emit(l.newEntry(2), []byte(s+"\n"), false)
+}
+
+func Summary() { DefaultLogger.Summary() }
+
+// plural returns n and noun, adding an "s" to noun unless n is 1.
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
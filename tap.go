@@ -0,0 +1,95 @@
+package alog
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// SetTAPOutput makes the Logger also report the outcome of each Step, in the
+// Test Anything Protocol, to w, e.g. "ok 1 - build" or "not ok 2 - test", so
+// that CI systems and test harnesses can read the results. The plan ("1..N")
+// is written when FinishTAPOutput is called or the Logger is closed. If
+// exclusive is set, steps are reported only in TAP, and not shown by the
+// Logger itself. A nil w stops TAP output.
+func (l *Logger) SetTAPOutput(w io.Writer, exclusive bool) {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	if w == nil {
+		l.tap = nil
+		return
+	}
+	l.tap = &tapWriter{owner: l, w: w, exclusive: exclusive}
+	io.WriteString(w, "TAP version 13\n")
+}
+
+func SetTAPOutput(w io.Writer, exclusive bool) { DefaultLogger.SetTAPOutput(w, exclusive) }
+
+// FinishTAPOutput writes the plan for the steps reported by SetTAPOutput, after
+// which no more are reported. Programs using the standard logger, which
+// shouldn't be closed, call this once their steps are done.
+func (l *Logger) FinishTAPOutput() {
+	if t := l.getTAP(); t != nil {
+		t.finish()
+	}
+}
+
+func FinishTAPOutput() { DefaultLogger.FinishTAPOutput() }
+
+type tapWriter struct {
+	owner     *Logger // the Logger that writes the plan when closed
+	w         io.Writer
+	exclusive bool
+	mutex     sync.Mutex
+	count     int
+	closed    bool
+}
+
+func (l *Logger) getTAP() *tapWriter {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	return l.tap
+}
+
+func (t *tapWriter) report(result *StepResult) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.closed {
+		return
+	}
+	t.count++
+	var line string
+	switch {
+	case result.Err != nil:
+		line = fmt.Sprintf("not ok %d - %s\n", t.count, tapEscape(result.Name))
+		line += "  ---\n"
+		line += fmt.Sprintf("  message: %q\n", result.Err.Error())
+		line += fmt.Sprintf("  duration_ms: %.3f\n", result.Duration.Seconds()*1000)
+		line += "  ...\n"
+	case result.Skipped != "":
+		line = fmt.Sprintf("ok %d - %s # SKIP %s\n", t.count, tapEscape(result.Name), tapEscape(result.Skipped))
+	default:
+		line = fmt.Sprintf("ok %d - %s\n", t.count, tapEscape(result.Name))
+	}
+	io.WriteString(t.w, line)
+}
+
+// finish writes the plan, once.
+func (t *tapWriter) finish() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+	_, err := fmt.Fprintf(t.w, "1..%d\n", t.count)
+	return err
+}
+
+// tapEscape escapes the characters that mean something in a TAP description.
+func tapEscape(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "#", "\\#", "\n", " ").Replace(s)
+}
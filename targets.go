@@ -0,0 +1,253 @@
+package alog
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A TargetState is where a target in a Targets grid has got to.
+type TargetState int
+
+const (
+	TargetPending TargetState = iota
+	TargetRunning
+	TargetDone
+	TargetFailed
+)
+
+// Targets shows the state of an operation on each of many targets (hosts,
+// clusters and so on) as a compact grid of partial lines of its own, e.g.
+// "host1 ✓  host2 ⠧  host3 ✗", wrapped into as many rows as the terminal's
+// width calls for. In multiline mode, each row is a line of its own. Detail
+// lines for each target are held back until it finishes, and then printed
+// together, so that output from targets running side by side doesn't get
+// interleaved.
+type Targets struct {
+	l       *Logger   // which the grid's rows are derived from
+	rows    []*Logger // each shows a row of the grid
+	dl      *Logger   // prints finished targets' details
+	g       *glyphs
+	mutex   sync.Mutex
+	names   []string
+	targets map[string]*target
+	frame   int // of the spinner
+	closed  bool
+	cancel  func()
+}
+
+type target struct {
+	state   TargetState
+	details []string
+}
+
+// targetsSpinnerInterval is how often the spinners of running targets turn.
+const targetsSpinnerInterval = 100 * time.Millisecond
+
+// NewTargets starts a Targets grid, with the named targets pending. Close it to
+// finish the grid's line, e.g.:
+//
+//	t := log.NewTargets(hosts...)
+//	defer t.Close()
+//	for _, host := range hosts {
+//		go func(host string) {
+//			t.Start(host)
+//			t.Printf(host, "uploading %s", artifact)
+//			if err := deploy(host); err != nil {
+//				t.Fail(host, err)
+//			} else {
+//				t.Done(host)
+//			}
+//		}(host)
+//	}
+func (l *Logger) NewTargets(names ...string) *Targets {
+	t := &Targets{
+		l:       l,
+		dl:      l.derive(""),
+		g:       l.glyphs(),
+		targets: map[string]*target{},
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for _, name := range names {
+		t.get(name)
+	}
+	t.show(2, "")
+	t.cancel = l.getScheduler().Every(targetsSpinnerInterval, t.spin)
+	return t
+}
+
+func NewTargets(names ...string) *Targets { return DefaultLogger.NewTargets(names...) }
+
+// Start marks the target name as running.
+func (t *Targets) Start(name string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.closed {
+		return
+	}
+	t.get(name).state = TargetRunning
+	t.show(2, "")
+}
+
+// Printf adds a detail line for the target name, which is printed once the
+// target is done or has failed.
+func (t *Targets) Printf(name string, format string, v ...interface{}) {
+	line := fmt.Sprintf(t.l.expandFormat(format, v), v...)
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	tg := t.get(name)
+	tg.details = append(tg.details, strings.TrimSuffix(line, "\n"))
+}
+
+// Done marks the target name as done, and prints its detail lines, if any,
+// under a green heading.
+func (t *Targets) Done(name string) { t.finish(name, TargetDone, nil) }
+
+// Fail marks the target name as failed, and prints err, in red, along with its
+// detail lines, as an error.
+func (t *Targets) Fail(name string, err error) { t.finish(name, TargetFailed, err) }
+
+// State returns the state of the target name.
+func (t *Targets) State(name string) TargetState {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if tg, ok := t.targets[name]; ok {
+		return tg.state
+	}
+	return TargetPending
+}
+
+// Close stops the spinners and prints the grid as it stands as a line of its
+// own. Detail lines of targets that haven't finished are dropped.
+func (t *Targets) Close() {
+	t.mutex.Lock()
+	if t.closed {
+		t.mutex.Unlock()
+		return
+	}
+	t.closed = true
+	t.show(2, "\n")
+	t.mutex.Unlock()
+	t.cancel()
+	for _, row := range t.rows {
+		row.Destroy()
+	}
+	t.dl.Destroy()
+}
+
+// get returns the target name, adding it to the end of the grid if it's new.
+// Must be called with t.mutex held.
+func (t *Targets) get(name string) *target {
+	tg, ok := t.targets[name]
+	if !ok {
+		tg = &target{}
+		t.targets[name] = tg
+		t.names = append(t.names, name)
+	}
+	return tg
+}
+
+func (t *Targets) finish(name string, state TargetState, err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.closed {
+		return
+	}
+	tg := t.get(name)
+	tg.state = state
+	details := tg.details
+	tg.details = nil
+	if !t.dl.isDiscarded() && (state == TargetFailed || len(details) > 0) {
+		e := t.dl.newEntry(3)
+		var out strings.Builder
+		if state == TargetFailed {
+			e.level = LevelError
+			fmt.Fprintf(&out, "%s\n", colorize(fmt.Sprintf("%s %s %v", name, t.g.failed, err), ColorRed))
+		} else {
+			fmt.Fprintf(&out, "%s\n", colorize(name+" "+t.g.ok, ColorGreen))
+		}
+		for _, line := range details {
+			fmt.Fprintf(&out, "  %s\n", line)
+		}
+		t.dl.emit(e, []byte(out.String()), false)
+	}
+	t.show(3, "")
+}
+
+// spin turns the spinners of running targets.
+func (t *Targets) spin() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.closed {
+		return
+	}
+	t.frame++
+	for _, tg := range t.targets {
+		if tg.state == TargetRunning {
+			t.show(1, "")
+			return
+		}
+	}
+}
+
+// show draws the grid, with each row followed by end, for the caller
+// calldepth frames up. Must be called with t.mutex held.
+func (t *Targets) show(calldepth int, end string) {
+	if t.l.isDiscarded() {
+		return
+	}
+	rows := t.layout(getTermWidth(t.l.out) - 1)
+	for len(t.rows) < len(rows) {
+		t.rows = append(t.rows, t.l.derive(""))
+	}
+	for i, row := range rows {
+		rl := t.rows[i]
+		rl.emit(rl.newEntry(calldepth+1), []byte(row+end), true)
+	}
+	// Rows left over from a narrower terminal
+	for _, rl := range t.rows[len(rows):] {
+		ws := getWriterState(rl.out)
+		ws.lock()
+		rl.clearPartialLine(ws)
+		ws.unlock()
+		rl.Destroy()
+	}
+	t.rows = t.rows[:len(rows)]
+}
+
+// layout returns the grid, wrapped into rows no wider than width. Must be
+// called with t.mutex held.
+func (t *Targets) layout(width int) []string {
+	var rows []string
+	var row strings.Builder
+	rowWidth := 0
+	for _, name := range t.names {
+		var glyph string
+		switch t.targets[name].state {
+		case TargetPending:
+			glyph = colorize(t.g.pending, ColorDim)
+		case TargetRunning:
+			glyph = colorize(t.g.spinner[t.frame%len(t.g.spinner)], ColorCyan)
+		case TargetDone:
+			glyph = colorize(t.g.ok, ColorGreen)
+		case TargetFailed:
+			glyph = colorize(t.g.failed, ColorRed)
+		}
+		cell := name + " " + glyph
+		cellWidth := VisibleStringLen([]byte(cell))
+		if rowWidth > 0 && rowWidth+2+cellWidth > width {
+			rows = append(rows, row.String())
+			row.Reset()
+			rowWidth = 0
+		}
+		if rowWidth > 0 {
+			row.WriteString("  ")
+			rowWidth += 2
+		}
+		row.WriteString(cell)
+		rowWidth += cellWidth
+	}
+	return append(rows, row.String())
+}
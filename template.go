@@ -0,0 +1,47 @@
+package alog
+
+import "fmt"
+
+// A Template is a format string with its color templates already expanded,
+// for formats that get printed over and over. Printf on a Logger expands
+// @(...) templates on every call; a Template does it once, up front.
+type Template struct {
+	l      *Logger
+	format string
+}
+
+// CompileTemplate expands the color templates in format according to the
+// Logger's current settings, e.g.:
+//
+//	ok := log.CompileTemplate("@(green:OK) %s\n")
+//	ok.Printf("uploaded %d files", n)
+func (l *Logger) CompileTemplate(format string) *Template {
+	return &Template{l: l, format: l.expandFormat(format, nil)}
+}
+
+// CompileTemplate compiles format for the standard logger.
+func CompileTemplate(format string) *Template {
+	return DefaultLogger.CompileTemplate(format)
+}
+
+// String returns the expanded format.
+func (t *Template) String() string {
+	return t.format
+}
+
+// Sprintf formats v according to the expanded format.
+func (t *Template) Sprintf(v ...interface{}) string {
+	return fmt.Sprintf(t.format, v...)
+}
+
+// Printf prints v, formatted according to the expanded format, to the Logger
+// the Template was compiled for.
+func (t *Template) Printf(v ...interface{}) {
+	if t.l.isDiscarded() {
+		return
+	}
+	msg := getMessageBuffer()
+	fmt.Fprintf(msg, t.format, v...)
+	t.l.emit(t.l.newEntry(2), msg.Bytes(), false)
+	putMessageBuffer(msg)
+}
@@ -0,0 +1,81 @@
+//go:build linux
+// +build linux
+
+package alog
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// openPty returns the master side of a new pseudo-terminal and the path of its
+// slave side.
+func openPty(t *testing.T) (*os.File, string) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		t.Skip("no pseudo-terminals available:", err)
+	}
+	var unlock int32
+	var num uint32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, master.Fd(), syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); errno != 0 {
+		master.Close()
+		t.Skip("can't unlock pseudo-terminal:", errno)
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, master.Fd(), syscall.TIOCGPTN, uintptr(unsafe.Pointer(&num))); errno != 0 {
+		master.Close()
+		t.Skip("can't get pseudo-terminal number:", errno)
+	}
+	return master, "/dev/pts/" + strconv.Itoa(int(num))
+}
+
+func TestSameTerminal(t *testing.T) {
+	assert := assert.New(t)
+	master, path := openPty(t)
+	defer master.Close()
+	out1, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Skip("can't open pseudo-terminal:", err)
+	}
+	defer out1.Close()
+	out2, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Skip("can't open pseudo-terminal:", err)
+	}
+	defer out2.Close()
+
+	assert.True(isSameTerminal(out1, out2))
+	assert.False(isSameTerminal(out1, &bytes.Buffer{}))
+
+	writer1 := New(out1, "", 0)
+	writer2 := New(out2, "", 0)
+	assert.True(getWriterState(out1) == getWriterState(out2), "both writers share one state")
+	writer1.SetTerminalWidth(40)
+	writer1.Printf("one")
+	writer2.Printf("two")
+	writer1.Destroy()
+	writer2.Destroy()
+	out1.Close()
+	out2.Close()
+
+	var screen bytes.Buffer
+	buf := make([]byte, 1024)
+	for !bytes.HasSuffix(screen.Bytes(), []byte("two\r\n")) {
+		n, err := master.Read(buf)
+		if err != nil && err != io.EOF {
+			break
+		}
+		screen.Write(buf[:n])
+	}
+	assert.Equal("one | two\rone      \r\ntwo\r\n", screen.String(), "the second partial line joins the first rather than overwriting it")
+	mutexGlobal.RLock()
+	_, ok := writers[out2]
+	mutexGlobal.RUnlock()
+	assert.False(ok, "the shared state is dropped along with the last Logger")
+}
@@ -0,0 +1,18 @@
+package alog
+
+// A TerminalInfoProvider is an io.Writer that can say what kind of terminal,
+// if any, it writes to. Writers that aren't *os.File can implement it, e.g. to
+// forward to a terminal over the network or to stand in for one in tests.
+// Otherwise, a writer that isn't an *os.File is taken to not be a terminal,
+// but to be as wide as the terminal on stderr, if any.
+type TerminalInfoProvider interface {
+	// Width returns the terminal's width in columns, or 0 to have it looked up
+	// from the terminal at Fd.
+	Width() int
+	// IsTTY returns whether the writer is a terminal, so that e.g. output is
+	// only buffered when it isn't.
+	IsTTY() bool
+	// Fd returns the file descriptor of the terminal, which identifies it so
+	// that other writers to the same terminal share partial lines with it.
+	Fd() uintptr
+}
@@ -20,5 +20,25 @@ func getTermWidth(writer io.Writer) int {
 	if ws.termWidth != 0 {
 		return ws.termWidth
 	}
+	if provider, ok := writer.(TerminalInfoProvider); ok {
+		if width := provider.Width(); width > 0 {
+			return width
+		}
+	}
 	return 200
 }
+
+// isTerminal reports whether writer is a terminal. We don't know how to tell on
+// this platform, so assume that only the standard streams are.
+func isTerminal(writer io.Writer) bool {
+	if provider, ok := writer.(TerminalInfoProvider); ok {
+		return provider.IsTTY()
+	}
+	return writer == os.Stdout || writer == os.Stderr
+}
+
+// terminalID identifies the terminal device writer is attached to, if any. As
+// with isTerminal, we assume the standard streams share the only terminal.
+func terminalID(writer io.Writer) (terminalKey, bool) {
+	return terminalKey{}, isTerminal(writer)
+}
@@ -24,7 +24,12 @@ func getTermWidth(writer io.Writer) int {
 		return ws.termWidth
 	}
 	var fd uintptr
-	if writer == os.Stdout {
+	if provider, ok := writer.(TerminalInfoProvider); ok {
+		if width := provider.Width(); width > 0 {
+			return width
+		}
+		fd = provider.Fd()
+	} else if writer == os.Stdout {
 		fd = uintptr(syscall.Stdout)
 	} else {
 		// For custom writers, just use the width we get for stderr. This might not be true in some
@@ -43,3 +48,37 @@ func getTermWidth(writer io.Writer) int {
 	}
 	return int(dimensions[1])
 }
+
+// isTerminal reports whether writer is a terminal (as opposed to a file, pipe,
+// or some in-memory buffer).
+func isTerminal(writer io.Writer) bool {
+	if provider, ok := writer.(TerminalInfoProvider); ok {
+		return provider.IsTTY()
+	}
+	file, ok := writer.(*os.File)
+	if !ok {
+		return false
+	}
+	var dimensions [4]uint16
+	_, _, err := syscall.Syscall6(syscall.SYS_IOCTL, file.Fd(), uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&dimensions)), 0, 0, 0)
+	return err == 0
+}
+
+// terminalID identifies the terminal device writer is attached to, if any, so
+// that e.g. os.Stdout and os.Stderr can be recognized as the same terminal.
+func terminalID(writer io.Writer) (terminalKey, bool) {
+	if !isTerminal(writer) {
+		return terminalKey{}, false
+	}
+	var fd uintptr
+	if provider, ok := writer.(TerminalInfoProvider); ok {
+		fd = provider.Fd()
+	} else {
+		fd = writer.(*os.File).Fd()
+	}
+	var stat syscall.Stat_t
+	if err := syscall.Fstat(int(fd), &stat); err != nil {
+		return terminalKey{}, false
+	}
+	return terminalKey{dev: uint64(stat.Rdev), ino: uint64(stat.Ino)}, true
+}
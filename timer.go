@@ -0,0 +1,196 @@
+package alog
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Default thresholds for coloring the durations printed by Timer.
+const (
+	DefaultTimerMediumTime = time.Second
+	DefaultTimerLongTime   = 10 * time.Second
+)
+
+// Timer prints name as a partial line, and returns a func that finishes the
+// line with the time taken since, e.g.:
+//
+//	defer log.Timer("load config")()
+//
+// prints "load config... " and then "load config... 1.25s". The duration is
+// colored green, yellow or red by how it compares to the Logger's
+// SetTimerThresholds. Steps slower than the Logger's SetSlowThresholds are
+// highlighted in their entirety, and logged as warnings or errors.
+func (l *Logger) Timer(name string) func() {
+	return l.timer(nil, name)
+}
+
+// TimerContext is Timer for a step that should end with ctx: if ctx is done
+// first, the line is finished as canceled, in yellow, with the time taken so
+// far. Calling the returned func afterward does nothing.
+func (l *Logger) TimerContext(ctx context.Context, name string) func() {
+	return l.timer(ctx, name)
+}
+
+func (l *Logger) timer(ctx context.Context, name string) func() {
+	if l.isDiscarded() {
+		return func() {}
+	}
+	hasCtx := ctx != nil && ctx.Done() != nil
+	t := l.startTask(3, name, l.now(), true, hasCtx)
+	t.watch(ctx, func() {
+		if t.end() {
+			t.canceled(1, t.l.since(t.start, t.l.now()))
+		}
+	})
+	return func() {
+		if t.end() {
+			t.done(2, "", t.l.since(t.start, t.l.now()))
+		}
+	}
+}
+
+// A task is the line shown for a Timer or Step: started when its work starts,
+// and finished once that's done with the outcome and how long it took.
+type task struct {
+	l     *Logger // shows the line; nil if it isn't shown
+	own   bool    // whether l is the task's own, to be destroyed when it ends
+	label string  // "name... "
+	start time.Time
+	mutex sync.Mutex
+	ended bool
+	stop  chan struct{} // closed when the task ends, if it watches a context
+}
+
+// startTask starts the task name at start, for the caller calldepth frames up,
+// showing its line if show is set. If own is set, the line gets a Logger of
+// its own, so that it can be finished from another goroutine without cutting
+// into whatever the caller is printing.
+func (l *Logger) startTask(calldepth int, name string, start time.Time, show, own bool) *task {
+	if !show || l.isDiscarded() {
+		return &task{start: start}
+	}
+	e := l.newEntry(calldepth + 1)
+	t := &task{l: l, own: own, label: l.Colorify(name) + "... ", start: start}
+	if own {
+		t.l = l.derive("")
+	}
+	t.l.emit(e, []byte(t.label), false)
+	return t
+}
+
+// watch calls canceled, from a goroutine of its own, if ctx is done before
+// the task ends. It must be called before the task can be ended.
+func (t *task) watch(ctx context.Context, canceled func()) {
+	if ctx == nil || ctx.Done() == nil {
+		return
+	}
+	t.stop = make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			canceled()
+		case <-t.stop:
+		}
+	}()
+}
+
+// end marks the task as ended, returning whether it hadn't been already.
+func (t *task) end() bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.ended {
+		return false
+	}
+	t.ended = true
+	if t.stop != nil {
+		close(t.stop)
+	}
+	return true
+}
+
+// done finishes the task's line as done, with status and then elapsed, which
+// is colored by the Logger's SetTimerThresholds. If elapsed passes its
+// SetSlowThresholds, the whole line is highlighted, and logged as a warning or
+// error.
+func (t *task) done(calldepth int, status string, elapsed time.Duration) {
+	if t.l == nil {
+		return
+	}
+	medium, long, warnAfter, errorAfter := t.l.getTimerThresholds()
+	line := t.label + status + FormatDurationColor(elapsed, medium, long)
+	level := LevelInfo
+	if errorAfter != 0 && elapsed >= errorAfter {
+		level = LevelError
+		line = colorize(string(Uncolorize([]byte(line))), ColorRed)
+	} else if warnAfter != 0 && elapsed >= warnAfter {
+		level = LevelWarn
+		line = colorize(string(Uncolorize([]byte(line))), ColorYellow)
+	}
+	t.finish(calldepth+1, level, line)
+}
+
+// canceled finishes the task's line as canceled after elapsed, in yellow, as a
+// warning.
+func (t *task) canceled(calldepth int, elapsed time.Duration) {
+	if t.l == nil {
+		return
+	}
+	line := string(Uncolorize([]byte(t.label))) + "canceled after " + FormatDuration(elapsed)
+	t.finish(calldepth+1, LevelWarn, colorize(line, ColorYellow))
+}
+
+// finish finishes the task's line as line, logged at level.
+func (t *task) finish(calldepth int, level Level, line string) {
+	if t.l == nil {
+		return
+	}
+	e := t.l.newEntry(calldepth + 1)
+	e.level = level
+	t.l.emit(e, []byte(line+"\n"), true)
+	if t.own {
+		t.l.Destroy()
+	}
+}
+
+// StartTimer is Timer for the standard logger. (Timer is taken by the type.)
+func StartTimer(name string) func() { return DefaultLogger.Timer(name) }
+
+func StartTimerContext(ctx context.Context, name string) func() {
+	return DefaultLogger.TimerContext(ctx, name)
+}
+
+// SetTimerThresholds sets the durations at which those printed by Timer turn
+// from green to yellow (medium) and from yellow to red (long).
+func (l *Logger) SetTimerThresholds(medium, long time.Duration) {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	l.timerMediumTime = medium
+	l.timerLongTime = long
+}
+
+// SetSlowThresholds sets how long a step timed by Timer can take before its
+// line is turned yellow and logged as a warning (warnAfter), or turned red and
+// logged as an error (errorAfter). Zero turns either off.
+func (l *Logger) SetSlowThresholds(warnAfter, errorAfter time.Duration) {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	l.slowWarnAfter = warnAfter
+	l.slowErrorAfter = errorAfter
+}
+
+func (l *Logger) getTimerThresholds() (medium, long, warnAfter, errorAfter time.Duration) {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	medium, long = l.timerMediumTime, l.timerLongTime
+	if medium == 0 {
+		medium = DefaultTimerMediumTime
+	}
+	if long == 0 {
+		long = DefaultTimerLongTime
+	}
+	return medium, long, l.slowWarnAfter, l.slowErrorAfter
+}
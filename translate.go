@@ -0,0 +1,36 @@
+package alog
+
+// A Translator returns the format to use in place of msgID, the format passed
+// to Printf and friends, e.g. by looking it up in a message catalog. args are
+// the arguments that will be formatted into it, for choosing plural forms and
+// the like. Color templates in the result are expanded as usual.
+type Translator func(msgID string, args ...interface{}) string
+
+// SetTranslator sets the Translator applied to the formats passed to Printf,
+// Replacef, Error, Debugf, Infof, Warnf, Fatalf, Panicf, PrintTemplate and
+// CompileTemplate, before any formatting is done. nil turns translation off.
+func (l *Logger) SetTranslator(translator Translator) {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	l.translator = translator
+	configChanged()
+}
+
+func SetTranslator(translator Translator) { DefaultLogger.SetTranslator(translator) }
+
+// expandFormat returns format translated and with its color templates
+// expanded, ready to be formatted with v.
+func (l *Logger) expandFormat(format string, v []interface{}) string {
+	c := l.snapshot()
+	if c.translator == nil {
+		return l.colorify(c, format)
+	}
+	// The Translator is called without the lock held, in case it logs. It gets
+	// a copy of v, so that v itself doesn't escape (keeping calls on Discard
+	// free of allocations).
+	args := make([]interface{}, len(v))
+	copy(args, v)
+	format = c.translator(format, args...)
+	return l.colorify(c, format)
+}
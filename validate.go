@@ -0,0 +1,58 @@
+package alog
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// A TemplateError lists what ValidateTemplate found wrong with a template.
+type TemplateError struct {
+	Template string
+	Problems []string
+}
+
+func (e *TemplateError) Error() string {
+	return fmt.Sprintf("invalid template %q: %s", e.Template, strings.Join(e.Problems, "; "))
+}
+
+// ValidateTemplate checks the color templates in s, e.g. a format string from
+// a config file, and returns a *TemplateError listing any problems: codes that
+// don't exist, templates that are never closed, and templates nested inside
+// others (which don't work). Templates are checked with the Logger's template
+// regexp, even if templates are disabled.
+func (l *Logger) ValidateTemplate(s string) error {
+	ws := getWriterState(l.out)
+	ws.lock()
+	colorTemplateRegexp := l.colorRegexp
+	ws.unlock()
+	if colorTemplateRegexp == nil {
+		colorTemplateRegexp = l.defaults().colorRegexp
+	}
+
+	var problems []string
+	buf := []byte(s)
+	matched := make([]bool, len(buf))
+	for _, m := range colorTemplateRegexp.FindAllSubmatchIndex(buf, -1) {
+		for i := m[0]; i < m[1]; i++ {
+			matched[i] = true
+		}
+		for _, code := range unknownTemplateCodes(colorTemplateRegexp, buf[m[0]:m[1]]) {
+			problems = append(problems, fmt.Sprintf("unknown color code %q at offset %d", code, m[0]))
+		}
+		if len(m) >= 8 && m[6] != -1 && bytes.Contains(buf[m[6]:m[7]], []byte("@(")) {
+			problems = append(problems, fmt.Sprintf("template nested inside another at offset %d", m[0]))
+		}
+	}
+	for i := 0; i+1 < len(buf); i++ {
+		if buf[i] == '@' && buf[i+1] == '(' && !matched[i] {
+			problems = append(problems, fmt.Sprintf("unclosed template at offset %d", i))
+		}
+	}
+	if problems != nil {
+		return &TemplateError{Template: s, Problems: problems}
+	}
+	return nil
+}
+
+func ValidateTemplate(s string) error { return DefaultLogger.ValidateTemplate(s) }
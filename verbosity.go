@@ -0,0 +1,103 @@
+package alog
+
+// SetQuiet turns quiet mode on or off, e.g. for a -q flag. In quiet mode, only
+// warnings and errors are printed, and partial lines aren't shown. Turning it
+// off goes back to the level and partial lines setting from before.
+func (l *Logger) SetQuiet(quiet bool) {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	// Quiet and verbose mode can each be turned on while the other is, with the
+	// one turned on last deciding the level
+	if quiet == l.quiet {
+		return
+	}
+	l.quiet = quiet
+	if quiet {
+		l.levelBeforeQuiet = l.level
+		l.verboseOverQuiet = false
+		l.level = LevelWarn
+		l.partialLinesBeforeQuiet = l.partialLinesEnabled
+		l.partialLinesEnabled = &no
+	} else {
+		if l.verbose && l.verboseOverQuiet {
+			// Verbose mode has the level; it goes back to the one from
+			// before either
+			l.levelBeforeVerbose = l.levelBeforeQuiet
+		} else {
+			l.level = l.levelBeforeQuiet
+		}
+		l.partialLinesEnabled = l.partialLinesBeforeQuiet
+	}
+	configChanged()
+}
+
+// SetVerbose turns verbose mode on or off, e.g. for a -v flag. In verbose
+// mode, debug lines are printed too, with the file and line they came from.
+// Turning it off goes back to the level from before, and drops the file and
+// line unless they were asked for separately.
+func (l *Logger) SetVerbose(verbose bool) {
+	ws := getWriterState(l.out)
+	ws.lock()
+	defer ws.unlock()
+	if verbose == l.verbose {
+		return
+	}
+	l.verbose = verbose
+	if verbose {
+		l.levelBeforeVerbose = l.level
+		l.verboseOverQuiet = l.quiet
+		l.level = LevelDebug
+		if l.flag&(Lshortfile|Llongfile) == 0 {
+			l.flag |= Lshortfile
+			l.verboseAddedFlags = Lshortfile
+		}
+	} else {
+		if l.quiet && !l.verboseOverQuiet {
+			// Quiet mode has the level; it goes back to the one from before
+			// either
+			l.levelBeforeQuiet = l.levelBeforeVerbose
+		} else {
+			l.level = l.levelBeforeVerbose
+		}
+		l.flag &^= l.verboseAddedFlags
+		l.verboseAddedFlags = 0
+	}
+	configChanged()
+}
+
+func SetQuiet(quiet bool)     { DefaultLogger.SetQuiet(quiet) }
+func SetVerbose(verbose bool) { DefaultLogger.SetVerbose(verbose) }
+
+// HandleLevelSignals lets operators change the Logger's level while it runs,
+// e.g. to get debug lines out of a stuck daemon without restarting it: SIGUSR1
+// makes it one level more verbose, and SIGUSR2 one level less, printing a line
+// to say so each time. Call the returned function to stop. On systems without
+// those signals, this does nothing.
+func (l *Logger) HandleLevelSignals() (stop func()) {
+	return handleLevelSignals(l)
+}
+
+func HandleLevelSignals() (stop func()) { return handleLevelSignals(DefaultLogger) }
+
+// stepLevel moves the Logger's level by delta, staying between LevelDebug and
+// LevelError, and prints the new level.
+func (l *Logger) stepLevel(delta int) {
+	ws := getWriterState(l.out)
+	ws.lock()
+	level := l.level + Level(delta)
+	if level < LevelDebug {
+		level = LevelDebug
+	} else if level > LevelError {
+		level = LevelError
+	}
+	l.setLevelInt(level)
+	ws.unlock()
+	if l.isDiscarded() {
+		return
+	}
+	// Printed at the new level so that it's always shown
+	e := l.newEntry(2)
+	e.level = level
+	l.emit(e, []byte("log level is now "+highlight(level.String())+"\n"), false)
+}
@@ -0,0 +1,90 @@
+package alog
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxWatchdogInterval is the longest the watchdog goes between checks.
+const maxWatchdogInterval = time.Second
+
+// StartWatchdog watches the Logger's writer for stalls: whenever there are
+// partial lines showing, but nothing has been written by any Logger for after
+// or longer, it shows a dim status line such as "still waiting on
+// downloading foo.tar.gz (45s)…", naming the oldest partial line. This helps
+// tell a hang from slow work. The status line goes away as soon as anything
+// else is written. Call the returned function to stop watching.
+func (l *Logger) StartWatchdog(after time.Duration) (stop func()) {
+	w := &watchdog{l: l, wl: l.derive(""), after: after}
+	w.wl.SetAutoNewlines(false)
+	interval := after
+	if interval > maxWatchdogInterval {
+		interval = maxWatchdogInterval
+	}
+	cancel := l.getScheduler().Every(interval, func() { w.check(l.now()) })
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			cancel()
+			w.check(time.Time{})
+			w.wl.Destroy()
+		})
+	}
+}
+
+func StartWatchdog(after time.Duration) (stop func()) { return DefaultLogger.StartWatchdog(after) }
+
+type watchdog struct {
+	l       *Logger
+	wl      *Logger // shows the status line
+	after   time.Duration
+	showing bool
+}
+
+// check shows, updates or removes the status line as of now. A zero now
+// removes it.
+func (w *watchdog) check(now time.Time) {
+	ellipsis := w.l.glyphs().ellipsis
+	ws := getWriterState(w.l.out)
+	ws.lock()
+	defer ws.unlock()
+	var waitingOn []byte
+	for _, tl := range ws.tempLoggers {
+		if tl != w.wl {
+			waitingOn = tl.buf
+			break
+		}
+	}
+	idle := w.l.between(ws.lastActivity, now)
+	if waitingOn == nil || now.IsZero() || ws.lastActivity.IsZero() || idle < w.after {
+		if w.showing {
+			w.wl.clearPartialLine(ws)
+			w.showing = false
+		}
+		return
+	}
+	what := strings.TrimSpace(string(Uncolorize(waitingOn)))
+	duration := strings.TrimSpace(FormatDuration(idle))
+	s := colorize(fmt.Sprintf("still waiting on %s (%s)%s", what, duration, ellipsis), ColorDim)
+	// The status line itself doesn't count as activity
+	lastActivity := ws.lastActivity
+	w.wl.truncateBuf()
+	e := w.wl.lockedEntry(0)
+	w.wl.intOutput(&e, []byte(s), true)
+	ws.lastActivity = lastActivity
+	w.showing = true
+}
+
+// clearPartialLine discards the Logger's partial line and takes it off the
+// screen. Must be called with the writer locked.
+func (l *Logger) clearPartialLine(ws *WriterState) {
+	l.truncateBuf()
+	if l.tempLineActive {
+		ws.removeTempLogger(l)
+		l.tempLineActive = false
+		updateTempOutput(l.out)
+		ws.writePending(l.out)
+	}
+}